@@ -8,6 +8,30 @@ var DB_NAME string
 var DB_DRIVER int
 var DB_MASTER string
 var DB_SLAVE string
+var DB_REPLICA_MAX_LAG int
+var DB_REPLICA_CHECK_INTERVAL int
+var DB_MIGRATE_ON_START bool
+var DB_STARTUP_TIMEOUT int
+var DB_LOG_STARTUP_TIMEOUT int
+var DB_SLOW_QUERY_THRESHOLD int
+var DB_LOG_QUERIES bool
+var DB_MASTER_MAX_OPEN_CONNS int
+var DB_MASTER_MAX_IDLE_CONNS int
+var DB_MASTER_CONN_MAX_LIFETIME int
+var DB_MASTER_CONN_MAX_IDLE_TIME int
+var DB_MASTER_STATEMENT_TIMEOUT int
+var DB_SLAVE_MAX_OPEN_CONNS int
+var DB_SLAVE_MAX_IDLE_CONNS int
+var DB_SLAVE_CONN_MAX_LIFETIME int
+var DB_SLAVE_CONN_MAX_IDLE_TIME int
+var DB_SLAVE_STATEMENT_TIMEOUT int
+var DB_LOG_MAX_POOL_SIZE int
+var DB_LOG_MIN_POOL_SIZE int
+var DB_LOG_MAX_CONN_IDLE_TIME int
+var DB_LOG_TIMEOUT int
+var DB_LOG_DBNAME string
+var DB_LOG_HTTP_BUFFER_SIZE int
+var DB_LOG_HTTP_TTL int
 
 // log
 var DB_LOG_NAME string
@@ -27,6 +51,8 @@ var HOST_IDLE_TIMEOUT string
 var HOST_PORT string
 var HOST_READ_TIMEOUT int
 var HOST_WRITE_TIMEOUT string
+var HOST_DRAIN_DELAY int
+var HOST_SHUTDOWN_TIMEOUT int
 var LEVEL string
 
 var MYVALUE_BASE_URL string
@@ -35,6 +61,9 @@ var MYVALUE_CLIENT_ID string
 var MYVALUE_CLIENT_SECRET int
 var MYVALUE_EXTERNAL_URL string
 var MYVALUE_REDIRECT_URI string
+var MYVALUE_TIMEOUT int
+var MYVALUE_POINT_CACHE_TTL int
+var MYVALUE_POINT_CACHE_SIZE int
 var SERVICE_NAME string
 
 var SLACK_BOT_NAME string
@@ -49,6 +78,9 @@ var APP_VERSION string
 
 var REDIS_HOST string
 var REDIS_PORT string
+var REDIS_PASSWORD string
+var REDIS_DB int
+var REDIS_TLS bool
 
 var IMAGE_BASE_URL string
 
@@ -56,6 +88,39 @@ var MEILI_HOST string
 var MEILI_PORT string
 var MEILI_API_KEY string
 
+var PII_MASTER_KEYS string
+var PII_MASTER_KEY_VERSION int
+var PII_BLIND_INDEX_KEY string
+
+var OUTBOX_POLL_INTERVAL int
+var OUTBOX_BATCH_SIZE int
+var OUTBOX_MAX_ATTEMPTS int
+var OUTBOX_RETENTION int
+
+var LOG_REDACT_HEADERS string
+var LOG_REDACT_FIELDS string
+var LOG_BODY_LIMIT int
+
+var RATE_LIMIT_BACKEND string
+var RATE_LIMIT_ALGORITHM string
+var RATE_LIMIT_WINDOW int
+var RATE_LIMIT_BURST int
+var RATE_LIMIT_PUBLIC int
+var RATE_LIMIT_USER int
+var RATE_LIMIT_AUTH int
+var RATE_LIMIT_API_KEY int
+var RATE_LIMIT_TRUST_PROXY bool
+
+var IDEMPOTENCY_TTL int
+var IDEMPOTENCY_LOCK_TIMEOUT int
+
+var COMPRESS_MIN_SIZE int
+
+var HEALTH_CHECK_TIMEOUT int
+var HEALTH_CHECK_INTERVAL int
+var HEALTH_HISTORY_SIZE int
+var HEALTH_CRITICAL_DEPENDENCIES string
+
 // Reload reload secret from system's ENV
 func Reload() {
 	// postgres
@@ -63,6 +128,30 @@ func Reload() {
 	DB_DRIVER = viper.GetInt("DB_DRIVER")
 	DB_MASTER = viper.GetString("DB_MASTER")
 	DB_SLAVE = viper.GetString("DB_SLAVE")
+	DB_REPLICA_MAX_LAG = viper.GetInt("DB_REPLICA_MAX_LAG")
+	DB_REPLICA_CHECK_INTERVAL = viper.GetInt("DB_REPLICA_CHECK_INTERVAL")
+	DB_MIGRATE_ON_START = viper.GetBool("DB_MIGRATE_ON_START")
+	DB_SLOW_QUERY_THRESHOLD = viper.GetInt("DB_SLOW_QUERY_THRESHOLD")
+	DB_LOG_QUERIES = viper.GetBool("DB_LOG_QUERIES")
+	DB_STARTUP_TIMEOUT = viper.GetInt("DB_STARTUP_TIMEOUT")
+	DB_LOG_STARTUP_TIMEOUT = viper.GetInt("DB_LOG_STARTUP_TIMEOUT")
+	DB_MASTER_MAX_OPEN_CONNS = viper.GetInt("DB_MASTER_MAX_OPEN_CONNS")
+	DB_MASTER_MAX_IDLE_CONNS = viper.GetInt("DB_MASTER_MAX_IDLE_CONNS")
+	DB_MASTER_CONN_MAX_LIFETIME = viper.GetInt("DB_MASTER_CONN_MAX_LIFETIME")
+	DB_MASTER_CONN_MAX_IDLE_TIME = viper.GetInt("DB_MASTER_CONN_MAX_IDLE_TIME")
+	DB_MASTER_STATEMENT_TIMEOUT = viper.GetInt("DB_MASTER_STATEMENT_TIMEOUT")
+	DB_SLAVE_MAX_OPEN_CONNS = viper.GetInt("DB_SLAVE_MAX_OPEN_CONNS")
+	DB_SLAVE_MAX_IDLE_CONNS = viper.GetInt("DB_SLAVE_MAX_IDLE_CONNS")
+	DB_SLAVE_CONN_MAX_LIFETIME = viper.GetInt("DB_SLAVE_CONN_MAX_LIFETIME")
+	DB_SLAVE_CONN_MAX_IDLE_TIME = viper.GetInt("DB_SLAVE_CONN_MAX_IDLE_TIME")
+	DB_SLAVE_STATEMENT_TIMEOUT = viper.GetInt("DB_SLAVE_STATEMENT_TIMEOUT")
+	DB_LOG_MAX_POOL_SIZE = viper.GetInt("DB_LOG_MAX_POOL_SIZE")
+	DB_LOG_MIN_POOL_SIZE = viper.GetInt("DB_LOG_MIN_POOL_SIZE")
+	DB_LOG_MAX_CONN_IDLE_TIME = viper.GetInt("DB_LOG_MAX_CONN_IDLE_TIME")
+	DB_LOG_TIMEOUT = viper.GetInt("DB_LOG_TIMEOUT")
+	DB_LOG_DBNAME = viper.GetString("DB_LOG_DBNAME")
+	DB_LOG_HTTP_BUFFER_SIZE = viper.GetInt("DB_LOG_HTTP_BUFFER_SIZE")
+	DB_LOG_HTTP_TTL = viper.GetInt("DB_LOG_HTTP_TTL")
 
 	// log mongodb
 	DB_LOG_NAME = viper.GetString("DB_LOG_NAME")
@@ -78,6 +167,8 @@ func Reload() {
 	HOST_PORT = viper.GetString("HOST_PORT")
 	HOST_READ_TIMEOUT = viper.GetInt("HOST_READ_TIMEOUT")
 	HOST_WRITE_TIMEOUT = viper.GetString("HOST_WRITE_TIMEOUT")
+	HOST_DRAIN_DELAY = viper.GetInt("HOST_DRAIN_DELAY")
+	HOST_SHUTDOWN_TIMEOUT = viper.GetInt("HOST_SHUTDOWN_TIMEOUT")
 	LEVEL = viper.GetString("LEVEL")
 	SECRETKEY = viper.GetString("SECRETKEY")
 	SERVICE_NAME = viper.GetString("SERVICE_NAME")
@@ -89,6 +180,9 @@ func Reload() {
 	MYVALUE_CLIENT_SECRET = viper.GetInt("MYVALUE_CLIENT_SECRET")
 	MYVALUE_EXTERNAL_URL = viper.GetString("MYVALUE_EXTERNAL_URL")
 	MYVALUE_REDIRECT_URI = viper.GetString("MYVALUE_REDIRECT_URI")
+	MYVALUE_TIMEOUT = viper.GetInt("MYVALUE_TIMEOUT")
+	MYVALUE_POINT_CACHE_TTL = viper.GetInt("MYVALUE_POINT_CACHE_TTL")
+	MYVALUE_POINT_CACHE_SIZE = viper.GetInt("MYVALUE_POINT_CACHE_SIZE")
 
 	// slacks
 	SLACK_BOT_NAME = viper.GetString("SLACK_BOT_NAME")
@@ -100,6 +194,9 @@ func Reload() {
 	// redis
 	REDIS_HOST = viper.GetString("REDIS_HOST")
 	REDIS_PORT = viper.GetString("REDIS_PORT")
+	REDIS_PASSWORD = viper.GetString("REDIS_PASSWORD")
+	REDIS_DB = viper.GetInt("REDIS_DB")
+	REDIS_TLS = viper.GetBool("REDIS_TLS")
 
 	IMAGE_BASE_URL = viper.GetString("IMAGE_BASE_URL")
 
@@ -108,6 +205,46 @@ func Reload() {
 	MEILI_PORT = viper.GetString("MEILI_PORT")
 	MEILI_API_KEY = viper.GetString("MEILI_API_KEY")
 
+	// pii encryption
+	PII_MASTER_KEYS = viper.GetString("PII_MASTER_KEYS")
+	PII_MASTER_KEY_VERSION = viper.GetInt("PII_MASTER_KEY_VERSION")
+	PII_BLIND_INDEX_KEY = viper.GetString("PII_BLIND_INDEX_KEY")
+
+	// outbox
+	OUTBOX_POLL_INTERVAL = viper.GetInt("OUTBOX_POLL_INTERVAL")
+	OUTBOX_BATCH_SIZE = viper.GetInt("OUTBOX_BATCH_SIZE")
+	OUTBOX_MAX_ATTEMPTS = viper.GetInt("OUTBOX_MAX_ATTEMPTS")
+	OUTBOX_RETENTION = viper.GetInt("OUTBOX_RETENTION")
+
+	// access log
+	LOG_REDACT_HEADERS = viper.GetString("LOG_REDACT_HEADERS")
+	LOG_REDACT_FIELDS = viper.GetString("LOG_REDACT_FIELDS")
+	LOG_BODY_LIMIT = viper.GetInt("LOG_BODY_LIMIT")
+
+	// rate limit
+	RATE_LIMIT_BACKEND = viper.GetString("RATE_LIMIT_BACKEND")
+	RATE_LIMIT_ALGORITHM = viper.GetString("RATE_LIMIT_ALGORITHM")
+	RATE_LIMIT_WINDOW = viper.GetInt("RATE_LIMIT_WINDOW")
+	RATE_LIMIT_BURST = viper.GetInt("RATE_LIMIT_BURST")
+	RATE_LIMIT_PUBLIC = viper.GetInt("RATE_LIMIT_PUBLIC")
+	RATE_LIMIT_USER = viper.GetInt("RATE_LIMIT_USER")
+	RATE_LIMIT_AUTH = viper.GetInt("RATE_LIMIT_AUTH")
+	RATE_LIMIT_API_KEY = viper.GetInt("RATE_LIMIT_API_KEY")
+	RATE_LIMIT_TRUST_PROXY = viper.GetBool("RATE_LIMIT_TRUST_PROXY")
+
+	// idempotency
+	IDEMPOTENCY_TTL = viper.GetInt("IDEMPOTENCY_TTL")
+	IDEMPOTENCY_LOCK_TIMEOUT = viper.GetInt("IDEMPOTENCY_LOCK_TIMEOUT")
+
+	// compression
+	COMPRESS_MIN_SIZE = viper.GetInt("COMPRESS_MIN_SIZE")
+
+	// health
+	HEALTH_CHECK_TIMEOUT = viper.GetInt("HEALTH_CHECK_TIMEOUT")
+	HEALTH_CHECK_INTERVAL = viper.GetInt("HEALTH_CHECK_INTERVAL")
+	HEALTH_HISTORY_SIZE = viper.GetInt("HEALTH_HISTORY_SIZE")
+	HEALTH_CRITICAL_DEPENDENCIES = viper.GetString("HEALTH_CRITICAL_DEPENDENCIES")
+
 }
 
 func ViperBind() {
@@ -116,6 +253,30 @@ func ViperBind() {
 	viper.BindEnv("DB_DRIVER")
 	viper.BindEnv("DB_MASTER")
 	viper.BindEnv("DB_SLAVE")
+	viper.BindEnv("DB_REPLICA_MAX_LAG")
+	viper.BindEnv("DB_REPLICA_CHECK_INTERVAL")
+	viper.BindEnv("DB_MIGRATE_ON_START")
+	viper.BindEnv("DB_SLOW_QUERY_THRESHOLD")
+	viper.BindEnv("DB_LOG_QUERIES")
+	viper.BindEnv("DB_STARTUP_TIMEOUT")
+	viper.BindEnv("DB_LOG_STARTUP_TIMEOUT")
+	viper.BindEnv("DB_MASTER_MAX_OPEN_CONNS")
+	viper.BindEnv("DB_MASTER_MAX_IDLE_CONNS")
+	viper.BindEnv("DB_MASTER_CONN_MAX_LIFETIME")
+	viper.BindEnv("DB_MASTER_CONN_MAX_IDLE_TIME")
+	viper.BindEnv("DB_MASTER_STATEMENT_TIMEOUT")
+	viper.BindEnv("DB_SLAVE_MAX_OPEN_CONNS")
+	viper.BindEnv("DB_SLAVE_MAX_IDLE_CONNS")
+	viper.BindEnv("DB_SLAVE_CONN_MAX_LIFETIME")
+	viper.BindEnv("DB_SLAVE_CONN_MAX_IDLE_TIME")
+	viper.BindEnv("DB_SLAVE_STATEMENT_TIMEOUT")
+	viper.BindEnv("DB_LOG_MAX_POOL_SIZE")
+	viper.BindEnv("DB_LOG_MIN_POOL_SIZE")
+	viper.BindEnv("DB_LOG_MAX_CONN_IDLE_TIME")
+	viper.BindEnv("DB_LOG_TIMEOUT")
+	viper.BindEnv("DB_LOG_DBNAME")
+	viper.BindEnv("DB_LOG_HTTP_BUFFER_SIZE")
+	viper.BindEnv("DB_LOG_HTTP_TTL")
 	viper.BindEnv("DB_LOG_NAME")
 	viper.BindEnv("DB_LOG_DRIVER")
 	viper.BindEnv("DB_LOG_MASTER")
@@ -127,6 +288,9 @@ func ViperBind() {
 	viper.BindEnv("MYVALUE_CLIENT_SECRET")
 	viper.BindEnv("MYVALUE_EXTERNAL_URL")
 	viper.BindEnv("MYVALUE_REDIRECT_URI")
+	viper.BindEnv("MYVALUE_TIMEOUT")
+	viper.BindEnv("MYVALUE_POINT_CACHE_TTL")
+	viper.BindEnv("MYVALUE_POINT_CACHE_SIZE")
 
 	// others
 	viper.BindEnv("SERVICE_NAME")
@@ -138,6 +302,8 @@ func ViperBind() {
 	viper.BindEnv("HOST_PORT")
 	viper.BindEnv("HOST_READ_TIMEOUT")
 	viper.BindEnv("HOST_WRITE_TIMEOUT")
+	viper.BindEnv("HOST_DRAIN_DELAY")
+	viper.BindEnv("HOST_SHUTDOWN_TIMEOUT")
 	viper.BindEnv("SECRETKEY")
 	viper.BindEnv("ISSUER")
 
@@ -151,6 +317,9 @@ func ViperBind() {
 	// redis
 	viper.BindEnv("REDIS_HOST")
 	viper.BindEnv("REDIS_PORT")
+	viper.BindEnv("REDIS_PASSWORD")
+	viper.BindEnv("REDIS_DB")
+	viper.BindEnv("REDIS_TLS")
 
 	// image
 	viper.BindEnv("IMAGE_BASE_URL")
@@ -160,4 +329,44 @@ func ViperBind() {
 	viper.BindEnv("MEILI_PORT")
 	viper.BindEnv("MEILI_API_KEY")
 
+	// pii encryption
+	viper.BindEnv("PII_MASTER_KEYS")
+	viper.BindEnv("PII_MASTER_KEY_VERSION")
+	viper.BindEnv("PII_BLIND_INDEX_KEY")
+
+	// outbox
+	viper.BindEnv("OUTBOX_POLL_INTERVAL")
+	viper.BindEnv("OUTBOX_BATCH_SIZE")
+	viper.BindEnv("OUTBOX_MAX_ATTEMPTS")
+	viper.BindEnv("OUTBOX_RETENTION")
+
+	// access log
+	viper.BindEnv("LOG_REDACT_HEADERS")
+	viper.BindEnv("LOG_REDACT_FIELDS")
+	viper.BindEnv("LOG_BODY_LIMIT")
+
+	// rate limit
+	viper.BindEnv("RATE_LIMIT_BACKEND")
+	viper.BindEnv("RATE_LIMIT_ALGORITHM")
+	viper.BindEnv("RATE_LIMIT_WINDOW")
+	viper.BindEnv("RATE_LIMIT_BURST")
+	viper.BindEnv("RATE_LIMIT_PUBLIC")
+	viper.BindEnv("RATE_LIMIT_USER")
+	viper.BindEnv("RATE_LIMIT_AUTH")
+	viper.BindEnv("RATE_LIMIT_API_KEY")
+	viper.BindEnv("RATE_LIMIT_TRUST_PROXY")
+
+	// idempotency
+	viper.BindEnv("IDEMPOTENCY_TTL")
+	viper.BindEnv("IDEMPOTENCY_LOCK_TIMEOUT")
+
+	// compression
+	viper.BindEnv("COMPRESS_MIN_SIZE")
+
+	// health
+	viper.BindEnv("HEALTH_CHECK_TIMEOUT")
+	viper.BindEnv("HEALTH_CHECK_INTERVAL")
+	viper.BindEnv("HEALTH_HISTORY_SIZE")
+	viper.BindEnv("HEALTH_CRITICAL_DEPENDENCIES")
+
 }
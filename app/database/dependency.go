@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	// DependencyState last known state of a dependency
+	DependencyState struct {
+		Name  string    `json:"name"`
+		Up    bool      `json:"up"`
+		Error string    `json:"error,omitempty"`
+		Since time.Time `json:"since"`
+	}
+
+	// Dependency state of a connection, set at startup then kept by its health check so the
+	// repositories can degrade instead of the process exiting
+	Dependency struct {
+		mu    sync.RWMutex
+		state DependencyState
+		onUp  []func()
+	}
+)
+
+// NewDependency initialize a dependency that is down until SetUp
+func NewDependency(name string) *Dependency {
+	return &Dependency{state: DependencyState{Name: name, Error: "not connected", Since: time.Now()}}
+}
+
+// State last known state
+func (d *Dependency) State() DependencyState {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.state
+}
+
+// IsUp whether the dependency is reachable
+func (d *Dependency) IsUp() bool {
+	return d.State().Up
+}
+
+// SetUp mark the dependency reachable and run the OnUp callbacks waiting for it
+func (d *Dependency) SetUp() {
+	d.mu.Lock()
+	if d.state.Up {
+		d.mu.Unlock()
+		return
+	}
+	d.state = DependencyState{Name: d.state.Name, Up: true, Since: time.Now()}
+	callbacks := d.onUp
+	d.onUp = nil
+	d.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// SetDown mark the dependency unreachable
+func (d *Dependency) SetDown(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	since := d.state.Since
+	if d.state.Up {
+		since = time.Now()
+	}
+	d.state = DependencyState{Name: d.state.Name, Error: err.Error(), Since: since}
+}
+
+// OnUp run fn once the dependency is up, right away when it already is. Use it for setup that
+// needs the server, e.g. creating indexes.
+func (d *Dependency) OnUp(fn func()) {
+	d.mu.Lock()
+	if !d.state.Up {
+		d.onUp = append(d.onUp, fn)
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+
+	fn()
+}
+
+// Track wrap the ping of the dependency so its state follows the result, the health check of the
+// dependency is then the only one pinging it. A canceled ping leaves the state as it is.
+func (d *Dependency) Track(ping func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := ping(ctx)
+		switch {
+		case errors.Is(err, context.Canceled):
+		case err != nil:
+			d.SetDown(err)
+		default:
+			d.SetUp()
+		}
+		return err
+	}
+}
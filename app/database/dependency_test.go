@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependency(t *testing.T) {
+	d := NewDependency("mongodb log")
+	assert.False(t, d.IsUp())
+
+	ran := 0
+	d.OnUp(func() { ran++ })
+	assert.Equal(t, 0, ran)
+
+	d.SetUp()
+	assert.True(t, d.IsUp())
+	assert.Equal(t, 1, ran)
+
+	// already up, runs right away and only once
+	d.OnUp(func() { ran++ })
+	d.SetUp()
+	assert.Equal(t, 2, ran)
+
+	d.SetDown(errors.New("connection refused"))
+	state := d.State()
+	assert.False(t, state.Up)
+	assert.Equal(t, "connection refused", state.Error)
+	assert.Equal(t, "mongodb log", state.Name)
+}
+
+func TestDependencyTrack(t *testing.T) {
+	d := NewDependency("mongodb log")
+	var pingErr error
+	check := d.Track(func(ctx context.Context) error { return pingErr })
+
+	assert.NoError(t, check(context.Background()))
+	assert.True(t, d.IsUp())
+
+	pingErr = errors.New("connection refused")
+	assert.Equal(t, pingErr, check(context.Background()))
+	assert.False(t, d.IsUp())
+
+	// a canceled ping says nothing about the dependency
+	d.SetUp()
+	pingErr = context.Canceled
+	check(context.Background())
+	assert.True(t, d.IsUp())
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var (
+	mongoPoolOpen = prometheus.NewDesc("mongodb_pool_open_connections",
+		"Open connections of the mongodb pool.", []string{"db_name"}, nil)
+	mongoPoolInUse = prometheus.NewDesc("mongodb_pool_in_use_connections",
+		"Connections of the mongodb pool checked out.", []string{"db_name"}, nil)
+	mongoPoolCreated = prometheus.NewDesc("mongodb_pool_created_connections_total",
+		"Connections created by the mongodb pool.", []string{"db_name"}, nil)
+	mongoPoolClosed = prometheus.NewDesc("mongodb_pool_closed_connections_total",
+		"Connections closed by the mongodb pool.", []string{"db_name"}, nil)
+	mongoPoolCheckedOut = prometheus.NewDesc("mongodb_pool_checked_out_total",
+		"Connections checked out of the mongodb pool.", []string{"db_name"}, nil)
+	mongoPoolCheckOutFailed = prometheus.NewDesc("mongodb_pool_check_out_failed_total",
+		"Failed checkouts of the mongodb pool.", []string{"db_name"}, nil)
+	mongoPoolCleared = prometheus.NewDesc("mongodb_pool_cleared_total",
+		"Times the mongodb pool was cleared after a server error.", []string{"db_name"}, nil)
+)
+
+// mongoPoolCollector prometheus.Collector of the pool events of the log database
+type mongoPoolCollector struct {
+	mongoCollection MongoCollection
+}
+
+// NewPoolCollectors collectors of the connection pools, the postgres ones are the go_sql_* metrics
+// of sql.DBStats labeled db_name master or slave, the mongodb ones the mongodb_pool_* metrics
+func NewPoolCollectors(postgresCollection PostgresCollection, mongoCollection MongoCollection) []prometheus.Collector {
+	pools := []prometheus.Collector{
+		collectors.NewDBStatsCollector(postgresCollection.Master.DB, "master"),
+		mongoPoolCollector{mongoCollection: mongoCollection},
+	}
+	if postgresCollection.Slave != nil {
+		pools = append(pools, collectors.NewDBStatsCollector(postgresCollection.Slave.DB, "slave"))
+	}
+	return pools
+}
+
+// Describe implement prometheus.Collector
+func (c mongoPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mongoPoolOpen
+	ch <- mongoPoolInUse
+	ch <- mongoPoolCreated
+	ch <- mongoPoolClosed
+	ch <- mongoPoolCheckedOut
+	ch <- mongoPoolCheckOutFailed
+	ch <- mongoPoolCleared
+}
+
+// Collect implement prometheus.Collector
+func (c mongoPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, stats := range c.mongoCollection.PoolStats() {
+		ch <- prometheus.MustNewConstMetric(mongoPoolOpen, prometheus.GaugeValue, float64(stats.Open), name)
+		ch <- prometheus.MustNewConstMetric(mongoPoolInUse, prometheus.GaugeValue, float64(stats.InUse), name)
+		ch <- prometheus.MustNewConstMetric(mongoPoolCreated, prometheus.CounterValue, float64(stats.Created), name)
+		ch <- prometheus.MustNewConstMetric(mongoPoolClosed, prometheus.CounterValue, float64(stats.Closed), name)
+		ch <- prometheus.MustNewConstMetric(mongoPoolCheckedOut, prometheus.CounterValue, float64(stats.CheckedOut), name)
+		ch <- prometheus.MustNewConstMetric(mongoPoolCheckOutFailed, prometheus.CounterValue, float64(stats.CheckOutFailed), name)
+		ch <- prometheus.MustNewConstMetric(mongoPoolCleared, prometheus.CounterValue, float64(stats.Cleared), name)
+	}
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/utils/mongodb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMongoPoolCollector(t *testing.T) {
+	collector := mongoPoolCollector{mongoCollection: MongoCollection{masterStats: &mongodb.PoolStats{}}}
+
+	expected := `
+# HELP mongodb_pool_open_connections Open connections of the mongodb pool.
+# TYPE mongodb_pool_open_connections gauge
+mongodb_pool_open_connections{db_name="master"} 0
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "mongodb_pool_open_connections"))
+	// no slave, the seven metrics of the master
+	assert.Equal(t, 7, testutil.CollectAndCount(collector))
+}
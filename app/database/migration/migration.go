@@ -0,0 +1,311 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// SOURCE_DIR where Create writes new migrations, relative to the repository root. The files are
+// embedded at build time so the binary has to be rebuilt after adding one.
+const SOURCE_DIR = "app/database/migration/sql"
+
+var (
+	//go:embed sql/*.sql
+	embedded embed.FS
+
+	// TableMigration applied versions
+	TableMigration = fmt.Sprintf("%v.%v", "public", "schema_migrations")
+
+	// lockKey pg_advisory_lock key, only one instance migrates at a time
+	lockKey int64 = 7_264_118_902
+
+	// <version>_<name>.<up|down>.sql
+	fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nameSanitizer   = regexp.MustCompile(`[^a-z0-9]+`)
+
+	ErrNoChange     = errors.New("migration: no change")
+	ErrMissingDown  = errors.New("migration: down file is missing")
+	ErrInvalidSteps = errors.New("migration: steps must be positive")
+)
+
+type (
+	// Migration versioned up and down sql
+	Migration struct {
+		Version int64
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	// Status applied state of a migration
+	Status struct {
+		Version   int64      `json:"version" db:"version"`
+		Name      string     `json:"name" db:"name"`
+		AppliedAt *time.Time `json:"applied_at" db:"applied_at"`
+	}
+
+	// Migrator apply the embedded migrations to db
+	Migrator struct {
+		db         *sqlx.DB
+		migrations []Migration
+	}
+)
+
+// NewMigrator initialize migrator with the embedded migrations
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	files, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load read the migrations at the root of fsys ordered by version, every version must have an up file
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, file := range files {
+		matches := fileNamePattern.FindStringSubmatch(file)
+		if matches == nil {
+			return nil, fmt.Errorf("migration: invalid file name %s, expected <version>_<name>.<up|down>.sql", file)
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration: invalid version of %s", file)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration: version %d is used by %s and %s", version, migration.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, fmt.Errorf("migration: up file of version %d is missing", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up apply every pending migration, returns the applied ones
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			log.Info().Msgf("migrate up %d_%s", migration.Version, migration.Name)
+			insert := fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", TableMigration)
+			if err := execInTx(ctx, conn, migration.Up, insert, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("migration: up %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down revert the last steps applied migrations, returns the reverted ones
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, ErrInvalidSteps
+	}
+
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("%w: %d_%s", ErrMissingDown, migration.Version, migration.Name)
+			}
+			log.Info().Msgf("migrate down %d_%s", migration.Version, migration.Name)
+			remove := fmt.Sprintf("DELETE FROM %s WHERE version = $1", TableMigration)
+			if err := execInTx(ctx, conn, migration.Down, remove, migration.Version); err != nil {
+				return fmt.Errorf("migration: down %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		if len(reverted) == 0 {
+			return ErrNoChange
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status every embedded migration with its applied time, nil when pending. It only reads, so it
+// does not wait for the lock held by a running migration.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	if err := m.db.GetContext(ctx, &exists, "SELECT to_regclass($1) IS NOT NULL", TableMigration); err != nil {
+		return nil, err
+	}
+	versions := map[int64]time.Time{}
+	if exists {
+		var err error
+		if versions, err = appliedVersions(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Create write an empty up and down file with the next version into dir, returns the file paths
+func Create(dir, name string) (up, down string, err error) {
+	name = strings.Trim(nameSanitizer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration: name is empty")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	prefix := filepath.Join(dir, fmt.Sprintf("%06d_%s", version, name))
+	up, down = prefix+".up.sql", prefix+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- revert "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+
+	return up, down, nil
+}
+
+// withLock run fn on a single connection holding the advisory lock, so concurrent pods wait for
+// each other instead of applying the same migration twice
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("migration: acquire lock: %w", err)
+	}
+	defer func() {
+		// the lock must be released even when ctx is done
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			log.Error().Msgf("error when release migration lock, err: %v", err)
+		}
+	}()
+
+	create := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`, TableMigration)
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions applied time by version
+func appliedVersions(ctx context.Context, q sqlx.QueryerContext) (map[int64]time.Time, error) {
+	var rows []Status
+	query := fmt.Sprintf("SELECT version, name, applied_at FROM %s", TableMigration)
+	if err := sqlx.SelectContext(ctx, q, &rows, query); err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = *row.AppliedAt
+	}
+	return versions, nil
+}
+
+// execInTx run the migration sql and the bookkeeping query atomically
+func execInTx(ctx context.Context, conn *sqlx.Conn, migration, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"000002_add_index.up.sql":     {Data: []byte("CREATE INDEX")},
+		"000002_add_index.down.sql":   {Data: []byte("DROP INDEX")},
+		"000001_create_user.up.sql":   {Data: []byte("CREATE TABLE")},
+		"000001_create_user.down.sql": {Data: []byte("DROP TABLE")},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_user", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE", migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+
+	_, err = Load(fstest.MapFS{"000001_create_user.down.sql": {Data: []byte("DROP TABLE")}})
+	assert.Error(t, err)
+
+	_, err = Load(fstest.MapFS{"create_user.sql": {Data: []byte("CREATE TABLE")}})
+	assert.Error(t, err)
+}
+
+func TestEmbedded(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrator.migrations)
+	for _, migration := range migrator.migrations {
+		assert.NotEmpty(t, migration.Down, "version %d has no down file", migration.Version)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "000001_create_user.up.sql"), []byte("CREATE TABLE"), 0o644))
+
+	up, down, err := Create(dir, "Add Outbox Table")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000002_add_outbox_table.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "000002_add_outbox_table.down.sql"), down)
+
+	_, _, err = Create(dir, "!!")
+	assert.Error(t, err)
+}
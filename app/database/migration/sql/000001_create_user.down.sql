@@ -0,0 +1,2 @@
+-- baseline of the legacy public."user", it is never dropped, revert the later migrations instead
+SELECT 1;
@@ -0,0 +1,29 @@
+-- baseline of the legacy public."user", a no-op on the legacy databases. Column and index changes go
+-- in their own ALTER TABLE migration so they are applied to the legacy databases too.
+CREATE TABLE IF NOT EXISTS public."user" (
+    id                   BIGSERIAL PRIMARY KEY,
+    email                VARCHAR(254) NOT NULL,
+    first_name           VARCHAR(150),
+    last_name            VARCHAR(150),
+    phone_number         VARCHAR(20),
+    username             VARCHAR(150),
+    password             VARCHAR(256),
+    last_login           TIMESTAMPTZ,
+    is_superuser         BOOLEAN NOT NULL DEFAULT FALSE,
+    is_staff             BOOLEAN NOT NULL DEFAULT FALSE,
+    is_active            BOOLEAN NOT NULL DEFAULT TRUE,
+    is_guest             BOOLEAN NOT NULL DEFAULT FALSE,
+    date_joined          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    properties           TEXT NOT NULL DEFAULT '',
+    corporate_account_id BIGINT NOT NULL DEFAULT 0,
+    author_id            BIGINT NOT NULL DEFAULT 0,
+    birth_place          VARCHAR(150),
+    birth_date           DATE,
+    gender               VARCHAR(20),
+    home_phone_number    VARCHAR(20),
+    occupation           VARCHAR(150),
+    hobby                TEXT,
+    identity_image       TEXT,
+    identity_number      VARCHAR(50),
+    identity_type        VARCHAR(20)
+);
@@ -0,0 +1,18 @@
+-- domain events written in the transaction of the change, published by the outbox relay
+-- status: pending, published or dead (gave up after OUTBOX_MAX_ATTEMPTS)
+CREATE TABLE IF NOT EXISTS public.outbox (
+    id             BIGSERIAL PRIMARY KEY,
+    event_type     VARCHAR(100) NOT NULL,
+    aggregate_type VARCHAR(100) NOT NULL,
+    aggregate_id   VARCHAR(100) NOT NULL,
+    payload        JSONB NOT NULL,
+    status         VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts       INTEGER NOT NULL DEFAULT 0,
+    last_error     TEXT,
+    available_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at   TIMESTAMPTZ
+);
+
+-- the relay only scans the pending events that are due
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON public.outbox (available_at, id) WHERE status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE public."user" DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,2 @@
+-- version of the row for optimistic concurrency, every update must match and bump it
+ALTER TABLE public."user" ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
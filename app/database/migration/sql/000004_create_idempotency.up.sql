@@ -0,0 +1,18 @@
+-- responses of the requests sent with an Idempotency-Key, replayed when the client retries
+-- status: processing while the first request runs, completed once its response is stored
+CREATE TABLE IF NOT EXISTS public.idempotency (
+    scope                 VARCHAR(100) NOT NULL,
+    idempotency_key       VARCHAR(255) NOT NULL,
+    fingerprint           CHAR(64) NOT NULL,
+    status                VARCHAR(20) NOT NULL DEFAULT 'processing',
+    response_status       INTEGER NOT NULL DEFAULT 0,
+    response_content_type VARCHAR(255) NOT NULL DEFAULT '',
+    response_body         BYTEA,
+    locked_until          TIMESTAMPTZ NOT NULL,
+    expires_at            TIMESTAMPTZ NOT NULL,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (scope, idempotency_key)
+);
+
+-- the expired keys are purged in the background
+CREATE INDEX IF NOT EXISTS idempotency_expires_at_idx ON public.idempotency (expires_at);
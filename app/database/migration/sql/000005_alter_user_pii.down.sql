@@ -0,0 +1,4 @@
+-- the columns stay text, the ciphertext does not fit the legacy types. verified, is_deleted and the
+-- email and username indexes may predate this migration so they are kept.
+DROP INDEX IF EXISTS public.user_phone_number_bidx_idx;
+ALTER TABLE public."user" DROP COLUMN IF EXISTS phone_number_bidx;
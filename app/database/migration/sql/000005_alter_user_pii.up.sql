@@ -0,0 +1,16 @@
+-- verified and is_deleted are missing on some legacy databases (gb_staging2)
+ALTER TABLE public."user" ADD COLUMN IF NOT EXISTS verified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE public."user" ADD COLUMN IF NOT EXISTS is_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- phone_number, birth_date, home_phone_number and identity_number are encrypted by utils/fieldcrypt,
+-- they are text so the ciphertext fits. The legacy plaintext is read as is until it is rewritten.
+ALTER TABLE public."user" ALTER COLUMN phone_number TYPE TEXT;
+ALTER TABLE public."user" ALTER COLUMN birth_date TYPE TEXT USING birth_date::TEXT;
+ALTER TABLE public."user" ALTER COLUMN home_phone_number TYPE TEXT;
+ALTER TABLE public."user" ALTER COLUMN identity_number TYPE TEXT;
+ALTER TABLE public."user" ADD COLUMN IF NOT EXISTS phone_number_bidx VARCHAR(64);
+
+-- email and username are case insensitive, the index names are mapped by repository.mapUniqueViolation
+CREATE UNIQUE INDEX IF NOT EXISTS user_email_key ON public."user" (lower(email));
+CREATE UNIQUE INDEX IF NOT EXISTS user_username_key ON public."user" (lower(username));
+CREATE INDEX IF NOT EXISTS user_phone_number_bidx_idx ON public."user" (phone_number_bidx);
@@ -0,0 +1,25 @@
+-- data subject requests of the users, run by the privacy runner of any instance
+-- status: pending, running while an instance holds the lease (locked_until), done or failed
+-- step: the last erasure step done, a retry resumes after it
+CREATE TABLE IF NOT EXISTS public.privacy_job (
+    id           VARCHAR(26) PRIMARY KEY,
+    user_id      BIGINT NOT NULL,
+    type         VARCHAR(20) NOT NULL,
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending',
+    step         VARCHAR(50) NOT NULL DEFAULT '',
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    error        TEXT NOT NULL DEFAULT '',
+    request_id   VARCHAR(100) NOT NULL DEFAULT '',
+    archive      BYTEA,
+    locked_until TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at  TIMESTAMPTZ
+);
+
+-- one pending or running job of a type per user
+CREATE UNIQUE INDEX IF NOT EXISTS privacy_job_active_key ON public.privacy_job (user_id, type)
+    WHERE status IN ('pending', 'running');
+-- the runner only scans the unfinished jobs, the purge the finished ones
+CREATE INDEX IF NOT EXISTS privacy_job_unfinished_idx ON public.privacy_job (created_at)
+    WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS privacy_job_finished_at_idx ON public.privacy_job (finished_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.user_phone_number_bidx_key;
+CREATE INDEX IF NOT EXISTS user_phone_number_bidx_idx ON public."user" (phone_number_bidx);
@@ -0,0 +1,26 @@
+-- a mobile phone number belongs to one user, the check of the service races with a concurrent
+-- write so the index enforces it. The migration fails listing the users sharing a phone number,
+-- they must be resolved before it is applied again, see the Migration section of README.md.
+-- The index name is mapped by repository.mapUniqueViolation.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(ids, '; ') INTO duplicates
+    FROM (
+        SELECT string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+        FROM public."user"
+        WHERE phone_number_bidx IS NOT NULL
+        GROUP BY phone_number_bidx
+        HAVING count(*) > 1
+    ) AS shared;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users sharing a phone number, ids of each phone number: %', duplicates
+            USING HINT = 'keep the phone number on one user of each group and clear it on the others';
+    END IF;
+END
+$$;
+
+DROP INDEX IF EXISTS public.user_phone_number_bidx_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS user_phone_number_bidx_key ON public."user" (phone_number_bidx);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.outbox_published_at_idx;
+ALTER TABLE public.outbox DROP COLUMN IF EXISTS locked_until;
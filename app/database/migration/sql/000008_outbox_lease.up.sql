@@ -0,0 +1,9 @@
+-- the relay leases the claimed events until locked_until and publishes them outside the transaction,
+-- the events of a crashed relay are claimed again once their lease runs out
+ALTER TABLE public.outbox ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+-- the published events are removed after OUTBOX_RETENTION
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON public.outbox (published_at) WHERE status = 'published';
+
+-- user.created only carries the id of the user, the personal data is read from the user service
+UPDATE public.outbox SET payload = payload - 'email' - 'username' WHERE event_type = 'user.created';
@@ -1,8 +1,12 @@
 package database
 
 import (
+	"context"
+	"errors"
+
 	"github.com/erwinwahyura/go-boilerplate/app/model"
 	"github.com/erwinwahyura/go-boilerplate/utils/mongodb"
+	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -10,17 +14,56 @@ import (
 type MongoCollection struct {
 	MessageMaster *mongo.Database
 	MessageSlave  *mongo.Database
+
+	// State of the master, the log database is optional so the app runs degraded while it is down
+	State *Dependency
+
+	masterStats, slaveStats *mongodb.PoolStats
 }
 
-// NewMongoCollection ...
-func NewMongoCollection(config model.Config) MongoCollection {
+// NewMongoCollection connect the log database with a single ping bounded by ctx. When it is down the
+// collection is returned anyway, the driver keeps connecting in the background and the health check
+// marks it up once it answers, only an invalid configuration is an error.
+func NewMongoCollection(ctx context.Context, config model.Config) (MongoCollection, error) {
 
 	// DB
-	master := mongodb.NewMongoDsn(config.Database.LogDB.Master, "dbname")
-	slave := mongodb.NewMongoDsn(config.Database.LogDB.Slave, "dbname")
+	dbname := config.Database.LogDB.DBName
+	if dbname == "" {
+		return MongoCollection{}, errors.New("DB_LOG_DBNAME is not set")
+	}
+	masterStats, slaveStats := &mongodb.PoolStats{}, &mongodb.PoolStats{}
+	master, err := mongodb.NewMongoDsn(config.Database.LogDB.Master, dbname, logPool(config.Database.LogDB, masterStats))
+	if err != nil {
+		return MongoCollection{}, err
+	}
+	slave, err := mongodb.NewMongoDsn(config.Database.LogDB.Slave, dbname, logPool(config.Database.LogDB, slaveStats))
+	if err != nil {
+		return MongoCollection{}, err
+	}
 
-	return MongoCollection{
+	collection := MongoCollection{
 		MessageMaster: master,
 		MessageSlave:  slave,
+		State:         NewDependency("mongodb log"),
+		masterStats:   masterStats,
+		slaveStats:    slaveStats,
+	}
+
+	log.Info().Msg("ping mongodb log")
+	if err := mongodb.Ping(ctx, master); err != nil {
+		log.Error().Msgf("mongodb log is down, starting degraded, err: %v", err)
+		collection.State.SetDown(err)
+	} else {
+		collection.State.SetUp()
+	}
+
+	return collection, nil
+}
+
+// Close disconnect
+func (m MongoCollection) Close(ctx context.Context) error {
+	if err := m.MessageSlave.Client().Disconnect(ctx); err != nil {
+		log.Error().Msgf("error when disconnect mongodb log slave, err: %v", err)
 	}
+	return m.MessageMaster.Client().Disconnect(ctx)
 }
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils/mongodb"
+	"github.com/erwinwahyura/go-boilerplate/utils/sqlxdb"
+)
+
+// masterPool connection pool of the postgres master
+func masterPool(config model.Datasource) sqlxdb.PoolConfig {
+	return sqlxdb.PoolConfig{
+		MaxOpenConns:     config.MasterMaxOpenConns,
+		MaxIdleConns:     config.MasterMaxIdleConns,
+		ConnMaxLifetime:  time.Duration(config.MasterConnMaxLifetime) * time.Second,
+		ConnMaxIdleTime:  time.Duration(config.MasterConnMaxIdleTime) * time.Second,
+		StatementTimeout: time.Duration(config.MasterStatementTimeout) * time.Millisecond,
+	}
+}
+
+// slavePool connection pool of the postgres slave
+func slavePool(config model.Datasource) sqlxdb.PoolConfig {
+	return sqlxdb.PoolConfig{
+		MaxOpenConns:     config.SlaveMaxOpenConns,
+		MaxIdleConns:     config.SlaveMaxIdleConns,
+		ConnMaxLifetime:  time.Duration(config.SlaveConnMaxLifetime) * time.Second,
+		ConnMaxIdleTime:  time.Duration(config.SlaveConnMaxIdleTime) * time.Second,
+		StatementTimeout: time.Duration(config.SlaveStatementTimeout) * time.Millisecond,
+	}
+}
+
+// logPool connection pool of the mongodb log master or slave
+func logPool(config model.DatasourceLog, stats *mongodb.PoolStats) mongodb.PoolConfig {
+	return mongodb.PoolConfig{
+		MaxPoolSize:     uint64(config.MaxPoolSize),
+		MinPoolSize:     uint64(config.MinPoolSize),
+		MaxConnIdleTime: time.Duration(config.MaxConnIdleTime) * time.Second,
+		Timeout:         time.Duration(config.Timeout) * time.Millisecond,
+		Stats:           stats,
+	}
+}
+
+// PoolStats connection pool of the master and the slave
+func (p PostgresCollection) PoolStats() map[string]model.PostgresPoolStats {
+	stats := map[string]model.PostgresPoolStats{
+		"master": mapPostgresPoolStats(p.Master.Stats()),
+	}
+	if p.Slave != nil {
+		stats["slave"] = mapPostgresPoolStats(p.Slave.Stats())
+	}
+	return stats
+}
+
+// PoolStats connection pool of the master and the slave
+func (m MongoCollection) PoolStats() map[string]mongodb.PoolStatsSnapshot {
+	stats := map[string]mongodb.PoolStatsSnapshot{}
+	if m.masterStats != nil {
+		stats["master"] = m.masterStats.Snapshot()
+	}
+	if m.slaveStats != nil {
+		stats["slave"] = m.slaveStats.Snapshot()
+	}
+	return stats
+}
+
+func mapPostgresPoolStats(stats sql.DBStats) model.PostgresPoolStats {
+	return model.PostgresPoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
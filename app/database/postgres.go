@@ -1,28 +1,148 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"time"
+
 	"github.com/erwinwahyura/go-boilerplate/app/model"
 	"github.com/erwinwahyura/go-boilerplate/utils/sqlxdb"
 	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
 )
 
 var (
 	DRIVER_POSTGRES = "postgres"
+
+	// DEFAULT_REPLICA_MAX_LAG used when DB_REPLICA_MAX_LAG is not set
+	DEFAULT_REPLICA_MAX_LAG = 10 * time.Second
+	// DEFAULT_REPLICA_CHECK_INTERVAL used when DB_REPLICA_CHECK_INTERVAL is not set
+	DEFAULT_REPLICA_CHECK_INTERVAL = 5 * time.Second
 )
 
-type PostgresCollection struct {
-	Master *sqlx.DB
-	Slave  *sqlx.DB
-}
+type (
+	// Queryer query methods shared by *sqlx.DB and *sqlx.Tx
+	Queryer interface {
+		sqlx.ExtContext
+		GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+		SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+		NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	}
+
+	// PostgresCollection route queries between the master and the slave, repositories should use
+	// Reader and Writer instead of picking Master or Slave by hand
+	PostgresCollection struct {
+		Master *sqlx.DB
+		// Slave nil when DB_SLAVE is not set, reads then go to the master
+		Slave *sqlx.DB
+
+		// State of the master
+		State *Dependency
+
+		replica *replicaMonitor
+		// queryLog nil leaves the queries unlogged
+		queryLog *queryLogger
+	}
+
+	readYourWritesContextKey struct{}
+)
 
-func NewPostgresCollection(config model.Config) PostgresCollection {
+// NewPostgresCollection connect the master, the ping is retried until ctx is done. The master is
+// required so it is an error when it is still down, the slave is optional.
+func NewPostgresCollection(ctx context.Context, config model.Config) (PostgresCollection, error) {
 
 	// DB
-	master := sqlxdb.NewSqlxDsn(DRIVER_POSTGRES, config.Database.DB.Master)
-	slave := sqlxdb.NewSqlxDsn(DRIVER_POSTGRES, config.Database.DB.Slave)
+	master, err := sqlxdb.ConnectSqlxDsn(ctx, DRIVER_POSTGRES, config.Database.DB.Master, masterPool(config.Database.DB))
+	if err != nil {
+		return PostgresCollection{}, err
+	}
+	collection := PostgresCollection{
+		Master:   master,
+		State:    NewDependency("postgres"),
+		queryLog: newQueryLogger(config.Database.DB),
+	}
+	collection.State.SetUp()
+
+	// a slave that is down only degrades reads to the master, it must not stop the startup
+	if config.Database.DB.Slave == "" {
+		log.Warn().Msg("DB_SLAVE is not set, reads go to the master")
+		return collection, nil
+	}
+	slave, err := sqlxdb.OpenSqlxDsn(DRIVER_POSTGRES, config.Database.DB.Slave, slavePool(config.Database.DB))
+	if err != nil {
+		log.Error().Msgf("error when open slave, reads go to the master, err: %v", err)
+		return collection, nil
+	}
+
+	maxLag := time.Duration(config.Database.DB.ReplicaMaxLag) * time.Second
+	if maxLag <= 0 {
+		maxLag = DEFAULT_REPLICA_MAX_LAG
+	}
+	interval := time.Duration(config.Database.DB.ReplicaCheckInterval) * time.Second
+	if interval <= 0 {
+		interval = DEFAULT_REPLICA_CHECK_INTERVAL
+	}
+
+	collection.Slave = slave
+	collection.replica = newReplicaMonitor(slave, maxLag, interval)
 
-	return PostgresCollection{
-		Master: master,
-		Slave:  slave,
+	return collection, nil
+}
+
+// Writer queryer of writes, the transaction of ctx or the master
+func (p PostgresCollection) Writer(ctx context.Context) Queryer {
+	if tx, ok := TxFromContext(ctx); ok {
+		return p.queryLog.wrap(tx, "tx")
+	}
+	return p.queryLog.wrap(p.Master, "master")
+}
+
+// Reader queryer of reads, the transaction of ctx, otherwise the slave unless read-your-writes is
+// requested or the slave is unhealthy or lagging
+func (p PostgresCollection) Reader(ctx context.Context) Queryer {
+	if tx, ok := TxFromContext(ctx); ok {
+		return p.queryLog.wrap(tx, "tx")
 	}
+	if IsReadYourWrites(ctx) || !p.ReplicaHealthy() {
+		return p.queryLog.wrap(p.Master, "master")
+	}
+	return p.queryLog.wrap(p.Slave, "slave")
+}
+
+// ReplicaHealthy whether reads can be served by the slave
+func (p PostgresCollection) ReplicaHealthy() bool {
+	return p.Slave != nil && p.replica != nil && p.replica.Healthy()
+}
+
+// ReplicaLag last lag of the slave measured by the monitor
+func (p PostgresCollection) ReplicaLag() time.Duration {
+	if p.replica == nil {
+		return 0
+	}
+	return p.replica.Lag()
+}
+
+// Close stop the replica monitor and close the connections
+func (p PostgresCollection) Close() error {
+	if p.replica != nil {
+		p.replica.Stop()
+	}
+	if p.Slave != nil {
+		if err := p.Slave.Close(); err != nil {
+			log.Error().Msgf("error when close slave, err: %v", err)
+		}
+	}
+	return p.Master.Close()
+}
+
+// WithReadYourWrites send the reads of ctx to the master, use it when a read must see a write
+// made just before (e.g. read-modify-write, uniqueness checks)
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesContextKey{}, true)
+}
+
+// IsReadYourWrites whether the reads of ctx must go to the master
+func IsReadYourWrites(ctx context.Context) bool {
+	value, _ := ctx.Value(readYourWritesContextKey{}).(bool)
+	return value
 }
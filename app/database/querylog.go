@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DEFAULT_SLOW_QUERY_THRESHOLD used when DB_SLOW_QUERY_THRESHOLD is not set
+var DEFAULT_SLOW_QUERY_THRESHOLD = 200 * time.Millisecond
+
+var (
+	fingerprintString = regexp.MustCompile(`'(?:[^']|'')*'`)
+	fingerprintNumber = regexp.MustCompile(`(^|[^\w$])\d+(?:\.\d+)?\b`)
+	fingerprintSpace  = regexp.MustCompile(`\s+`)
+)
+
+type (
+	// queryLogger log and trace every query of a Queryer
+	queryLogger struct {
+		slowThreshold time.Duration
+		// logAll log every query at debug level, otherwise only the slow ones and the errors
+		logAll bool
+	}
+
+	// loggedQueryer Queryer logging and tracing the queries of the wrapped one, repositories get it
+	// from Reader and Writer without knowing
+	loggedQueryer struct {
+		Queryer
+		logger *queryLogger
+		target string
+	}
+)
+
+func newQueryLogger(config model.Datasource) *queryLogger {
+	threshold := time.Duration(config.SlowQueryThreshold) * time.Millisecond
+	if threshold <= 0 {
+		threshold = DEFAULT_SLOW_QUERY_THRESHOLD
+	}
+	return &queryLogger{slowThreshold: threshold, logAll: config.LogQueries}
+}
+
+// wrap q so its queries are logged, target is master, slave or tx
+func (l *queryLogger) wrap(q Queryer, target string) Queryer {
+	if l == nil {
+		return q
+	}
+	return loggedQueryer{Queryer: q, logger: l, target: target}
+}
+
+// observe start the span of query, the returned func finishes it and writes the log
+func (q loggedQueryer) observe(ctx context.Context, operation, query string) (context.Context, func(rows int64, err error)) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "sql."+operation)
+	ext.DBType.Set(span, "sql")
+	ext.DBStatement.Set(span, query)
+	span.SetTag("db.target", q.target)
+	start := time.Now()
+
+	return ctx, func(rows int64, err error) {
+		duration := time.Since(start)
+		if err != nil && err != sql.ErrNoRows {
+			ext.Error.Set(span, true)
+			span.SetTag("error.message", err.Error())
+		}
+		span.Finish()
+
+		// the logger of ctx carries the request id
+		slow := duration >= q.logger.slowThreshold
+		var event *zerolog.Event
+		switch {
+		case err != nil && err != sql.ErrNoRows:
+			event = log.Ctx(ctx).Error().Err(err)
+		case slow:
+			event = log.Ctx(ctx).Warn()
+		case q.logger.logAll:
+			event = log.Ctx(ctx).Debug()
+		default:
+			return
+		}
+
+		event.
+			Str("query", Fingerprint(query)).
+			Str("target", q.target).
+			Str("operation", operation).
+			Dur("duration", duration).
+			Int64("rows", rows).
+			Bool("slow", slow).
+			Msg("sql query")
+	}
+}
+
+func (q loggedQueryer) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, done := q.observe(ctx, "get", query)
+	err := q.Queryer.GetContext(ctx, dest, query, args...)
+	var rows int64
+	if err == nil {
+		rows = 1
+	}
+	done(rows, err)
+	return err
+}
+
+func (q loggedQueryer) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, done := q.observe(ctx, "select", query)
+	err := q.Queryer.SelectContext(ctx, dest, query, args...)
+	done(sliceLen(dest), err)
+	return err
+}
+
+func (q loggedQueryer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, done := q.observe(ctx, "exec", query)
+	res, err := q.Queryer.ExecContext(ctx, query, args...)
+	done(rowsAffected(res), err)
+	return res, err
+}
+
+func (q loggedQueryer) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	ctx, done := q.observe(ctx, "exec", query)
+	res, err := q.Queryer.NamedExecContext(ctx, query, arg)
+	done(rowsAffected(res), err)
+	return res, err
+}
+
+// QueryContext the rows are read by the caller so they are logged as -1
+func (q loggedQueryer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, done := q.observe(ctx, "query", query)
+	rows, err := q.Queryer.QueryContext(ctx, query, args...)
+	done(-1, err)
+	return rows, err
+}
+
+func (q loggedQueryer) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	ctx, done := q.observe(ctx, "query", query)
+	rows, err := q.Queryer.QueryxContext(ctx, query, args...)
+	done(-1, err)
+	return rows, err
+}
+
+func (q loggedQueryer) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	ctx, done := q.observe(ctx, "query_row", query)
+	row := q.Queryer.QueryRowxContext(ctx, query, args...)
+	done(1, row.Err())
+	return row
+}
+
+// Fingerprint query with its literals replaced by ? and the whitespace collapsed, queries differing
+// only by their values share a fingerprint
+func Fingerprint(query string) string {
+	query = fingerprintString.ReplaceAllString(query, "?")
+	query = fingerprintNumber.ReplaceAllString(query, "${1}?")
+	return strings.TrimSpace(fingerprintSpace.ReplaceAllString(query, " "))
+}
+
+func sliceLen(dest interface{}) int64 {
+	value := reflect.Indirect(reflect.ValueOf(dest))
+	if value.Kind() != reflect.Slice {
+		return 0
+	}
+	return int64(value.Len())
+}
+
+func rowsAffected(res sql.Result) int64 {
+	if res == nil {
+		return 0
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return rows
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM user WHERE id = $1", Fingerprint("SELECT *\n\t\tFROM user\n\t\tWHERE id = $1"))
+	assert.Equal(t, "SELECT * FROM user WHERE email = ? AND age > ? LIMIT ?", Fingerprint("SELECT * FROM user WHERE email = 'a''b@c.d' AND age > 17 LIMIT 10"))
+	assert.Equal(t, "SAVEPOINT sp_1", Fingerprint("SAVEPOINT sp_1"))
+}
+
+func TestQueryLoggerWrap(t *testing.T) {
+	master := &sqlx.DB{}
+	ctx := context.Background()
+
+	p := PostgresCollection{Master: master, queryLog: &queryLogger{}}
+	q, ok := p.Writer(ctx).(loggedQueryer)
+	assert.True(t, ok)
+	assert.Same(t, master, q.Queryer)
+	assert.Equal(t, "master", q.target)
+
+	tx := &sqlx.Tx{}
+	q, ok = p.Reader(ContextWithTx(ctx, tx)).(loggedQueryer)
+	assert.True(t, ok)
+	assert.Same(t, tx, q.Queryer)
+	assert.Equal(t, "tx", q.target)
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// DEFAULT_REDIS_PORT used when REDIS_PORT is not set
+var DEFAULT_REDIS_PORT = "6379"
+
+// NewRedisClient client of the configured redis, nil when REDIS_HOST is not set. The client
+// connects lazily so a redis down at startup does not stop the app.
+func NewRedisClient(config model.Config) *redis.Client {
+	if config.Redis.Host == "" {
+		return nil
+	}
+
+	port := config.Redis.Port
+	if port == "" {
+		port = DEFAULT_REDIS_PORT
+	}
+	options := &redis.Options{
+		Addr:     net.JoinHostPort(config.Redis.Host, port),
+		Password: config.Redis.Password,
+		DB:       config.Redis.DB,
+	}
+	if config.Redis.TLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: config.Redis.Host}
+	}
+	return redis.NewClient(options)
+}
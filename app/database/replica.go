@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// replicaLagQuery seconds the slave is behind the master, 0 when everything received is replayed
+// (an idle master does not make the slave lag) or when the database is not a replica
+const replicaLagQuery = `SELECT CASE
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// replicaMonitor check the health and lag of the slave in the background
+type replicaMonitor struct {
+	db       *sqlx.DB
+	maxLag   time.Duration
+	interval time.Duration
+
+	healthy atomic.Bool
+	lag     atomic.Int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newReplicaMonitor(db *sqlx.DB, maxLag, interval time.Duration) *replicaMonitor {
+	m := &replicaMonitor{
+		db:       db,
+		maxLag:   maxLag,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+
+	// first check before serving so a dead slave is not used right after startup
+	m.check()
+	go m.run()
+
+	return m
+}
+
+// Healthy whether the slave answered the last check within the max lag
+func (m *replicaMonitor) Healthy() bool {
+	return m.healthy.Load()
+}
+
+// Lag last measured lag
+func (m *replicaMonitor) Lag() time.Duration {
+	return time.Duration(m.lag.Load())
+}
+
+// Stop the background checks
+func (m *replicaMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+func (m *replicaMonitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+func (m *replicaMonitor) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+	defer cancel()
+
+	healthy := true
+	var seconds float64
+	if err := m.db.GetContext(ctx, &seconds, replicaLagQuery); err != nil {
+		log.Error().Msgf("error when check slave, reads go to the master, err: %v", err)
+		healthy = false
+	}
+
+	lag := time.Duration(seconds * float64(time.Second))
+	m.lag.Store(int64(lag))
+	if healthy && lag > m.maxLag {
+		log.Warn().Msgf("slave lags %s behind (max %s), reads go to the master", lag, m.maxLag)
+		healthy = false
+	}
+
+	if previous := m.healthy.Swap(healthy); previous != healthy && healthy {
+		log.Info().Msgf("slave is healthy again, lag: %s", lag)
+	}
+}
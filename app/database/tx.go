@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// MAX_TX_ATTEMPTS a transaction failing on a serialization failure or deadlock is run again up to this many times
+	MAX_TX_ATTEMPTS = 3
+	// TX_RETRY_BACKOFF base wait before running a transaction again, multiplied by the attempt and jittered
+	TX_RETRY_BACKOFF = 20 * time.Millisecond
+
+	pqSerializationFailure pq.ErrorCode = "40001"
+	pqDeadlockDetected     pq.ErrorCode = "40P01"
+)
+
+type (
+	// TxManager unit of work spanning multiple repositories
+	TxManager interface {
+		// WithinTx run fn in a transaction bound to the ctx passed to fn, repositories using Reader and
+		// Writer of that ctx join it. A nested call runs in a savepoint of the outer transaction.
+		WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+		// WithinTxIsolation WithinTx with the isolation level of a new transaction, ignored when nested
+		WithinTxIsolation(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) error
+	}
+
+	// TxManagerImpl implementation on the master
+	TxManagerImpl struct {
+		postgresCollection PostgresCollection
+	}
+
+	txContextKey             struct{}
+	savepointDepthContextKey struct{}
+)
+
+// NewTxManager initialize transaction manager
+func NewTxManager(postgresCollection PostgresCollection) TxManager {
+	return TxManagerImpl{postgresCollection: postgresCollection}
+}
+
+// WithinTx run fn in a read committed transaction
+func (m TxManagerImpl) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxIsolation(ctx, sql.LevelDefault, fn)
+}
+
+// WithinTxIsolation run fn in a transaction, the whole transaction is run again on a serialization
+// failure or deadlock so fn must not have side effects outside the database
+func (m TxManagerImpl) WithinTxIsolation(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		// the outer transaction is the one retried
+		return withinSavepoint(ctx, tx, fn)
+	}
+
+	var err error
+	for attempt := 1; attempt <= MAX_TX_ATTEMPTS; attempt++ {
+		err = m.run(ctx, isolation, fn)
+		if err == nil || !IsRetryableTxError(err) || attempt == MAX_TX_ATTEMPTS {
+			return err
+		}
+
+		wait := time.Duration(attempt)*TX_RETRY_BACKOFF + time.Duration(rand.Int63n(int64(TX_RETRY_BACKOFF)))
+		log.Ctx(ctx).Warn().Msgf("transaction failed, retrying in %s, attempt: %d, err: %v", wait, attempt, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	return err
+}
+
+func (m TxManagerImpl) run(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) (err error) {
+	tx, err := m.postgresCollection.Master.BeginTxx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Ctx(ctx).Error().Msgf("error when rollback transaction, err: %v", rbErr)
+			}
+		}
+	}()
+
+	if err = fn(ContextWithTx(ctx, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withinSavepoint run fn in a savepoint, only the work of fn is rolled back when it fails so the
+// outer transaction can go on (e.g. retry an insert after a unique violation)
+func withinSavepoint(ctx context.Context, tx *sqlx.Tx, fn func(ctx context.Context) error) (err error) {
+	depth, _ := ctx.Value(savepointDepthContextKey{}).(int)
+	depth++
+	savepoint := fmt.Sprintf("sp_%d", depth)
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+savepoint)
+			panic(p)
+		}
+		if err != nil {
+			if _, rbErr := tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+				log.Ctx(ctx).Error().Msgf("error when rollback to savepoint %s, err: %v", savepoint, rbErr)
+			}
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, savepointDepthContextKey{}, depth)); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint)
+	return err
+}
+
+// ContextWithTx bind tx to ctx, Reader and Writer of ctx then return tx
+func ContextWithTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext transaction bound to ctx
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sqlx.Tx)
+	return tx, ok && tx != nil
+}
+
+// IsRetryableTxError whether the transaction failed on a serialization failure or deadlock and
+// can succeed when run again
+func IsRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+}
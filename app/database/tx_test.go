@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	assert.True(t, IsRetryableTxError(&pq.Error{Code: "40001"}))
+	assert.True(t, IsRetryableTxError(fmt.Errorf("update user: %w", &pq.Error{Code: "40P01"})))
+	assert.False(t, IsRetryableTxError(&pq.Error{Code: "23505"}))
+	assert.False(t, IsRetryableTxError(errors.New("40001")))
+	assert.False(t, IsRetryableTxError(nil))
+}
+
+func TestRouting(t *testing.T) {
+	master, slave := &sqlx.DB{}, &sqlx.DB{}
+	ctx := context.Background()
+
+	// no slave
+	p := PostgresCollection{Master: master}
+	assert.Same(t, master, p.Reader(ctx))
+	assert.Same(t, master, p.Writer(ctx))
+
+	// unhealthy slave
+	p = PostgresCollection{Master: master, Slave: slave, replica: &replicaMonitor{}}
+	assert.Same(t, master, p.Reader(ctx))
+
+	p.replica.healthy.Store(true)
+	assert.Same(t, slave, p.Reader(ctx))
+	assert.Same(t, master, p.Reader(WithReadYourWrites(ctx)))
+	assert.Same(t, master, p.Writer(ctx))
+
+	tx := &sqlx.Tx{}
+	txCtx := ContextWithTx(ctx, tx)
+	assert.Same(t, tx, p.Reader(txCtx))
+	assert.Same(t, tx, p.Writer(txCtx))
+}
@@ -6,15 +6,16 @@ import (
 	"github.com/erwinwahyura/go-boilerplate/app/model"
 	"github.com/erwinwahyura/go-boilerplate/app/service/healthcheck"
 	"github.com/erwinwahyura/go-boilerplate/utils"
-
-	// "github.com/erwinwahyura/go-boilerplate/utils/jaegerutil"
 	"github.com/rs/zerolog/log"
 )
 
 type (
 	// HealthHandler controller
 	HealthHandler interface {
-		Check(w http.ResponseWriter, r *http.Request)
+		Live(w http.ResponseWriter, r *http.Request)
+		Ready(w http.ResponseWriter, r *http.Request)
+		History(w http.ResponseWriter, r *http.Request)
+		PoolStats(w http.ResponseWriter, r *http.Request)
 	}
 
 	// HealthHandlerImpl health controller
@@ -28,26 +29,74 @@ func NewHealthHandler(h healthcheck.HealthService) HealthHandler {
 	return &HealthHandlerImpl{healthService: h}
 }
 
-// Check godoc
-// @Summary Health Check
-// @Description Health Check
+// Live godoc
+// @Summary Liveness
+// @Description The process is running, the dependencies are not checked
 // @Tags Health
-// @Accept json
 // @Produce json
-// @Success 200 {object} model.BaseResponse
+// @Success 200 {object} model.BaseResponse{data=model.HealthCheckResponse}
+// @Router /livez [get]
 // @Router /healthcheck [get]
-func (h *HealthHandlerImpl) Check(w http.ResponseWriter, r *http.Request) {
-	// span, _ := jaegerutil.StartSpan(r.Context(), utils.GetCurrentFunctionName())
-	// defer span.Finish()
-
-	var err error
-	// defer jaegerutil.SetErrorSpan(span, time.Now(), err)
+func (h *HealthHandlerImpl) Live(w http.ResponseWriter, r *http.Request) {
+	data, err := h.healthService.Live(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when healthService.Live(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), data, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
 
-	data, err := h.healthService.Check(r.Context())
+// Ready godoc
+// @Summary Readiness
+// @Description Last status and latency of every dependency, 503 when a critical one is down. The dependencies are checked in the background every HEALTH_CHECK_INTERVAL
+// @Tags Health
+// @Produce json
+// @Success 200 {object} model.BaseResponse{data=model.HealthCheckResponse}
+// @Failure 503 {object} model.BaseResponse{data=model.HealthCheckResponse}
+// @Router /readyz [get]
+func (h *HealthHandlerImpl) Ready(w http.ResponseWriter, r *http.Request) {
+	data, err := h.healthService.Ready(r.Context())
 	if err != nil {
-		log.Error().Msgf("error when healthService.Check(), err: %v", err)
+		log.Ctx(r.Context()).Error().Msgf("error when healthService.Ready(), err: %v", err)
 		model.MapBaseResponse(w, r, err.Error(), data, nil, err)
 		return
 	}
 	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
 }
+
+// History godoc
+// @Summary Health Check History
+// @Description Last results of every dependency check newest first, staff only
+// @Tags Health
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.BaseResponse{data=[]model.HealthCheckHistory}
+// @Router /admin/health/history [get]
+func (h *HealthHandlerImpl) History(w http.ResponseWriter, r *http.Request) {
+	data, err := h.healthService.History(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when healthService.History(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// PoolStats godoc
+// @Summary Database Pool Stats
+// @Description Connection pools of the postgres master and slave and the mongodb log, staff only
+// @Tags Health
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.BaseResponse{data=model.DatabasePoolStatsResponse}
+// @Router /admin/database/pools [get]
+func (h *HealthHandlerImpl) PoolStats(w http.ResponseWriter, r *http.Request) {
+	data, err := h.healthService.PoolStats(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when healthService.PoolStats(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/service/logs"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// LogHandler controller
+	LogHandler interface {
+		SearchHTTPLogs(w http.ResponseWriter, r *http.Request)
+	}
+
+	// LogHandlerImpl log controller
+	LogHandlerImpl struct {
+		logService logs.LogService
+	}
+)
+
+// NewLogHandler initialize log controller
+func NewLogHandler(l logs.LogService) LogHandler {
+	return &LogHandlerImpl{logService: l}
+}
+
+// SearchHTTPLogs godoc
+// @Summary Search HTTP Logs
+// @Description Search the request logs, staff only
+// @Tags Log
+// @Produce json
+// @Security BearerAuth
+// @Param request_id query string false "request id"
+// @Param uri query string false "uri prefix, e.g. /api/v1/me"
+// @Param status query int false "response status"
+// @Param page query int false "page"
+// @Param size query int false "size"
+// @Success 200 {object} model.BaseResponse{data=[]model.LogHTTP,meta=model.PaginationMeta}
+// @Router /admin/logs/http [get]
+func (h *LogHandlerImpl) SearchHTTPLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := model.LogHTTPFilter{
+		RequestID: query.Get("request_id"),
+		URI:       query.Get("uri"),
+		Status:    utils.ConvertStrToInt(query.Get("status"), 0),
+	}
+	page := utils.ConvertStrToInt(query.Get("page"), 1)
+	size := utils.ConvertStrToInt(query.Get("size"), model.DEFAULT_PAGINATION_SIZE)
+
+	data, meta, err := h.logService.SearchHTTPLogs(r.Context(), filter, page, size)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when logService.SearchHTTPLogs(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, meta, nil)
+}
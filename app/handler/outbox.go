@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/service/outbox"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// OutboxHandler controller
+	OutboxHandler interface {
+		ListDeadEvents(w http.ResponseWriter, r *http.Request)
+		RequeueEvent(w http.ResponseWriter, r *http.Request)
+	}
+
+	// OutboxHandlerImpl outbox controller
+	OutboxHandlerImpl struct {
+		outboxService outbox.OutboxService
+	}
+)
+
+// NewOutboxHandler initialize outbox controller
+func NewOutboxHandler(o outbox.OutboxService) OutboxHandler {
+	return &OutboxHandlerImpl{outboxService: o}
+}
+
+// ListDeadEvents godoc
+// @Summary Dead Outbox Events
+// @Description Domain events that failed to publish OUTBOX_MAX_ATTEMPTS times, newest first
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "page, default 1"
+// @Param size query int false "size, default 20"
+// @Success 200 {object} model.BaseResponse{data=[]model.OutboxEvent,meta=model.PaginationMeta}
+// @Router /admin/outbox/dead [get]
+func (h *OutboxHandlerImpl) ListDeadEvents(w http.ResponseWriter, r *http.Request) {
+	page := utils.ConvertStrToInt(r.URL.Query().Get("page"), 1)
+	size := utils.ConvertStrToInt(r.URL.Query().Get("size"), model.DEFAULT_PAGINATION_SIZE)
+
+	data, meta, err := h.outboxService.ListDeadEvents(r.Context(), page, size)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when outboxService.ListDeadEvents(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, meta, nil)
+}
+
+// RequeueEvent godoc
+// @Summary Requeue Outbox Event
+// @Description Set a dead event pending again so the relay publishes it
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "event id"
+// @Success 200 {object} model.BaseResponse
+// @Router /admin/outbox/{id}/requeue [post]
+func (h *OutboxHandlerImpl) RequeueEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+
+	if err := h.outboxService.RequeueEvent(r.Context(), id); err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when outboxService.RequeueEvent(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, nil, nil, nil)
+}
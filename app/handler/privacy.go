@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/service/privacy"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// PrivacyHandler controller
+	PrivacyHandler interface {
+		RequestExport(w http.ResponseWriter, r *http.Request)
+		DownloadExport(w http.ResponseWriter, r *http.Request)
+		RequestErasure(w http.ResponseWriter, r *http.Request)
+		GetJob(w http.ResponseWriter, r *http.Request)
+	}
+
+	// PrivacyHandlerImpl privacy controller
+	PrivacyHandlerImpl struct {
+		privacyService privacy.PrivacyService
+	}
+)
+
+// NewPrivacyHandler initialize privacy controller
+func NewPrivacyHandler(p privacy.PrivacyService) PrivacyHandler {
+	return &PrivacyHandlerImpl{privacyService: p}
+}
+
+// RequestExport godoc
+// @Summary Export Personal Data
+// @Description Queue building a ZIP of the profile, history and uploaded files, an export that is still pending or running is returned instead. Poll the job until it is done
+// @Tags Privacy
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.BaseResponse{data=model.PrivacyJob}
+// @Router /api/v1/me/export [get]
+func (h *PrivacyHandlerImpl) RequestExport(w http.ResponseWriter, r *http.Request) {
+	data, err := h.privacyService.RequestExport(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when privacyService.RequestExport(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// DownloadExport godoc
+// @Summary Download Personal Data
+// @Description Download the ZIP of a finished export job
+// @Tags Privacy
+// @Produce application/zip
+// @Security BearerAuth
+// @Param job_id path string true "job id"
+// @Success 200 {file} file
+// @Router /api/v1/me/export/{job_id}/download [get]
+func (h *PrivacyHandlerImpl) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+	archive, err := h.privacyService.OpenExport(r.Context(), jobID)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when privacyService.OpenExport(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=personal-data-%s.zip", jobID))
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, archive); err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when write export archive, err: %v", err)
+	}
+}
+
+// RequestErasure godoc
+// @Summary Erase Personal Data
+// @Description Start anonymising the account and removing the identity image, poll the job until it is done.
+// @Description If-Match must be the ETag of GET /api/v1/me.
+// @Tags Privacy
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string true "ETag of the profile"
+// @Success 200 {object} model.BaseResponse{data=model.PrivacyJob}
+// @Failure 409 {object} model.BaseResponse "changed since it was read"
+// @Failure 428 {object} model.BaseResponse "If-Match is missing"
+// @Router /api/v1/me [delete]
+func (h *PrivacyHandlerImpl) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	ifMatch, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := h.privacyService.RequestErasure(r.Context(), ifMatch)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when privacyService.RequestErasure(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// GetJob godoc
+// @Summary Privacy Job Status
+// @Description Poll the status of an export or erasure job
+// @Tags Privacy
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path string true "job id"
+// @Success 200 {object} model.BaseResponse{data=model.PrivacyJob}
+// @Router /api/v1/me/jobs/{job_id} [get]
+func (h *PrivacyHandlerImpl) GetJob(w http.ResponseWriter, r *http.Request) {
+	data, err := h.privacyService.GetJob(r.Context(), chi.URLParam(r, "job_id"))
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when privacyService.GetJob(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
 	"github.com/erwinwahyura/go-boilerplate/app/service/user"
 	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/httputil"
+	"github.com/go-chi/chi/v5"
 
 	// "github.com/erwinwahyura/go-boilerplate/utils/jaegerutil"
 	"github.com/rs/zerolog/log"
@@ -15,6 +21,15 @@ type (
 	// UserHandler controller
 	UserHandler interface {
 		CreateUser(w http.ResponseWriter, r *http.Request)
+		GetProfile(w http.ResponseWriter, r *http.Request)
+		UpdateProfile(w http.ResponseWriter, r *http.Request)
+		GetUser(w http.ResponseWriter, r *http.Request)
+		UpdateUser(w http.ResponseWriter, r *http.Request)
+		ImportUsers(w http.ResponseWriter, r *http.Request)
+		ExportUsers(w http.ResponseWriter, r *http.Request)
+		RotateEncryptionKeys(w http.ResponseWriter, r *http.Request)
+		GetEncryptionKeyRotation(w http.ResponseWriter, r *http.Request)
+		GetUserHistory(w http.ResponseWriter, r *http.Request)
 	}
 
 	// UserHandlerImpl health controller
@@ -28,14 +43,18 @@ func NewUserHandler(h user.UserService) UserHandler {
 	return &UserHandlerImpl{userService: h}
 }
 
-// Check godoc
-// @Summary Health Check
-// @Description Health Check
-// @Tags Health
+// CreateUser godoc
+// @Summary Create User
+// @Description Register a user, the username is generated from the email when empty. The user is active, not verified and has no privileges.
+// @Tags User
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "unique per user creation, its retries get the same response"
+// @Param request body model.RegisterUserRequest true "user"
 // @Success 200 {object} model.BaseResponse
-// @Router /healthcheck [get]
+// @Failure 409 {object} model.BaseResponse "a request with the Idempotency-Key is in progress"
+// @Failure 422 {object} model.BaseResponse "the Idempotency-Key was used with another request"
+// @Router /api/v1/user/create_user [post]
 func (h *UserHandlerImpl) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// span, _ := jaegerutil.StartSpan(r.Context(), utils.GetCurrentFunctionName())
 	// defer span.Finish()
@@ -43,11 +62,303 @@ func (h *UserHandlerImpl) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var err error
 	// defer jaegerutil.SetErrorSpan(span, time.Now(), err)
 
-	data, err := h.userService.CreateUser(r.Context(), model.UserRequest{})
+	var req model.RegisterUserRequest
+	if err = httputil.RequestBodyToStruct(w, r.Body, &req); err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when decode create user request, err: %v", err)
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+
+	data, err := h.userService.CreateUser(r.Context(), req)
 	if err != nil {
-		log.Error().Msgf("error when healthService.Check(), err: %v", err)
+		log.Ctx(r.Context()).Error().Msgf("error when userService.CreateUser(), err: %v", err)
 		model.MapBaseResponse(w, r, err.Error(), data, nil, err)
 		return
 	}
 	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
 }
+
+// GetProfile godoc
+// @Summary Get Profile
+// @Description Profile of the logged in user including the MyValue points balance
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.BaseResponse{data=model.ProfileResponse}
+// @Router /api/v1/me [get]
+func (h *UserHandlerImpl) GetProfile(w http.ResponseWriter, r *http.Request) {
+	data, err := h.userService.GetProfile(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.GetProfile(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	w.Header().Set("ETag", data.ETag)
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// UpdateProfile godoc
+// @Summary Update Profile
+// @Description Self-service edit of the logged in user's profile, If-Match must be the ETag of GET /api/v1/me
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string true "ETag of the profile"
+// @Param request body model.UpdateProfileRequest true "fields to update"
+// @Success 200 {object} model.BaseResponse{data=model.ProfileResponse}
+// @Failure 409 {object} model.BaseResponse "changed since it was read"
+// @Failure 428 {object} model.BaseResponse "If-Match is missing"
+// @Router /api/v1/me [patch]
+func (h *UserHandlerImpl) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	ifMatch, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateProfileRequest
+	if err := httputil.RequestBodyToStruct(w, r.Body, &req); err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when decode update profile request, err: %v", err)
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+
+	data, err := h.userService.UpdateProfile(r.Context(), req, ifMatch)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.UpdateProfile(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	w.Header().Set("ETag", data.ETag)
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// GetUser godoc
+// @Summary Get User
+// @Description User by id, the ETag header is the If-Match of PATCH /admin/users/{id}
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "user id"
+// @Success 200 {object} model.BaseResponse{data=model.UserResponse}
+// @Router /admin/users/{id} [get]
+func (h *UserHandlerImpl) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+
+	data, err := h.userService.GetUser(r.Context(), id)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.GetUser(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	w.Header().Set("ETag", data.ETag)
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// UpdateUser godoc
+// @Summary Update User
+// @Description Staff edit of a user, If-Match must be the ETag of GET /admin/users/{id}. Only a superuser edits a superuser or sets is_staff.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "user id"
+// @Param If-Match header string true "ETag of the user"
+// @Param request body model.UpdateUserRequest true "fields to update"
+// @Success 200 {object} model.BaseResponse{data=model.UserResponse}
+// @Failure 403 {object} model.BaseResponse "a superuser or is_staff edited by a non-superuser"
+// @Failure 409 {object} model.BaseResponse "changed since it was read"
+// @Failure 428 {object} model.BaseResponse "If-Match is missing"
+// @Router /admin/users/{id} [patch]
+func (h *UserHandlerImpl) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+	ifMatch, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateUserRequest
+	if err := httputil.RequestBodyToStruct(w, r.Body, &req); err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when decode update user request, err: %v", err)
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+
+	data, err := h.userService.UpdateUser(r.Context(), id, req, ifMatch)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.UpdateUser(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	w.Header().Set("ETag", data.ETag)
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// requireIfMatch If-Match of a conditional update, responds 428 when it is missing
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		model.MapBaseResponse(w, r, utils.ErrorPreconditionRequired.Error(), nil, nil, utils.ErrorPreconditionRequired)
+		return "", false
+	}
+	return ifMatch, true
+}
+
+// ImportUsers godoc
+// @Summary Import Users
+// @Description Bulk import users from csv or ndjson, use dry_run to only validate the file. is_superuser and is_staff are only imported by a superuser, the report details the first 1000 rows
+// @Tags Admin
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "csv or ndjson, default from Content-Type"
+// @Param dry_run query bool false "validate only, nothing is written"
+// @Param upsert query bool false "update the existing user with the same email"
+// @Success 200 {object} model.BaseResponse{data=model.ImportUserReport}
+// @Router /admin/users/import [post]
+func (h *UserHandlerImpl) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := model.ImportUserOptions{
+		Format: bulkFormat(query.Get("format"), r.Header.Get("Content-Type")),
+	}
+	opts.DryRun, _ = strconv.ParseBool(query.Get("dry_run"))
+	opts.Upsert, _ = strconv.ParseBool(query.Get("upsert"))
+
+	defer r.Body.Close()
+	data, err := h.userService.ImportUsers(r.Context(), r.Body, opts)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.ImportUsers(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), data, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// ExportUsers godoc
+// @Summary Export Users
+// @Description Stream the users matching the filter as csv or ndjson
+// @Tags Admin
+// @Produce text/csv,application/x-ndjson
+// @Security BearerAuth
+// @Param format query string false "csv (default) or ndjson"
+// @Param first_name query string false "first name contains"
+// @Param last_name query string false "last name contains"
+// @Param username query string false "username"
+// @Param email query string false "email"
+// @Success 200 {file} file
+// @Router /admin/users/export [get]
+func (h *UserHandlerImpl) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := bulkFormat(query.Get("format"), "")
+	if format == "" {
+		format = model.BULK_FORMAT_CSV
+	}
+	if format != model.BULK_FORMAT_CSV && format != model.BULK_FORMAT_NDJSON {
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+
+	filter := repository.UserRepositoryFilter{
+		FirstName: utils.ValueToPtr(query.Get("first_name")),
+		LastName:  utils.ValueToPtr(query.Get("last_name")),
+		Username:  utils.ValueToPtr(query.Get("username")),
+		Email:     utils.ValueToPtr(query.Get("email")),
+	}
+
+	contentType := "text/csv"
+	if format == model.BULK_FORMAT_NDJSON {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=users.%s", format))
+
+	// the status is already sent once the first row is written, errors can only be logged
+	err := h.userService.ExportUsers(r.Context(), httputil.NewFlushWriter(w), format, filter)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.ExportUsers(), err: %v", err)
+	}
+}
+
+// RotateEncryptionKeys godoc
+// @Summary Rotate Encryption Keys
+// @Description Start rewrapping the encrypted PII columns with the current master key and encrypting legacy plaintext rows in the background, poll the rotation until it is done
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.BaseResponse{data=model.EncryptionKeyRotation}
+// @Router /admin/users/encryption/rotate [post]
+func (h *UserHandlerImpl) RotateEncryptionKeys(w http.ResponseWriter, r *http.Request) {
+	data, err := h.userService.RotateEncryptionKeys(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.RotateEncryptionKeys(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// GetEncryptionKeyRotation godoc
+// @Summary Encryption Key Rotation Status
+// @Description Progress of the last rotation started on the instance serving the request
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.BaseResponse{data=model.EncryptionKeyRotation}
+// @Router /admin/users/encryption/rotate [get]
+func (h *UserHandlerImpl) GetEncryptionKeyRotation(w http.ResponseWriter, r *http.Request) {
+	data := h.userService.GetEncryptionKeyRotation(r.Context())
+	model.MapBaseResponse(w, r, utils.Success, data, nil, nil)
+}
+
+// GetUserHistory godoc
+// @Summary User History
+// @Description Who changed what on a user and when, newest first
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "user id"
+// @Param page query int false "page, default 1"
+// @Param size query int false "size, default 20"
+// @Success 200 {object} model.BaseResponse{data=[]model.UserHistory,meta=model.PaginationMeta}
+// @Router /admin/users/{id}/history [get]
+func (h *UserHandlerImpl) GetUserHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+		return
+	}
+	page := utils.ConvertStrToInt(r.URL.Query().Get("page"), 1)
+	size := utils.ConvertStrToInt(r.URL.Query().Get("size"), model.DEFAULT_PAGINATION_SIZE)
+
+	data, meta, err := h.userService.GetUserHistory(r.Context(), userID, page, size)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error when userService.GetUserHistory(), err: %v", err)
+		model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+		return
+	}
+	model.MapBaseResponse(w, r, utils.Success, data, meta, nil)
+}
+
+// bulkFormat resolve the bulk file format from the format param or the content type
+func bulkFormat(format, contentType string) string {
+	if format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return model.BULK_FORMAT_CSV
+	case "application/x-ndjson", "application/jsonl", "application/json-lines":
+		return model.BULK_FORMAT_NDJSON
+	}
+	return ""
+}
@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// Content encoding
+	ENCODING_GZIP = "gzip"
+	ENCODING_ZSTD = "zstd"
+)
+
+var (
+	// DEFAULT_COMPRESS_MIN_SIZE used when COMPRESS_MIN_SIZE is not set
+	DEFAULT_COMPRESS_MIN_SIZE = 1024
+
+	// compressEncodings supported encodings, the first one wins a tie of the Accept-Encoding weights
+	compressEncodings = []string{ENCODING_ZSTD, ENCODING_GZIP}
+
+	// compressContentTypes compressed media types, the others are usually compressed already
+	compressContentTypes = []string{"text/", "application/json", "application/xml", "application/javascript",
+		model.CONTENT_TYPE_MSGPACK, "image/svg+xml"}
+
+	gzipPool = sync.Pool{New: func() any {
+		writer, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return writer
+	}}
+	zstdPool = sync.Pool{New: func() any {
+		encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		return encoder
+	}}
+)
+
+type (
+	// encoder gzip.Writer and zstd.Encoder reused from their pool
+	encoder interface {
+		io.WriteCloser
+		Flush() error
+		Reset(w io.Writer)
+	}
+
+	// compressWriter buffer the response until it reaches the minimum size, then compress it when
+	// its content type is worth it
+	compressWriter struct {
+		http.ResponseWriter
+		method   string
+		encoding string
+		minSize  int
+
+		status   int
+		buf      []byte
+		decided  bool
+		encoder  encoder
+		released func()
+	}
+)
+
+// Compress the responses with zstd or gzip negotiated from Accept-Encoding, the responses smaller
+// than COMPRESS_MIN_SIZE are sent as they are
+func (m *GoMiddleware) Compress(next http.Handler) http.Handler {
+	if m.compressMinSize < 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, method: r.Method, encoding: encoding, minSize: m.compressMinSize}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding the supported encoding with the highest weight, empty when none is accepted
+func negotiateEncoding(acceptEncoding string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		weight := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			weight = q
+		}
+		weights[coding] = weight
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range compressEncodings {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush send the buffered response, a streaming response is compressed regardless of its size
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.minSize = 0
+		if err := cw.decide(); err != nil {
+			return
+		}
+	}
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close send what is left of the response and put the encoder back in its pool
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		// nothing was written, net/http sends the default response
+		if cw.status == 0 {
+			return nil
+		}
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	cw.released()
+	cw.encoder = nil
+	return err
+}
+
+// Unwrap for http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide whether the response is compressed, then write the header and the buffered body
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+	if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// sniffed here, net/http would sniff the compressed bytes
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if len(cw.buf) >= cw.minSize && cw.compressible() {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.encoder, cw.released = acquireEncoder(cw.encoding, cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// compressible a response with a body, not encoded yet and of a compressed content type
+func (cw *compressWriter) compressible() bool {
+	if cw.method == http.MethodHead || cw.status < http.StatusOK ||
+		cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	header := cw.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	for _, prefix := range compressContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(contentType, "+json")
+}
+
+// acquireEncoder encoder of encoding writing to w and the func putting it back in its pool
+func acquireEncoder(encoding string, w io.Writer) (encoder, func()) {
+	pool := &gzipPool
+	if encoding == ENCODING_ZSTD {
+		pool = &zstdPool
+	}
+
+	e := pool.Get().(encoder)
+	e.Reset(w)
+	return e, func() { pool.Put(e) }
+}
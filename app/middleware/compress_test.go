@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"identity":              "",
+		"gzip":                  ENCODING_GZIP,
+		"gzip, deflate, br":     ENCODING_GZIP,
+		"gzip, zstd":            ENCODING_ZSTD,
+		"zstd;q=0.5, gzip":      ENCODING_GZIP,
+		"zstd;q=0, gzip;q=0.1":  ENCODING_GZIP,
+		"*":                     ENCODING_ZSTD,
+		"*;q=0.2, zstd;q=0":     ENCODING_GZIP,
+		"gzip;q=0, deflate":     "",
+		"GZIP ;Q=0.8 , invalid": ENCODING_GZIP,
+	}
+	for acceptEncoding, expected := range tests {
+		assert.Equal(t, expected, negotiateEncoding(acceptEncoding), acceptEncoding)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	m := InitMiddleware(model.Config{Compression: model.Compression{MinSize: 500}}, nil, nil, nil, nil)
+	handler := m.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := 10
+		if r.URL.Query().Get("large") != "" {
+			size = 1000
+		}
+		model.MapBaseResponse(w, r, utils.Success, strings.Repeat("x", size), nil, nil)
+	}))
+	request := func(url, acceptEncoding, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) model.BaseResponse {
+		var res model.BaseResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res
+	}
+
+	t.Run("small response is not compressed", func(t *testing.T) {
+		w := request("/", "gzip, zstd", "")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+		assert.Equal(t, "xxxxxxxxxx", decode(t, w).Data)
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		w := request("/?large=1", "gzip", "")
+		require.Equal(t, ENCODING_GZIP, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, model.CONTENT_TYPE_JSON, w.Header().Get("Content-Type"))
+		reader, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		w.Body = bytes.NewBuffer(body)
+		assert.Len(t, decode(t, w).Data, 1000)
+	})
+
+	t.Run("zstd", func(t *testing.T) {
+		w := request("/?large=1", "gzip;q=0.5, zstd", "")
+		require.Equal(t, ENCODING_ZSTD, w.Header().Get("Content-Encoding"))
+		decoder, err := zstd.NewReader(w.Body)
+		require.NoError(t, err)
+		defer decoder.Close()
+		body, err := io.ReadAll(decoder)
+		require.NoError(t, err)
+		w.Body = bytes.NewBuffer(body)
+		assert.Len(t, decode(t, w).Data, 1000)
+	})
+
+	t.Run("json compact or pretty", func(t *testing.T) {
+		previous := model.CompactJSON
+		defer func() { model.CompactJSON = previous }()
+
+		model.CompactJSON = true
+		assert.NotContains(t, request("/", "", "").Body.String(), "\n")
+		assert.Contains(t, request("/?pretty=1", "", "").Body.String(), "\n    ")
+		model.CompactJSON = false
+		assert.Contains(t, request("/", "", "").Body.String(), "\n    ")
+	})
+
+	t.Run("msgpack", func(t *testing.T) {
+		w := request("/", "", "application/msgpack, application/json;q=0.9")
+		assert.Equal(t, model.CONTENT_TYPE_MSGPACK, w.Header().Get("Content-Type"))
+		var res map[string]interface{}
+		require.NoError(t, msgpack.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, utils.SUCCESS, res["code"])
+		assert.Equal(t, "xxxxxxxxxx", res["data"])
+		assert.IsType(t, int64(0), res["server_time"])
+
+		w = request("/", "", "application/msgpack;q=0, application/json")
+		assert.Equal(t, model.CONTENT_TYPE_JSON, w.Header().Get("Content-Type"))
+	})
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// maxIdempotencyKeyLength size of the idempotency_key column
+const maxIdempotencyKeyLength = 255
+
+var (
+	// DEFAULT_IDEMPOTENCY_TTL used when IDEMPOTENCY_TTL is not set
+	DEFAULT_IDEMPOTENCY_TTL = 24 * time.Hour
+	// DEFAULT_IDEMPOTENCY_LOCK_TIMEOUT used when IDEMPOTENCY_LOCK_TIMEOUT is not set
+	DEFAULT_IDEMPOTENCY_LOCK_TIMEOUT = time.Minute
+)
+
+// idempotencyRecorder keep the response to store it for the retries
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (ir *idempotencyRecorder) WriteHeader(status int) {
+	if ir.status == 0 {
+		ir.status = status
+	}
+	ir.ResponseWriter.WriteHeader(status)
+}
+
+func (ir *idempotencyRecorder) Write(p []byte) (int, error) {
+	if ir.status == 0 {
+		ir.status = http.StatusOK
+	}
+	ir.body.Write(p)
+	return ir.ResponseWriter.Write(p)
+}
+
+// Unwrap for http.ResponseController
+func (ir *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return ir.ResponseWriter
+}
+
+// Idempotency run a POST sent with an Idempotency-Key once. The retries of the same request get
+// the stored response, a retry while the first one is running gets idempotency_key_in_use and the
+// key sent with another method, path, body or response format gets idempotency_key_mismatch. A 5xx
+// response is not stored so the retry runs again. Keys are scoped to the authenticated user, use it
+// after Authenticate on the authenticated routes, and to the client ip on the public routes.
+func (m *GoMiddleware) Idempotency(next http.Handler) http.Handler {
+	if m.idempotencyRepo == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(constant.IdempotencyKey)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength || !printable(key) {
+			model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			model.MapBaseResponse(w, r, utils.ErrorBadRequest.Error(), nil, nil, utils.ErrorBadRequest)
+			return
+		}
+		r.Body = readCloser{Reader: bytes.NewReader(body), Closer: r.Body}
+
+		// the stored response is replayed as is, a retry asking for another format is another request
+		now := utils.TimeNow()
+		idempotency := model.IdempotencyRecord{
+			Scope:       "ip:" + m.clientIP(r),
+			Key:         key,
+			Fingerprint: utils.HashToStr(r.Method + " " + r.URL.RequestURI() + "\n" + model.ResponseContentType(r) + "\n" + string(body)),
+			LockedUntil: now.Add(m.idempotencyLockTimeout),
+			ExpiresAt:   now.Add(m.idempotencyTTL),
+		}
+		if appContext, ok := model.AppContextFromContext(r.Context()); ok && appContext.UID != "" {
+			idempotency.Scope = appContext.UID
+		}
+
+		stored, err := m.idempotencyRepo.Acquire(r.Context(), idempotency)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Msgf("error when idempotencyRepo.Acquire(), err: %v", err)
+			model.MapBaseResponse(w, r, utils.ErrorServiceUnavailable.Error(), nil, nil, utils.ErrorServiceUnavailable)
+			return
+		}
+		if stored != nil {
+			replayIdempotency(w, r, idempotency, *stored)
+			return
+		}
+
+		// the key is kept or released even when the client went away
+		ctx := context.WithoutCancel(r.Context())
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := m.idempotencyRepo.Release(ctx, idempotency.Scope, idempotency.Key, idempotency.Fingerprint); err != nil {
+				log.Ctx(ctx).Error().Msgf("error when idempotencyRepo.Release(), err: %v", err)
+			}
+		}()
+
+		recorder := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		idempotency.ResponseStatus = recorder.status
+		idempotency.ResponseContentType = w.Header().Get("Content-Type")
+		idempotency.ResponseBody = recorder.body.Bytes()
+		if err := m.idempotencyRepo.Complete(ctx, idempotency); err != nil {
+			log.Ctx(ctx).Error().Msgf("error when idempotencyRepo.Complete(), err: %v", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// replayIdempotency answer the request whose key is already stored
+func replayIdempotency(w http.ResponseWriter, r *http.Request, idempotency, stored model.IdempotencyRecord) {
+	switch {
+	case stored.Fingerprint != idempotency.Fingerprint:
+		model.MapBaseResponse(w, r, utils.ErrorIdempotencyKeyMismatch.Error(), nil, nil, utils.ErrorIdempotencyKeyMismatch)
+	case stored.Status != model.IDEMPOTENCY_STATUS_COMPLETED:
+		w.Header().Set(headerRetryAfter, "1")
+		model.MapBaseResponse(w, r, utils.ErrorIdempotencyKeyInUse.Error(), nil, nil, utils.ErrorIdempotencyKeyInUse)
+	default:
+		if stored.ResponseContentType != "" {
+			w.Header().Set("Content-Type", stored.ResponseContentType)
+		}
+		w.Header().Add("Vary", "Accept")
+		w.Header().Set(constant.IdempotentReplayed, "true")
+		w.WriteHeader(stored.ResponseStatus)
+		w.Write(stored.ResponseBody)
+	}
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/erwinwahyura/go-boilerplate/app/repository/memory"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	m := InitMiddleware(model.Config{}, nil, nil, nil, memory.NewIdempotencyRepository())
+
+	var calls atomic.Int64
+	status := http.StatusCreated
+	// wait is called by the handler when set, to keep a request running
+	var wait func()
+	handler := m.Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := calls.Add(1)
+		if wait != nil {
+			wait()
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d,"body":%s}`, call, body)
+	}))
+
+	requestFrom := func(remoteAddr, accept, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/user/create_user", strings.NewReader(body))
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Accept", accept)
+		r.Header.Set(constant.IdempotencyKey, key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	request := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/user/create_user", strings.NewReader(body))
+		r.Header.Set(constant.IdempotencyKey, key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	code := func(w *httptest.ResponseRecorder) string {
+		var res model.BaseResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res.Code
+	}
+
+	w := request("key-1", `{"email":"a@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"call":1,"body":{"email":"a@example.com"}}`, w.Body.String())
+
+	// the retry gets the stored response without running the handler
+	w = request("key-1", `{"email":"a@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get(constant.IdempotentReplayed))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"call":1,"body":{"email":"a@example.com"}}`, w.Body.String())
+	assert.Equal(t, int64(1), calls.Load())
+
+	// same key with another payload
+	w = request("key-1", `{"email":"b@example.com"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, utils.IDEMPOTENCY_KEY_MISMATCH, code(w))
+
+	// same key asking for another format
+	w = requestFrom("192.0.2.1:1234", model.CONTENT_TYPE_MSGPACK, "key-1", `{"email":"a@example.com"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// anonymous keys are scoped to the client ip
+	w = requestFrom("192.0.2.2:1234", "", "key-1", `{"email":"a@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"call":2,"body":{"email":"a@example.com"}}`, w.Body.String())
+
+	// a duplicate while the first request is running
+	started, release := make(chan struct{}), make(chan struct{})
+	wait = func() {
+		close(started)
+		<-release
+	}
+	done := make(chan int)
+	go func() { done <- request("key-2", `{}`).Code }()
+	<-started
+	wait = nil
+	w = request("key-2", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, utils.IDEMPOTENCY_KEY_IN_USE, code(w))
+	close(release)
+	assert.Equal(t, http.StatusCreated, <-done)
+
+	// a 5xx is not stored, the retry runs again
+	status = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, request("key-3", `{}`).Code)
+	status = http.StatusCreated
+	assert.Equal(t, http.StatusCreated, request("key-3", `{}`).Code)
+	assert.Equal(t, int64(5), calls.Load())
+
+	assert.Equal(t, http.StatusBadRequest, request("key with spaces", `{}`).Code)
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository/memory"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRequest(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = previous }()
+
+	logHTTPRepo := memory.NewLogHTTPRepository()
+	m := InitMiddleware(model.Config{AccessLog: model.AccessLog{BodyLimit: 70}}, nil, logHTTPRepo, nil, nil)
+
+	body := `{"email":"jane@example.com","password":"s3cret","identity_number":"3171","bio":"` + strings.Repeat("x", 100) + `"}`
+	var read string
+	handler := m.SetRequestID(m.LogRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the handler still gets the whole body
+		b, _ := io.ReadAll(r.Body)
+		read = string(b)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/user/create_user?token=abc", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer abc")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, body, read)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "http request", line["message"])
+	assert.Equal(t, float64(http.StatusCreated), line["status"])
+	assert.Equal(t, float64(8), line["size"])
+	assert.Equal(t, "/api/v1/user/create_user?token=%5Bredacted%5D", line["uri"])
+	assert.NotEmpty(t, line["request_id"])
+	assert.Contains(t, line, "duration")
+	assert.NotContains(t, buf.String(), "s3cret")
+
+	require.NoError(t, logHTTPRepo.Close(context.Background()))
+	logs, _, err := logHTTPRepo.Search(context.Background(), model.LogHTTPFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, http.StatusCreated, logs[0].Status)
+	assert.True(t, logs[0].BodyTruncated)
+	assert.Equal(t, `{"email":"jane@example.com","password":"[redacted]","identity_number":"[redacted]"`, logs[0].Body)
+	assert.Contains(t, logs[0].Header, `"Authorization":["[redacted]"]`)
+	assert.NotContains(t, logs[0].Header, "Bearer abc")
+}
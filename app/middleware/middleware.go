@@ -8,20 +8,35 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/erwinwahyura/go-boilerplate/app/model"
 	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
 	"github.com/erwinwahyura/go-boilerplate/utils"
 	"github.com/erwinwahyura/go-boilerplate/utils/jwt"
+	"github.com/erwinwahyura/go-boilerplate/utils/ratelimit"
+	"github.com/erwinwahyura/go-boilerplate/utils/redact"
 	"github.com/justinas/nosurf"
+	zlog "github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
 type contextKey string
 
+var (
+	// DEFAULT_REDACT_HEADERS always redacted, LOG_REDACT_HEADERS adds to them
+	DEFAULT_REDACT_HEADERS = []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrf-Token", constant.ApiKey}
+	// DEFAULT_REDACT_FIELDS always redacted, LOG_REDACT_FIELDS adds to them
+	DEFAULT_REDACT_FIELDS = []string{"password", "token", "secret", "identity_number", "otp", "pin"}
+	// DEFAULT_BODY_LIMIT used when LOG_BODY_LIMIT is not set
+	DEFAULT_BODY_LIMIT = 4096
+)
+
 const (
 	isAuthenticatedContextKey = contextKey("isAuthenticated")
 	uid                       = contextKey("uid")
@@ -34,33 +49,111 @@ const (
 
 type (
 
-	// LogHTTP to show fields of log
-	LogHTTP struct {
-		RequestID string `json:"request_id,omitempty"`
-		Method    string `json:"method,omitempty"`
-		URI       string `json:"uri,omitempty"`
-		IP        string `json:"ip,omitempty"`
-		RemoteIP  string `json:"remote_ip,omitempty"`
-		Host      string `json:"host,omitempty"`
-		Status    int    `json:"status,omitempty"`
-		Size      int64  `json:"size,omitempty"`
-		UserAgent string `json:"user_agent,omitempty"`
-		Header    string `json:"header,omitempty"`
-		Body      string `json:"body,omitempty"`
-	}
-
 	// GoMiddleware struct of middleware
 	GoMiddleware struct {
-		Config model.Config
-		// add dependecies to logging the log, perhaps store into mongodb?
+		Config      model.Config
+		userRepo    repository.UserRepository
+		logHTTPRepo repository.LogHTTPRepository
+		redact      redact.Rules
+		bodyLimit   int
+		rateLimiter ratelimit.Store
+		rateLimits  map[string]ratelimit.Limit
+
+		idempotencyRepo        repository.IdempotencyRepository
+		idempotencyTTL         time.Duration
+		idempotencyLockTimeout time.Duration
+
+		compressMinSize int
+	}
+
+	// responseRecorder keep the status and size of the response for the http log
+	responseRecorder struct {
+		http.ResponseWriter
+		status int
+		size   int64
+	}
+
+	readCloser struct {
+		io.Reader
+		io.Closer
 	}
 )
 
 // InitMiddleware will initialize the middleware handler
-func InitMiddleware(config model.Config) *GoMiddleware {
+func InitMiddleware(config model.Config, userRepo repository.UserRepository, logHTTPRepo repository.LogHTTPRepository,
+	rateLimiter ratelimit.Store, idempotencyRepo repository.IdempotencyRepository) *GoMiddleware {
+	// the configured ones are added to the defaults, a typo must not stop redacting a password
+	redactHeaders := append([]string{}, DEFAULT_REDACT_HEADERS...)
+	if config.AccessLog.RedactHeaders != "" {
+		redactHeaders = append(redactHeaders, strings.Split(config.AccessLog.RedactHeaders, ",")...)
+	}
+	redactFields := append([]string{}, DEFAULT_REDACT_FIELDS...)
+	if config.AccessLog.RedactFields != "" {
+		redactFields = append(redactFields, strings.Split(config.AccessLog.RedactFields, ",")...)
+	}
+	bodyLimit := config.AccessLog.BodyLimit
+	if bodyLimit == 0 {
+		bodyLimit = DEFAULT_BODY_LIMIT
+	}
+	if bodyLimit > model.MAX_LOG_HTTP_BODY_SIZE {
+		bodyLimit = model.MAX_LOG_HTTP_BODY_SIZE
+	}
+	compressMinSize := config.Compression.MinSize
+	if compressMinSize == 0 {
+		compressMinSize = DEFAULT_COMPRESS_MIN_SIZE
+	}
+	idempotencyTTL := time.Duration(config.Idempotency.TTL) * time.Hour
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = DEFAULT_IDEMPOTENCY_TTL
+	}
+	idempotencyLockTimeout := time.Duration(config.Idempotency.LockTimeout) * time.Second
+	if idempotencyLockTimeout <= 0 {
+		idempotencyLockTimeout = DEFAULT_IDEMPOTENCY_LOCK_TIMEOUT
+	}
+
 	return &GoMiddleware{
-		Config: config,
+		Config:      config,
+		userRepo:    userRepo,
+		logHTTPRepo: logHTTPRepo,
+		redact:      redact.New(redactHeaders, redactFields),
+		bodyLimit:   bodyLimit,
+		rateLimiter: rateLimiter,
+		rateLimits:  rateLimits(config.RateLimit),
+
+		idempotencyRepo:        idempotencyRepo,
+		idempotencyTTL:         idempotencyTTL,
+		idempotencyLockTimeout: idempotencyLockTimeout,
+
+		compressMinSize: compressMinSize,
+	}
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
 	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(p)
+	rr.size += int64(n)
+	return n, err
+}
+
+// Flush keep streaming responses working through the recorder
+func (rr *responseRecorder) Flush() {
+	if flusher, ok := rr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap for http.ResponseController
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
 }
 
 func (m *GoMiddleware) SecureHeaders(next http.Handler) http.Handler {
@@ -164,45 +257,137 @@ func (m *GoMiddleware) Authenticate(next http.Handler) http.Handler {
 			}
 			appContext := model.AppContext{
 				Context: r.Context(),
-				UID:     id,
-				Token:   tokenStr,
-				Issuer:  issuer,
+				MandatoryRequest: model.MandatoryRequest{
+					ChannelID: r.Header.Get(constant.ChannelID),
+					RequestID: r.Header.Get(constant.RequestID),
+				},
+				UID:    id,
+				Token:  tokenStr,
+				Issuer: issuer,
 			}
 
 			// Set App Context
-			r = r.WithContext(appContext)
+			r = r.WithContext(model.NewAppContext(appContext))
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (m *GoMiddleware) LogRequest(next http.Handler) http.Handler {
+// RequireStaff only let staff and superuser through, must be used after Authenticate
+func (m *GoMiddleware) RequireStaff(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestLog := MapLogRequest(w, r)
-		fmt.Println(requestLog)
+		appContext, ok := model.AppContextFromContext(r.Context())
+		if !ok {
+			model.MapBaseResponse(w, r, utils.ErrorUnauthorized.Error(), nil, nil, utils.ErrorUnauthorized)
+			return
+		}
+		id, err := appContext.UserID()
+		if err != nil {
+			model.MapBaseResponse(w, r, utils.ErrorUnauthorized.Error(), nil, nil, utils.ErrorUnauthorized)
+			return
+		}
+
+		user, err := m.userRepo.GetByID(r.Context(), id)
+		if err != nil {
+			if err == utils.ErrorNotFound {
+				err = utils.ErrorUnauthorized
+			}
+			model.MapBaseResponse(w, r, err.Error(), nil, nil, err)
+			return
+		}
+		if !user.IsActive || !(user.IsStaff || user.IsSuperUser) {
+			model.MapBaseResponse(w, r, utils.ErrorForbidden.Error(), nil, nil, utils.ErrorForbidden)
+			return
+		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-// MapLogRequest for map log request
-func MapLogRequest(w http.ResponseWriter, r *http.Request) string {
-	headerByte, _ := json.Marshal(r.Header)
+// LogRequest write one log line per request once it is served and store the request with its
+// response status into the log database
+func (m *GoMiddleware) LogRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logHTTP := MapLogHTTP(r, m.redact, m.bodyLimit)
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		logHTTP.Status = recorder.status
+		if logHTTP.Status == 0 {
+			logHTTP.Status = http.StatusOK
+		}
+		logHTTP.Size = recorder.size
+		duration := time.Since(start)
+		logHTTP.DurationMs = duration.Milliseconds()
+
+		event := zlog.Ctx(r.Context()).Info()
+		if logHTTP.Status >= http.StatusInternalServerError {
+			event = zlog.Ctx(r.Context()).Error()
+		}
+		event.
+			Str("method", logHTTP.Method).
+			Str("uri", logHTTP.URI).
+			Int("status", logHTTP.Status).
+			Int64("size", logHTTP.Size).
+			Dur("duration", duration).
+			Str("ip", logHTTP.IP).
+			Str("user_agent", logHTTP.UserAgent).
+			Msg("http request")
+
+		if m.logHTTPRepo != nil {
+			m.logHTTPRepo.Write(logHTTP)
+		}
+	})
+}
+
+// MapLogHTTP map the request into the http log with the headers, query and body redacted by rules.
+// At most bodyLimit bytes of the body are kept, the handler still reads the whole body.
+func MapLogHTTP(r *http.Request, rules redact.Rules, bodyLimit int) model.LogHTTP {
+	headerByte, _ := json.Marshal(rules.Header(r.Header))
 
-	// Read the content
-	var bodyBytes []byte
-	if r.Body != nil {
-		bodyBytes, _ = io.ReadAll(r.Body)
+	body, truncated := captureBody(r, bodyLimit)
+	if len(body) > 0 {
+		body = rules.Body(r.Header.Get("Content-Type"), body)
 	}
-	// Use the content
-	var req interface{}
-	json.Unmarshal(bodyBytes, &req)
-	bodyBytes, _ = json.Marshal(req)
 
-	// Restore the io.ReadCloser to its original state
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	return fmt.Sprint("[IN_REQUEST: ", r.URL, "] REQUEST_ID: ", r.Header.Get(constant.RequestID), " HEADER:", string(headerByte))
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	ip := remoteIP
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+
+	return model.LogHTTP{
+		RequestID:     r.Header.Get(constant.RequestID),
+		Method:        r.Method,
+		URI:           rules.URI(r.URL.RequestURI()),
+		IP:            ip,
+		RemoteIP:      remoteIP,
+		Host:          r.Host,
+		UserAgent:     r.UserAgent(),
+		Header:        string(headerByte),
+		Body:          string(body),
+		BodyTruncated: truncated,
+		CreatedAt:     utils.TimeNow(),
+	}
+}
+
+// captureBody read up to limit bytes of the body and put them back in front of the rest
+func captureBody(r *http.Request, limit int) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody || limit <= 0 {
+		return nil, false
+	}
+
+	peeked, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(peeked), r.Body), Closer: r.Body}
+	if len(peeked) > limit {
+		return peeked[:limit], true
+	}
+	return peeked, false
 }
 
 func (m *GoMiddleware) Pagination(next http.Handler) http.Handler {
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/ratelimit"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// RATE_LIMIT_PUBLIC route group without authentication
+	RATE_LIMIT_PUBLIC = "public"
+	// RATE_LIMIT_USER route group of the authenticated users
+	RATE_LIMIT_USER = "user"
+	// RATE_LIMIT_AUTH every ip sending a bearer token, counted before the token is verified so
+	// guessed or expired tokens are limited as well
+	RATE_LIMIT_AUTH = "auth"
+	// RATE_LIMIT_API_KEY every api key of the integrations, behind the ip limit so made up keys
+	// do not get a limit of their own
+	RATE_LIMIT_API_KEY = "api_key"
+
+	// rate limit headers of draft-ietf-httpapi-ratelimit-headers
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+	headerRateLimitPolicy    = "RateLimit-Policy"
+	headerRetryAfter         = "Retry-After"
+)
+
+var (
+	// DEFAULT_RATE_LIMIT_ALGORITHM used when RATE_LIMIT_ALGORITHM is not set
+	DEFAULT_RATE_LIMIT_ALGORITHM = ratelimit.ALGORITHM_TOKEN_BUCKET
+	// DEFAULT_RATE_LIMIT_WINDOW used when RATE_LIMIT_WINDOW is not set
+	DEFAULT_RATE_LIMIT_WINDOW = time.Minute
+	// DEFAULT_RATE_LIMIT_PUBLIC used when RATE_LIMIT_PUBLIC is not set
+	DEFAULT_RATE_LIMIT_PUBLIC = 60
+	// DEFAULT_RATE_LIMIT_USER used when RATE_LIMIT_USER is not set
+	DEFAULT_RATE_LIMIT_USER = 600
+	// DEFAULT_RATE_LIMIT_AUTH used when RATE_LIMIT_AUTH is not set, users behind one NAT share it
+	DEFAULT_RATE_LIMIT_AUTH = 1200
+	// DEFAULT_RATE_LIMIT_API_KEY used when RATE_LIMIT_API_KEY is not set
+	DEFAULT_RATE_LIMIT_API_KEY = 600
+
+	// RateLimitHeaders response headers of the rate limit, exposed to the browsers
+	RateLimitHeaders = []string{headerRateLimitLimit, headerRateLimitRemaining, headerRateLimitReset, headerRateLimitPolicy, headerRetryAfter}
+)
+
+// RateLimitKey who the request is counted for, requests with the same key share their limit
+type RateLimitKey func(r *http.Request) string
+
+// rateLimits the limit of each route group from the config
+func rateLimits(config model.RateLimit) map[string]ratelimit.Limit {
+	algorithm := config.Algorithm
+	if algorithm == "" {
+		algorithm = DEFAULT_RATE_LIMIT_ALGORITHM
+	}
+	window := DEFAULT_RATE_LIMIT_WINDOW
+	if config.Window > 0 {
+		window = time.Duration(config.Window) * time.Second
+	}
+
+	limit := func(requests, defaultRequests int) ratelimit.Limit {
+		if requests == 0 {
+			requests = defaultRequests
+		}
+		return ratelimit.Limit{Algorithm: algorithm, Requests: requests, Window: window, Burst: config.Burst}
+	}
+	return map[string]ratelimit.Limit{
+		RATE_LIMIT_PUBLIC:  limit(config.Public, DEFAULT_RATE_LIMIT_PUBLIC),
+		RATE_LIMIT_USER:    limit(config.User, DEFAULT_RATE_LIMIT_USER),
+		RATE_LIMIT_AUTH:    limit(config.Auth, DEFAULT_RATE_LIMIT_AUTH),
+		RATE_LIMIT_API_KEY: limit(config.APIKey, DEFAULT_RATE_LIMIT_API_KEY),
+	}
+}
+
+// RateLimit limit the requests of the route group per key, the requests over the limit get
+// too_many_requests. The limit is skipped when it is disabled or no store is configured, and the
+// request is let through when the store fails.
+func (m *GoMiddleware) RateLimit(group string, key RateLimitKey) func(http.Handler) http.Handler {
+	limit, ok := m.rateLimits[group]
+	if m.rateLimiter == nil || !ok || limit.Requests <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Window.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := m.rateLimiter.Allow(r.Context(), group+":"+key(r), limit)
+			if err != nil {
+				log.Ctx(r.Context()).Error().Msgf("error when rateLimiter.Allow(), err: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(headerRateLimitLimit, strconv.Itoa(result.Limit))
+			w.Header().Set(headerRateLimitRemaining, strconv.Itoa(result.Remaining))
+			w.Header().Set(headerRateLimitReset, strconv.Itoa(ceilSeconds(result.Reset)))
+			w.Header().Set(headerRateLimitPolicy, policy)
+			if !result.Allowed {
+				w.Header().Set(headerRetryAfter, strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+				model.MapBaseResponse(w, r, utils.ErrorTooManyRequests.Error(), nil, nil, utils.ErrorTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitByIP key of the client ip
+func (m *GoMiddleware) RateLimitByIP(r *http.Request) string {
+	return "ip:" + m.clientIP(r)
+}
+
+// RateLimitByUID key of the authenticated user, the ip when the request is not authenticated
+func (m *GoMiddleware) RateLimitByUID(r *http.Request) string {
+	if appContext, ok := model.AppContextFromContext(r.Context()); ok && appContext.UID != "" {
+		return "uid:" + appContext.UID
+	}
+	return m.RateLimitByIP(r)
+}
+
+// RateLimitByAPIKey key of the api-key header hashed, the ip when there is none. The key is not
+// verified here, so put it behind a limit by ip.
+func (m *GoMiddleware) RateLimitByAPIKey(r *http.Request) string {
+	if apiKey := r.Header.Get(constant.ApiKey); apiKey != "" {
+		return "api_key:" + utils.HashToStr(apiKey)
+	}
+	return m.RateLimitByIP(r)
+}
+
+// clientIP the address the request came from, behind a trusted proxy the last X-Forwarded-For entry
+// is the one the proxy saw
+func (m *GoMiddleware) clientIP(r *http.Request) string {
+	if m.Config.RateLimit.TrustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// ceilSeconds whole seconds rounded up so a client waiting them is not limited again
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
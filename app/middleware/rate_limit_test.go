@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	config := model.Config{RateLimit: model.RateLimit{Public: 2, User: -1}}
+	m := InitMiddleware(config, nil, nil, ratelimit.NewMemoryStore(), nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := m.RateLimit(RATE_LIMIT_PUBLIC, m.RateLimitByIP)(ok)
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/user/create_user", nil)
+		r.RemoteAddr = remoteAddr
+		// not trusted without a proxy
+		r.Header.Set("X-Forwarded-For", "10.0.0.9")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("192.0.2.1:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusOK, request("192.0.2.1:1235").Code)
+
+	w = request("192.0.2.1:1236")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	var res model.BaseResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, utils.TOO_MANY_REQUESTS, res.Code)
+
+	// another ip has its own limit
+	assert.Equal(t, http.StatusOK, request("192.0.2.2:1234").Code)
+
+	// a disabled limit lets everything through
+	w = httptest.NewRecorder()
+	m.RateLimit(RATE_LIMIT_USER, m.RateLimitByUID)(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/me", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitByIPTrustProxy(t *testing.T) {
+	m := InitMiddleware(model.Config{RateLimit: model.RateLimit{TrustProxy: true}}, nil, nil, nil, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "ip:10.0.0.1", m.RateLimitByIP(r))
+
+	// the entries before the last one are set by the client
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.4")
+	assert.Equal(t, "ip:198.51.100.4", m.RateLimitByIP(r))
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	m := InitMiddleware(model.Config{}, nil, nil, nil, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "ip:10.0.0.1", m.RateLimitByAPIKey(r))
+
+	// the key itself is not kept in the store
+	r.Header.Set("Api-Key", "secret")
+	key := m.RateLimitByAPIKey(r)
+	assert.Equal(t, "api_key:"+utils.HashToStr("secret"), key)
+	assert.NotContains(t, key, "secret")
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/erwinwahyura/go-boilerplate/utils/ulid"
+	"github.com/rs/zerolog/log"
+)
+
+// maxRequestIDLength longer incoming ids are replaced, they end up in every log line
+const maxRequestIDLength = 128
+
+// SetRequestID keep the request-id of the caller or generate one, the id is set back on the request
+// header for the code reading it from there, stored in the context with a logger adding it to every
+// line and echoed in the response header
+func (m *GoMiddleware) SetRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(constant.RequestID)
+		if !validRequestID(requestID) {
+			requestID = ulid.GenerateUlidID()
+		}
+		r.Header.Set(constant.RequestID, requestID)
+		w.Header().Set(constant.RequestID, requestID)
+
+		ctx := model.ContextWithRequestID(r.Context(), requestID)
+		ctx = log.With().Str("request_id", requestID).Logger().WithContext(ctx)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID printable ascii without spaces so the id can not forge log lines
+func validRequestID(requestID string) bool {
+	return requestID != "" && len(requestID) <= maxRequestIDLength && printable(requestID)
+}
+
+// printable ascii without spaces
+func printable(s string) bool {
+	for _, c := range s {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
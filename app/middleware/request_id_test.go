@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = previous }()
+
+	var seen string
+	handler := (&GoMiddleware{}).SetRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = model.RequestIDFromContext(r.Context())
+		assert.Equal(t, seen, r.Header.Get(constant.RequestID))
+		log.Ctx(r.Context()).Info().Msg("handled")
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{name: "kept", incoming: "checkout-7f3a", kept: true},
+		{name: "generated", incoming: ""},
+		{name: "forged log line", incoming: "id\n{\"level\":\"error\"}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set(constant.RequestID, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if tt.kept {
+				assert.Equal(t, tt.incoming, seen)
+			} else {
+				assert.Len(t, seen, 26)
+			}
+			assert.Equal(t, seen, w.Header().Get(constant.RequestID))
+			assert.Contains(t, buf.String(), `"request_id":"`+seen+`"`)
+		})
+	}
+}
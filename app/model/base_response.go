@@ -1,15 +1,28 @@
 package model
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
 	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
-const DEFAULT_PAGINATION_SIZE = 20
+const (
+	DEFAULT_PAGINATION_SIZE = 20
+
+	// Content type
+	CONTENT_TYPE_JSON    = "application/json"
+	CONTENT_TYPE_MSGPACK = "application/msgpack"
+)
+
+// CompactJSON default of the JSON responses, ?pretty=1 indents the response anyway. Set in production.
+var CompactJSON = constant.IS_PRODUCTION
 
 type (
 	// BaseResponse is the base response
@@ -20,16 +33,16 @@ type (
 		Meta       interface{} `json:"meta"`
 		Errors     []string    `json:"errors"`
 		ServerTime int64       `json:"server_time"`
+		RequestID  string      `json:"request_id,omitempty"`
 	}
 )
 
 // MapBaseResponse map response
 func MapBaseResponse(w http.ResponseWriter, r *http.Request, message string, data interface{}, meta interface{}, err error) {
 	// Check Request ID
-	requestID := r.Header.Get(constant.RequestID)
-	if requestID != "" {
-		bodyByte, _ := json.Marshal(data)
-		fmt.Println("[RESPONSE: ", r.URL.String(), "] REQUEST_ID: ", requestID, " BODY:", string(bodyByte))
+	requestID := RequestIDFromContext(r.Context())
+	if requestID == "" {
+		requestID = r.Header.Get(constant.RequestID)
 	}
 
 	statusCode, code := utils.GetStatusCode(err)
@@ -47,13 +60,98 @@ func MapBaseResponse(w http.ResponseWriter, r *http.Request, message string, dat
 		Errors:     errors,
 		ServerTime: utils.TimeNow().Unix(),
 		Meta:       meta,
+		RequestID:  requestID,
 	}
 
-	// Marshal json response
-	jsonResponse, _ := json.MarshalIndent(payload, "", "    ")
+	// Marshal response in the format accepted by the client
+	contentType, response, err := marshalResponse(r, payload)
+	if err != nil {
+		statusCode = http.StatusInternalServerError
+		contentType, response = CONTENT_TYPE_JSON, []byte(`{"code":"`+utils.INTERNAL_SERVER_ERROR+`"}`)
+	}
 
 	// Write Response
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
 	w.WriteHeader(statusCode)
-	w.Write(jsonResponse)
+	w.Write(response)
+}
+
+// ResponseContentType content type MapBaseResponse answers r with
+func ResponseContentType(r *http.Request) string {
+	if acceptMsgpack(r.Header.Get("Accept")) {
+		return CONTENT_TYPE_MSGPACK
+	}
+	return CONTENT_TYPE_JSON
+}
+
+// marshalResponse MessagePack when the client accepts it, otherwise JSON compact or indented
+func marshalResponse(r *http.Request, payload BaseResponse) (string, []byte, error) {
+	if ResponseContentType(r) == CONTENT_TYPE_MSGPACK {
+		response, err := json.Marshal(payload)
+		if err != nil {
+			return "", nil, err
+		}
+		response, err = jsonToMsgpack(response)
+		return CONTENT_TYPE_MSGPACK, response, err
+	}
+
+	pretty := !CompactJSON
+	if value, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		pretty = value
+	}
+	if pretty {
+		response, err := json.MarshalIndent(payload, "", "    ")
+		return CONTENT_TYPE_JSON, response, err
+	}
+	response, err := json.Marshal(payload)
+	return CONTENT_TYPE_JSON, response, err
+}
+
+// acceptMsgpack whether the Accept header lists MessagePack
+func acceptMsgpack(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil || (mediaType != CONTENT_TYPE_MSGPACK && mediaType != "application/x-msgpack") {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q <= 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// jsonToMsgpack re-encode the JSON response so the MessagePack one is the same envelope, with the
+// json tags and MarshalJSON of the data applied
+func jsonToMsgpack(response []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(response))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(msgpackValue(value))
+}
+
+// msgpackValue the numbers as integers when they are, floats otherwise
+func msgpackValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = msgpackValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = msgpackValue(item)
+		}
+	}
+	return value
 }
@@ -17,6 +17,13 @@ type (
 		Redis        Redis        `mapstructure:",squash"`
 		Meilisearch  Meilisearch  `mapstructure:",squash"`
 		Image        Image        `mapstructure:",squash"`
+		Encryption   Encryption   `mapstructure:",squash"`
+		Outbox       Outbox       `mapstructure:",squash"`
+		Health       Health       `mapstructure:",squash"`
+		AccessLog    AccessLog    `mapstructure:",squash"`
+		RateLimit    RateLimit    `mapstructure:",squash"`
+		Idempotency  Idempotency  `mapstructure:",squash"`
+		Compression  Compression  `mapstructure:",squash"`
 	}
 
 	// Host server config
@@ -26,12 +33,23 @@ type (
 		WriteTimeout int    `mapstructure:"HOST_WRITE_TIMEOUT" default:"15"`
 		ReadTimeout  int    `mapstructure:"HOST_READ_TIMEOUT" default:"15"`
 		IdleTimeout  int    `mapstructure:"HOST_IDLE_TIMEOUT" default:"60"`
+		// DrainDelay in seconds between failing the readiness and stopping the server on shutdown,
+		// it should be longer than the period of the readiness probe
+		DrainDelay int `mapstructure:"HOST_DRAIN_DELAY"`
+		// ShutdownTimeout in seconds all the components are given to stop, keep HOST_DRAIN_DELAY plus
+		// this within the termination grace period
+		ShutdownTimeout int `mapstructure:"HOST_SHUTDOWN_TIMEOUT"`
 	}
 
 	// Database all database
 	Database struct {
 		LogDB DatasourceLog `mapstructure:",squash"`
 		DB    Datasource    `mapstructure:",squash"`
+		// StartupTimeout in seconds postgres is retried at startup, still down after it stops the startup
+		StartupTimeout int `mapstructure:"DB_STARTUP_TIMEOUT"`
+		// LogStartupTimeout in seconds the log database is waited for at startup, still down after it
+		// the service starts degraded and the log database connects in the background
+		LogStartupTimeout int `mapstructure:"DB_LOG_STARTUP_TIMEOUT"`
 	}
 
 	// Datasource datasource detail
@@ -40,6 +58,29 @@ type (
 		Slave  string `mapstructure:"DB_SLAVE"`
 		Driver string `mapstructure:"DB_DRIVER"`
 		DBName string `mapstructure:"DB_DBNAME"`
+		// ReplicaMaxLag in seconds, reads go to the master while the slave lags behind more than this
+		ReplicaMaxLag int `mapstructure:"DB_REPLICA_MAX_LAG"`
+		// ReplicaCheckInterval in seconds between slave health and lag checks
+		ReplicaCheckInterval int `mapstructure:"DB_REPLICA_CHECK_INTERVAL"`
+		// MigrateOnStart apply the pending migrations before the server starts listening
+		MigrateOnStart bool `mapstructure:"DB_MIGRATE_ON_START"`
+		// SlowQueryThreshold in milliseconds, slower queries are logged as warnings
+		SlowQueryThreshold int `mapstructure:"DB_SLOW_QUERY_THRESHOLD"`
+		// LogQueries log every query at debug level, not only the slow and failed ones
+		LogQueries bool `mapstructure:"DB_LOG_QUERIES"`
+
+		// connection pool of the master and the slave, 0 uses the default of sqlxdb.DefaultPool.
+		// lifetime and idle time are in seconds, the statement timeout in milliseconds
+		MasterMaxOpenConns     int `mapstructure:"DB_MASTER_MAX_OPEN_CONNS"`
+		MasterMaxIdleConns     int `mapstructure:"DB_MASTER_MAX_IDLE_CONNS"`
+		MasterConnMaxLifetime  int `mapstructure:"DB_MASTER_CONN_MAX_LIFETIME"`
+		MasterConnMaxIdleTime  int `mapstructure:"DB_MASTER_CONN_MAX_IDLE_TIME"`
+		MasterStatementTimeout int `mapstructure:"DB_MASTER_STATEMENT_TIMEOUT"`
+		SlaveMaxOpenConns      int `mapstructure:"DB_SLAVE_MAX_OPEN_CONNS"`
+		SlaveMaxIdleConns      int `mapstructure:"DB_SLAVE_MAX_IDLE_CONNS"`
+		SlaveConnMaxLifetime   int `mapstructure:"DB_SLAVE_CONN_MAX_LIFETIME"`
+		SlaveConnMaxIdleTime   int `mapstructure:"DB_SLAVE_CONN_MAX_IDLE_TIME"`
+		SlaveStatementTimeout  int `mapstructure:"DB_SLAVE_STATEMENT_TIMEOUT"`
 	}
 	// Datasource datasource log detail
 	DatasourceLog struct {
@@ -47,6 +88,18 @@ type (
 		Slave  string `mapstructure:"DB_LOG_SLAVE"`
 		Driver string `mapstructure:"DB_LOG_DRIVER"`
 		DBName string `mapstructure:"DB_LOG_DBNAME"`
+
+		// connection pool of the master and the slave, 0 uses the driver default. idle time is in
+		// seconds, the operation timeout in milliseconds
+		MaxPoolSize     int `mapstructure:"DB_LOG_MAX_POOL_SIZE"`
+		MinPoolSize     int `mapstructure:"DB_LOG_MIN_POOL_SIZE"`
+		MaxConnIdleTime int `mapstructure:"DB_LOG_MAX_CONN_IDLE_TIME"`
+		Timeout         int `mapstructure:"DB_LOG_TIMEOUT"`
+
+		// HTTPBufferSize http logs waiting to be inserted, more are dropped
+		HTTPBufferSize int `mapstructure:"DB_LOG_HTTP_BUFFER_SIZE"`
+		// HTTPTTL in days the http logs are kept
+		HTTPTTL int `mapstructure:"DB_LOG_HTTP_TTL"`
 	}
 
 	// Slack slack notif
@@ -64,6 +117,12 @@ type (
 		RedirectURI  string `mapstructure:"MYVALUE_REDIRECT_URI"`
 		BaseURL      string `mapstructure:"MYVALUE_BASE_URL"`
 		ExternalURL  string `mapstructure:"MYVALUE_EXTERNAL_URL"`
+		// Timeout in seconds for a single call to the MyValue API
+		Timeout int `mapstructure:"MYVALUE_TIMEOUT"`
+		// PointCacheTTL in seconds, how long a points balance may be served from cache when MyValue is unavailable
+		PointCacheTTL int `mapstructure:"MYVALUE_POINT_CACHE_TTL"`
+		// PointCacheSize balances kept in cache, the least recently used ones are evicted beyond it
+		PointCacheSize int `mapstructure:"MYVALUE_POINT_CACHE_SIZE"`
 	}
 
 	// Promo Service
@@ -74,8 +133,12 @@ type (
 
 	// Redis
 	Redis struct {
-		Host string `mapstructure:"REDIS_HOST"`
-		Port string `mapstructure:"REDIS_PORT"`
+		Host     string `mapstructure:"REDIS_HOST"`
+		Port     string `mapstructure:"REDIS_PORT"`
+		Password string `mapstructure:"REDIS_PASSWORD"`
+		DB       int    `mapstructure:"REDIS_DB"`
+		// TLS connect with TLS, e.g. to a managed redis
+		TLS bool `mapstructure:"REDIS_TLS"`
 	}
 	// Meilisearch
 	Meilisearch struct {
@@ -87,4 +150,92 @@ type (
 	Image struct {
 		BaseURL string `mapstructure:"IMAGE_BASE_URL"`
 	}
+
+	// Encryption field-level encryption of PII columns
+	Encryption struct {
+		// MasterKeys "<version>:<base64 32 bytes key>" separated by comma, old versions are kept to decrypt
+		MasterKeys string `mapstructure:"PII_MASTER_KEYS"`
+		// MasterKeyVersion version used to wrap new data keys
+		MasterKeyVersion uint32 `mapstructure:"PII_MASTER_KEY_VERSION"`
+		// BlindIndexKey key of the keyed hash used for exact-match lookups, must never be rotated
+		BlindIndexKey string `mapstructure:"PII_BLIND_INDEX_KEY"`
+	}
+
+	// Outbox relay of the domain events, 0 uses the default of the relay
+	Outbox struct {
+		// PollInterval in milliseconds between polls when the outbox is empty
+		PollInterval int `mapstructure:"OUTBOX_POLL_INTERVAL"`
+		// BatchSize events claimed per poll
+		BatchSize int `mapstructure:"OUTBOX_BATCH_SIZE"`
+		// MaxAttempts failed publishes before the event is marked dead
+		MaxAttempts int `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+		// Retention in hours the published events are kept
+		Retention int `mapstructure:"OUTBOX_RETENTION"`
+	}
+
+	// Health checks of the dependencies, 0 uses the default of the health check registry
+	Health struct {
+		// CheckTimeout in milliseconds of a single dependency check
+		CheckTimeout int `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+		// CheckInterval in milliseconds between the checks of a dependency
+		CheckInterval int `mapstructure:"HEALTH_CHECK_INTERVAL"`
+		// HistorySize results kept per dependency
+		HistorySize int `mapstructure:"HEALTH_HISTORY_SIZE"`
+		// CriticalDependencies outbound dependencies separated by comma that fail the readiness when
+		// they are down, e.g. "redis,myvalue"
+		CriticalDependencies string `mapstructure:"HEALTH_CRITICAL_DEPENDENCIES"`
+	}
+
+	// AccessLog one log line per request, the request is stored with its redacted body in the log
+	// database. Empty values use the defaults of the middleware.
+	AccessLog struct {
+		// RedactHeaders header names separated by comma whose values are never logged, added to the
+		// defaults of the middleware
+		RedactHeaders string `mapstructure:"LOG_REDACT_HEADERS"`
+		// RedactFields JSON, form and query fields separated by comma, added to the defaults of the
+		// middleware. A field whose words contain the words of one of them is redacted.
+		RedactFields string `mapstructure:"LOG_REDACT_FIELDS"`
+		// BodyLimit in bytes of the request body kept up to model.MAX_LOG_HTTP_BODY_SIZE, 0 uses the
+		// default and -1 keeps none
+		BodyLimit int `mapstructure:"LOG_BODY_LIMIT"`
+	}
+
+	// RateLimit of the route groups, 0 uses the default of the middleware and -1 disables the limit
+	RateLimit struct {
+		// Backend memory counts per instance, redis shares the counts through REDIS_HOST
+		Backend string `mapstructure:"RATE_LIMIT_BACKEND"`
+		// Algorithm token_bucket or sliding_window
+		Algorithm string `mapstructure:"RATE_LIMIT_ALGORITHM"`
+		// Window in seconds the requests are counted over
+		Window int `mapstructure:"RATE_LIMIT_WINDOW"`
+		// Burst of the token bucket, 0 uses the requests of the limit
+		Burst int `mapstructure:"RATE_LIMIT_BURST"`
+		// Public requests per window of an ip on the routes without authentication
+		Public int `mapstructure:"RATE_LIMIT_PUBLIC"`
+		// User requests per window of an authenticated user
+		User int `mapstructure:"RATE_LIMIT_USER"`
+		// Auth requests per window of an ip on the routes with authentication, before the token is verified
+		Auth int `mapstructure:"RATE_LIMIT_AUTH"`
+		// APIKey requests per window of an api key on the public routes
+		APIKey int `mapstructure:"RATE_LIMIT_API_KEY"`
+		// TrustProxy key the ip by the X-Forwarded-For header of the proxy in front of the app,
+		// without a proxy the header is set by the client
+		TrustProxy bool `mapstructure:"RATE_LIMIT_TRUST_PROXY"`
+	}
+
+	// Idempotency of the POST requests sent with an Idempotency-Key, 0 uses the default of the middleware
+	Idempotency struct {
+		// TTL in hours the response is replayed to the retries
+		TTL int `mapstructure:"IDEMPOTENCY_TTL"`
+		// LockTimeout in seconds after which a request still processing is considered dead and its
+		// retry runs again, keep it above HOST_WRITE_TIMEOUT
+		LockTimeout int `mapstructure:"IDEMPOTENCY_LOCK_TIMEOUT"`
+	}
+
+	// Compression of the responses
+	Compression struct {
+		// MinSize in bytes of the responses compressed, 0 uses the default of the middleware and -1
+		// disables the compression
+		MinSize int `mapstructure:"COMPRESS_MIN_SIZE"`
+	}
 )
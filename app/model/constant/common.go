@@ -4,9 +4,11 @@ import "os"
 
 var (
 	// Header
-	ChannelID = "X-Channel-Id"
-	ApiKey    = "api-key"
-	RequestID = "request-id"
+	ChannelID          = "X-Channel-Id"
+	ApiKey             = "api-key"
+	RequestID          = "request-id"
+	IdempotencyKey     = "Idempotency-Key"
+	IdempotentReplayed = "Idempotent-Replayed"
 
 	// Environment
 	ENV_PROD    = "prod"
@@ -0,0 +1,24 @@
+package model
+
+import "github.com/erwinwahyura/go-boilerplate/utils/mongodb"
+
+type (
+	// DatabasePoolStatsResponse connection pools by datasource (master, slave)
+	DatabasePoolStatsResponse struct {
+		Postgres map[string]PostgresPoolStats         `json:"postgres"`
+		MongoLog map[string]mongodb.PoolStatsSnapshot `json:"mongodb_log"`
+	}
+
+	// PostgresPoolStats sql.DBStats of a datasource
+	PostgresPoolStats struct {
+		MaxOpenConnections int   `json:"max_open_connections"`
+		OpenConnections    int   `json:"open_connections"`
+		InUse              int   `json:"in_use"`
+		Idle               int   `json:"idle"`
+		WaitCount          int64 `json:"wait_count"`
+		WaitDurationMs     int64 `json:"wait_duration_ms"`
+		MaxIdleClosed      int64 `json:"max_idle_closed"`
+		MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+		MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+	}
+)
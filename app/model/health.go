@@ -1,9 +1,53 @@
 package model
 
+import "time"
+
+const (
+	HEALTH_STATUS_UP = "up"
+	// HEALTH_STATUS_DEGRADED a degraded component is down, the service still serves traffic
+	HEALTH_STATUS_DEGRADED = "degraded"
+	HEALTH_STATUS_DOWN     = "down"
+	// HEALTH_STATUS_UNKNOWN the check did not run yet
+	HEALTH_STATUS_UNKNOWN = "unknown"
+
+	// HEALTH_CRITICAL the service is not ready while the component is down
+	HEALTH_CRITICAL = "critical"
+	// HEALTH_DEGRADED the service runs degraded while the component is down
+	HEALTH_DEGRADED = "degraded"
+)
+
 type (
 	// HealthCheckResponse response
 	HealthCheckResponse struct {
-		Success []string `json:"success,omitempty"`
-		Errors  []string `json:"errors,omitempty"`
+		Status     string            `json:"status"`
+		Components []HealthComponent `json:"components,omitempty"`
+		CheckedAt  time.Time         `json:"checked_at"`
+		// Draining the service is shutting down
+		Draining bool `json:"draining,omitempty"`
+	}
+
+	// HealthComponent last result of the check of one dependency
+	HealthComponent struct {
+		Name        string `json:"name"`
+		Criticality string `json:"criticality"`
+		HealthResult
+	}
+
+	// HealthResult result of a single check
+	HealthResult struct {
+		Status string `json:"status"`
+		// Latency in milliseconds of the check
+		Latency   float64   `json:"latency_ms"`
+		Error     string    `json:"error,omitempty"`
+		CheckedAt time.Time `json:"checked_at"`
+	}
+
+	// HealthCheckHistory last results of the check of one dependency, newest first
+	HealthCheckHistory struct {
+		Name        string `json:"name"`
+		Criticality string `json:"criticality"`
+		// Interval in milliseconds between the checks
+		Interval int64          `json:"interval_ms"`
+		Results  []HealthResult `json:"results"`
 	}
 )
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+const (
+	// Idempotency status
+	IDEMPOTENCY_STATUS_PROCESSING = "processing"
+	IDEMPOTENCY_STATUS_COMPLETED  = "completed"
+)
+
+// IdempotencyRecord request sent with an Idempotency-Key and, once completed, its response
+type IdempotencyRecord struct {
+	// Scope the key belongs to, the uid of the authenticated user or empty
+	Scope string `db:"scope"`
+	Key   string `db:"idempotency_key"`
+	// Fingerprint hash of the method, path and body, a retry must match it
+	Fingerprint         string `db:"fingerprint"`
+	Status              string `db:"status"`
+	ResponseStatus      int    `db:"response_status"`
+	ResponseContentType string `db:"response_content_type"`
+	ResponseBody        []byte `db:"response_body"`
+	// LockedUntil a processing key is taken over by a retry after it, in case the first request died
+	LockedUntil time.Time `db:"locked_until"`
+	ExpiresAt   time.Time `db:"expires_at"`
+	CreatedAt   time.Time `db:"created_at"`
+}
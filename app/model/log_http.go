@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// MAX_LOG_HTTP_BODY_SIZE bytes of the body stored whatever LOG_BODY_LIMIT is, a log must stay far
+// below the document size limit of the log database
+const MAX_LOG_HTTP_BODY_SIZE = 64 << 10
+
+type (
+	// LogHTTP to show fields of log, stored in the log database
+	LogHTTP struct {
+		ID        string `json:"id" bson:"_id"`
+		RequestID string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+		Method    string `json:"method,omitempty" bson:"method,omitempty"`
+		URI       string `json:"uri,omitempty" bson:"uri,omitempty"`
+		IP        string `json:"ip,omitempty" bson:"ip,omitempty"`
+		RemoteIP  string `json:"remote_ip,omitempty" bson:"remote_ip,omitempty"`
+		Host      string `json:"host,omitempty" bson:"host,omitempty"`
+		Status    int    `json:"status,omitempty" bson:"status,omitempty"`
+		Size      int64  `json:"size,omitempty" bson:"size,omitempty"`
+		UserAgent string `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+		Header    string `json:"header,omitempty" bson:"header,omitempty"`
+		Body      string `json:"body,omitempty" bson:"body,omitempty"`
+		// BodyTruncated only the first LOG_BODY_LIMIT bytes of the body are kept
+		BodyTruncated bool      `json:"body_truncated,omitempty" bson:"body_truncated,omitempty"`
+		DurationMs    int64     `json:"duration_ms" bson:"duration_ms"`
+		CreatedAt     time.Time `json:"created_at" bson:"created_at"`
+	}
+
+	// LogHTTPFilter search of the http logs, empty fields match everything
+	LogHTTPFilter struct {
+		RequestID string
+		// URI prefix of the path, the query string is part of the stored uri
+		URI    string
+		Status int
+	}
+)
+
+// Capped log with its body cut to MAX_LOG_HTTP_BODY_SIZE
+func (l LogHTTP) Capped() LogHTTP {
+	if len(l.Body) > MAX_LOG_HTTP_BODY_SIZE {
+		l.Body = l.Body[:MAX_LOG_HTTP_BODY_SIZE]
+		l.BodyTruncated = true
+	}
+	return l
+}
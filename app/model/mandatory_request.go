@@ -2,8 +2,11 @@ package model
 
 import (
 	"context"
+	"strconv"
 )
 
+type appContextKey struct{}
+
 // AppContext ...
 type AppContext struct {
 	context.Context
@@ -16,4 +19,25 @@ type AppContext struct {
 // MandatoryRequest ...
 type MandatoryRequest struct {
 	ChannelID string
+	RequestID string
+}
+
+// NewAppContext store the app context so it can still be found after
+// another middleware wraps the request context
+func NewAppContext(appContext AppContext) context.Context {
+	return context.WithValue(appContext, appContextKey{}, appContext)
+}
+
+// AppContextFromContext get the app context set by the Authenticate middleware
+func AppContextFromContext(ctx context.Context) (AppContext, bool) {
+	if appContext, ok := ctx.(AppContext); ok {
+		return appContext, true
+	}
+	appContext, ok := ctx.Value(appContextKey{}).(AppContext)
+	return appContext, ok
+}
+
+// UserID parse the uid claim into the id of table user
+func (c AppContext) UserID() (int64, error) {
+	return strconv.ParseInt(c.UID, 10, 64)
 }
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+const (
+	// Outbox status
+	OUTBOX_STATUS_PENDING   = "pending"
+	OUTBOX_STATUS_PUBLISHED = "published"
+	// OUTBOX_STATUS_DEAD the event failed OUTBOX_MAX_ATTEMPTS times and is no longer retried
+	OUTBOX_STATUS_DEAD = "dead"
+
+	// Aggregate type
+	AGGREGATE_USER = "user"
+
+	// Event type
+	EVENT_USER_CREATED = "user.created"
+)
+
+type (
+	// OutboxEvent domain event stored in the transaction of the change until it is published
+	OutboxEvent struct {
+		ID            int64          `json:"id" db:"id"`
+		EventType     string         `json:"event_type" db:"event_type"`
+		AggregateType string         `json:"aggregate_type" db:"aggregate_type"`
+		AggregateID   string         `json:"aggregate_id" db:"aggregate_id"`
+		Payload       types.JSONText `json:"payload" db:"payload" swaggertype:"object"`
+		Status        string         `json:"status" db:"status"`
+		Attempts      int            `json:"attempts" db:"attempts"`
+		LastError     *string        `json:"last_error,omitempty" db:"last_error"`
+		AvailableAt   time.Time      `json:"available_at" db:"available_at"`
+		// LockedUntil lease of the relay that claimed the event
+		LockedUntil *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+		CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+		PublishedAt *time.Time `json:"published_at,omitempty" db:"published_at"`
+	}
+
+	// UserCreatedEvent payload of user.created, the outbox outlives an erasure so it carries no
+	// personal data: the consumers read the user by its id
+	UserCreatedEvent struct {
+		ID         int64     `json:"id"`
+		IsGuest    bool      `json:"is_guest"`
+		DateJoined time.Time `json:"date_joined"`
+	}
+)
+
+// NewOutboxEvent marshal payload into an event of aggregateType/aggregateID
+func NewOutboxEvent(eventType, aggregateType, aggregateID string, payload interface{}) (OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	return OutboxEvent{
+		EventType:     eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       data,
+		Status:        OUTBOX_STATUS_PENDING,
+	}, nil
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+const (
+	// Privacy job type
+	PRIVACY_JOB_EXPORT  = "export"
+	PRIVACY_JOB_ERASURE = "erasure"
+
+	// Privacy job status
+	PRIVACY_JOB_PENDING = "pending"
+	PRIVACY_JOB_RUNNING = "running"
+	PRIVACY_JOB_DONE    = "done"
+	PRIVACY_JOB_FAILED  = "failed"
+
+	// Erasure step, the last one done
+	PRIVACY_STEP_IMAGE_DELETED   = "image_deleted"
+	PRIVACY_STEP_USER_ANONYMIZED = "user_anonymized"
+
+	USER_HISTORY_ERASE = "erase"
+)
+
+type (
+	// PrivacyJob background job of a data subject request, stored so any instance can run it,
+	// resume it after a crash and serve its status and archive
+	PrivacyJob struct {
+		ID          string     `json:"id" db:"id"`
+		UserID      int64      `json:"user_id" db:"user_id"`
+		Type        string     `json:"type" db:"type"`
+		Status      string     `json:"status" db:"status"`
+		Step        string     `json:"-" db:"step"`
+		Attempts    int        `json:"-" db:"attempts"`
+		Error       string     `json:"error,omitempty" db:"error"`
+		RequestID   string     `json:"-" db:"request_id"`
+		DownloadURL string     `json:"download_url,omitempty" db:"-"`
+		LockedUntil *time.Time `json:"-" db:"locked_until"`
+		CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+		FinishedAt  *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+	}
+
+	// PersonalDataExport profile of the user inside the export archive, the password is never exported
+	PersonalDataExport struct {
+		ID                 int64      `json:"id"`
+		Email              string     `json:"email"`
+		Username           string     `json:"username"`
+		FirstName          string     `json:"first_name"`
+		LastName           string     `json:"last_name"`
+		PhoneNumber        string     `json:"phone_number"`
+		HomePhone          string     `json:"home_phone_number"`
+		BirthPlace         string     `json:"birth_place"`
+		BirthDate          *time.Time `json:"birth_date"`
+		Gender             string     `json:"gender"`
+		Job                string     `json:"occupation"`
+		Hobby              string     `json:"hobby"`
+		IdentityType       string     `json:"identity_type"`
+		IdentityNumber     string     `json:"identity_number"`
+		IdentityImage      string     `json:"identity_image"`
+		Properties         string     `json:"properties"`
+		IsActive           bool       `json:"is_active"`
+		IsVerified         bool       `json:"verified"`
+		LastLogin          *time.Time `json:"last_login"`
+		CreatedAt          time.Time  `json:"date_joined"`
+		CorporateAccountID int64      `json:"corporate_account_id"`
+		AuthorID           int64      `json:"author_id"`
+	}
+)
+
+// IsActive pending or running
+func (j PrivacyJob) IsActive() bool {
+	return j.Status == PRIVACY_JOB_PENDING || j.Status == PRIVACY_JOB_RUNNING
+}
+
+// WithDownloadURL job with the download url of its archive once a export is done
+func (j PrivacyJob) WithDownloadURL() PrivacyJob {
+	if j.Type == PRIVACY_JOB_EXPORT && j.Status == PRIVACY_JOB_DONE {
+		j.DownloadURL = fmt.Sprintf("/api/v1/me/export/%s/download", j.ID)
+	}
+	return j
+}
+
+// ToPersonalDataExport map user into the export profile
+func (s *User) ToPersonalDataExport() PersonalDataExport {
+	export := PersonalDataExport{
+		ID:                 s.ID,
+		Email:              s.Email,
+		Username:           utils.PtrToValue(s.Username),
+		FirstName:          utils.PtrToValue(s.FirstName),
+		LastName:           utils.PtrToValue(s.LastName),
+		PhoneNumber:        utils.PtrToValue(s.PhoneNumber).String(),
+		HomePhone:          utils.PtrToValue(s.HomePhone).String(),
+		BirthPlace:         utils.PtrToValue(s.BirthPlace),
+		Gender:             utils.PtrToValue(s.Gender),
+		Job:                utils.PtrToValue(s.Job),
+		Hobby:              utils.PtrToValue(s.Hobby),
+		IdentityType:       string(utils.PtrToValue(s.IdentityType)),
+		IdentityNumber:     utils.PtrToValue(s.IdentityNumber).String(),
+		IdentityImage:      utils.PtrToValue(s.IdentityImage),
+		Properties:         s.Properties,
+		IsActive:           s.IsActive,
+		IsVerified:         s.IsVerified,
+		LastLogin:          s.LastLogin,
+		CreatedAt:          s.CreatedAt,
+		CorporateAccountID: s.CorporateAccountID,
+		AuthorID:           s.AuthorID,
+	}
+	if s.BirthDate != nil {
+		export.BirthDate = &s.BirthDate.Time
+	}
+
+	return export
+}
+
+// ErasedUserColumns columns cleared by the erasure, listed in its history entry with the values
+// redacted
+var ErasedUserColumns = []string{"email", "username", "first_name", "last_name", "phone_number", "password",
+	"last_login", "properties", "birth_place", "birth_date", "gender", "home_phone_number", "occupation",
+	"hobby", "identity_image", "identity_number", "identity_type"}
+
+// AnonymizedEmail email of an erased user, unique so the constraint still holds
+func AnonymizedEmail(id int64) string {
+	return fmt.Sprintf("deleted-%d@deleted.invalid", id)
+}
+
+// AnonymizedUsername username of an erased user
+func AnonymizedUsername(id int64) string {
+	return fmt.Sprintf("deleted-%d", id)
+}
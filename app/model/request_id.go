@@ -0,0 +1,21 @@
+package model
+
+import "context"
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID store the request id of the incoming request
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext request id set by the SetRequestID middleware, empty outside of a request
+func RequestIDFromContext(ctx context.Context) string {
+	if requestID, ok := ctx.Value(requestIDContextKey{}).(string); ok {
+		return requestID
+	}
+	if appContext, ok := AppContextFromContext(ctx); ok {
+		return appContext.RequestID
+	}
+	return ""
+}
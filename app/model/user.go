@@ -1,9 +1,11 @@
 package model
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
 )
 
 type IdentityType string
@@ -20,7 +22,7 @@ func (s *User) ToUserRequest() UserRequest {
 		Email:              s.Email,
 		FirstName:          utils.PtrToValue(s.FirstName),
 		LastName:           utils.PtrToValue(s.LastName),
-		PhoneNumber:        utils.PtrToValue(s.PhoneNumber),
+		PhoneNumber:        utils.PtrToValue(s.PhoneNumber).String(),
 		Username:           utils.PtrToValue(s.Username),
 		Password:           utils.PtrToValue(s.Password),
 		LastLogin:          utils.PtrToValue(s.LastLogin),
@@ -36,12 +38,12 @@ func (s *User) ToUserRequest() UserRequest {
 
 // NOTE: maybe we can remove the pointer and use COALESCE on the query instead?
 type User struct {
-	ID          int64   `db:"id"`
-	Email       string  `db:"email"`
-	FirstName   *string `db:"first_name"`
-	LastName    *string `db:"last_name"`
-	PhoneNumber *string `db:"phone_number"`
-	Username    *string `db:"username"`
+	ID          int64                       `db:"id"`
+	Email       string                      `db:"email"`
+	FirstName   *string                     `db:"first_name"`
+	LastName    *string                     `db:"last_name"`
+	PhoneNumber *fieldcrypt.EncryptedString `db:"phone_number"`
+	Username    *string                     `db:"username"`
 	// sample password
 	// scoop$ffff2ab42c2a9423c27f86487b65d92babe4e0a15e49b4a42e4bc50de2621692$4a4a578741bf263d334c2b68a85556a4796460b32a50af6685d45de6a201fb1201bdefc1a37b570ab0f839b4cc1c5de5c9a81859e6deb98d2df734617dddcdd9
 	// 2917b0dc1a2b32ce0c4c1910815a0c6add1e08be86fe4ecf95be7848e46f9d3a
@@ -60,6 +62,8 @@ type User struct {
 
 	// if we change to the new db then should be change the name into created_at instead of date_joined
 	CreatedAt time.Time `db:"date_joined"`
+	// UpdatedAt version of the row, set by the database on every update and exposed as the ETag
+	UpdatedAt time.Time `db:"updated_at"`
 
 	// not quite sure properties in legacy db is data type array, should be string and has a separator (; or ,)
 	Properties string `db:"properties"`
@@ -70,21 +74,29 @@ type User struct {
 	// new added, a FK from table author
 	AuthorID int64 `db:"author_id"`
 
+	// keyed hash of the phone number for exact-match lookups, see fieldcrypt.Keyring.BlindIndex
+	PhoneNumberIndex *string `db:"phone_number_bidx"`
+
 	// user_profile's data
-	BirthPlace *string    `db:"birth_place"`
-	BirthDate  *time.Time `db:"birth_date"`
-	Gender     *string    `db:"gender"`
-	HomePhone  *string    `db:"home_phone_number"`
-	Job        *string    `db:"occupation"`
+	BirthPlace *string                     `db:"birth_place"`
+	BirthDate  *fieldcrypt.EncryptedTime   `db:"birth_date"`
+	Gender     *string                     `db:"gender"`
+	HomePhone  *fieldcrypt.EncryptedString `db:"home_phone_number"`
+	Job        *string                     `db:"occupation"`
 
 	// the legacy DB is using array as data type, can use string and separate it with (, or ;)
 	Hobby *string `db:"hobby"`
 
 	// fk from table user
 	// UserID         int64  `db:"user_id"`
-	IdentityImage  *string       `db:"identity_image"`
-	IdentityNumber *string       `db:"identity_number"`
-	IdentityType   *IdentityType `db:"identity_type"`
+	IdentityImage  *string                     `db:"identity_image"`
+	IdentityNumber *fieldcrypt.EncryptedString `db:"identity_number"`
+	IdentityType   *IdentityType               `db:"identity_type"`
+}
+
+// ETag version of the user for If-Match, changes on every update
+func (u User) ETag() string {
+	return fmt.Sprintf(`"%d"`, u.UpdatedAt.UnixMicro())
 }
 
 // sample data of user
@@ -143,11 +155,11 @@ type ModifiedUser struct {
 // Request and Response struct User below
 
 type UserRequest struct {
-	Email       string    `json:"email"`
-	FirstName   string    `json:"first_name"`
-	LastName    string    `json:"last_name"`
-	PhoneNumber string    `json:"phone_number"`
-	Username    string    `json:"username"`
+	Email       string    `json:"email" validate:"required,email,max=254"`
+	FirstName   string    `json:"first_name" validate:"max=150"`
+	LastName    string    `json:"last_name" validate:"max=150"`
+	PhoneNumber string    `json:"phone_number" validate:"max=20"`
+	Username    string    `json:"username" validate:"max=150"`
 	Password    string    `json:"password"`
 	LastLogin   time.Time `json:"last_login"`
 	IsSuperUser bool      `json:"is_superuser"`
@@ -155,13 +167,100 @@ type UserRequest struct {
 	IsActive    bool      `json:"is_active"`
 	IsVerified  bool      `json:"verified"`
 	// not quite sure properties in legacy db is data type array, should be string and has a separator (; or ,)
-	Properties string `db:"properties"`
+	Properties string `json:"properties" db:"properties"`
 
 	// corporate_account_id is a FK from table corporate_partner
-	CorporateAccountID int64 `db:"corporate_account_id"`
+	CorporateAccountID int64 `json:"corporate_account_id" db:"corporate_account_id"`
 
 	// new added, a FK from table author
-	AuthorID int64 `db:"author_id"`
+	AuthorID int64 `json:"author_id" db:"author_id"`
+}
+
+// ToUser map request into user, password and last login are left to the caller
+func (req UserRequest) ToUser() User {
+	return User{
+		Email:              req.Email,
+		FirstName:          utils.ValueToPtr(req.FirstName),
+		LastName:           utils.ValueToPtr(req.LastName),
+		PhoneNumber:        utils.ValueToPtr(fieldcrypt.EncryptedString(req.PhoneNumber)),
+		Username:           utils.ValueToPtr(req.Username),
+		IsSuperUser:        req.IsSuperUser,
+		IsStaff:            req.IsStaff,
+		IsActive:           req.IsActive,
+		IsVerified:         req.IsVerified,
+		Properties:         req.Properties,
+		CorporateAccountID: req.CorporateAccountID,
+		AuthorID:           req.AuthorID,
+	}
+}
+
+// RegisterUserRequest self-registration, the privileges are not part of it so a client cannot
+// grant them to itself, staff grant them with UpdateUserRequest
+type RegisterUserRequest struct {
+	Email       string `json:"email" validate:"required,email,max=254"`
+	FirstName   string `json:"first_name" validate:"max=150"`
+	LastName    string `json:"last_name" validate:"max=150"`
+	PhoneNumber string `json:"phone_number" validate:"max=20"`
+	Username    string `json:"username" validate:"max=150"`
+}
+
+// ToUser map the registration into an active user without privileges
+func (req RegisterUserRequest) ToUser() User {
+	return User{
+		Email:       req.Email,
+		FirstName:   utils.ValueToPtr(req.FirstName),
+		LastName:    utils.ValueToPtr(req.LastName),
+		PhoneNumber: utils.ValueToPtr(fieldcrypt.EncryptedString(req.PhoneNumber)),
+		Username:    utils.ValueToPtr(req.Username),
+		IsSuperUser: false,
+		IsStaff:     false,
+		IsActive:    true,
+		IsVerified:  false,
+	}
+}
+
+// UpdateProfileRequest self-service profile edit, only the non-nil fields are updated
+type UpdateProfileRequest struct {
+	FirstName   *string    `json:"first_name" validate:"omitempty,max=150"`
+	LastName    *string    `json:"last_name" validate:"omitempty,max=150"`
+	PhoneNumber *string    `json:"phone_number" validate:"omitempty,max=20"`
+	BirthPlace  *string    `json:"birth_place" validate:"omitempty,max=100"`
+	BirthDate   *time.Time `json:"birth_date"`
+	Gender      *string    `json:"gender" validate:"omitempty,max=10"`
+	HomePhone   *string    `json:"home_phone_number" validate:"omitempty,max=20"`
+	Job         *string    `json:"occupation" validate:"omitempty,max=100"`
+	Hobby       *string    `json:"hobby" validate:"omitempty,max=255"`
+}
+
+// ApplyTo copy the requested changes into user
+func (req UpdateProfileRequest) ApplyTo(user *User) {
+	if req.FirstName != nil {
+		user.FirstName = req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = req.LastName
+	}
+	if req.PhoneNumber != nil {
+		user.PhoneNumber = utils.ValueToPtr(fieldcrypt.EncryptedString(*req.PhoneNumber))
+	}
+	if req.BirthPlace != nil {
+		user.BirthPlace = req.BirthPlace
+	}
+	if req.BirthDate != nil {
+		user.BirthDate = &fieldcrypt.EncryptedTime{Time: *req.BirthDate}
+	}
+	if req.Gender != nil {
+		user.Gender = req.Gender
+	}
+	if req.HomePhone != nil {
+		user.HomePhone = utils.ValueToPtr(fieldcrypt.EncryptedString(*req.HomePhone))
+	}
+	if req.Job != nil {
+		user.Job = req.Job
+	}
+	if req.Hobby != nil {
+		user.Hobby = req.Hobby
+	}
 }
 
 type ErrorMessageCode string
@@ -186,14 +285,96 @@ func (code ErrorMessageCode) String() string {
 	return errorMessages[code]
 }
 
+// Error the message, the code is the error returned for the invalid field
+func (code ErrorMessageCode) Error() string {
+	return code.String()
+}
+
 // String converts to its string representation
 func (code ErrorMessageCode) Code() string {
 	return string(code)
 }
 
 type ProfileResponse struct {
-	Email        string `json:"email"`
-	Fullname     string `json:"fullname"`
-	MyValuePoint int    `json:"myvalue_point"`
+	Email    string `json:"email"`
+	Fullname string `json:"fullname"`
+	// MyValuePoint null when MyValue is unavailable, a missing balance is not a zero balance
+	MyValuePoint *int   `json:"myvalue_point"`
 	Avatar       string `json:"avatar"`
+	// ETag sent in the ETag header, If-Match of the next update
+	ETag string `json:"-"`
+}
+
+// UpdateUserRequest staff edit of a user, only the non-nil fields are updated
+type UpdateUserRequest struct {
+	UpdateProfileRequest
+	IsActive *bool `json:"is_active"`
+	// IsStaff is only set by a superuser
+	IsStaff    *bool `json:"is_staff"`
+	IsVerified *bool `json:"verified"`
+}
+
+// ApplyTo copy the requested changes into user
+func (req UpdateUserRequest) ApplyTo(user *User) {
+	req.UpdateProfileRequest.ApplyTo(user)
+	if req.IsActive != nil {
+		user.IsActive = *req.IsActive
+	}
+	if req.IsStaff != nil {
+		user.IsStaff = *req.IsStaff
+	}
+	if req.IsVerified != nil {
+		user.IsVerified = *req.IsVerified
+	}
+}
+
+// UserResponse user as seen by staff, the encrypted columns are left out
+type UserResponse struct {
+	ID         int64     `json:"id"`
+	Email      string    `json:"email"`
+	Username   *string   `json:"username"`
+	FirstName  *string   `json:"first_name"`
+	LastName   *string   `json:"last_name"`
+	IsActive   bool      `json:"is_active"`
+	IsStaff    bool      `json:"is_staff"`
+	IsVerified bool      `json:"verified"`
+	DateJoined time.Time `json:"date_joined"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// ETag sent in the ETag header, If-Match of the next update
+	ETag string `json:"-"`
+}
+
+// ToUserResponse map user into the staff view
+func (u User) ToUserResponse() UserResponse {
+	return UserResponse{
+		ID:         u.ID,
+		Email:      u.Email,
+		Username:   u.Username,
+		FirstName:  u.FirstName,
+		LastName:   u.LastName,
+		IsActive:   u.IsActive,
+		IsStaff:    u.IsStaff,
+		IsVerified: u.IsVerified,
+		DateJoined: u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+		ETag:       u.ETag(),
+	}
+}
+
+const (
+	// Status of the encryption key rotation
+	KEY_ROTATION_IDLE    = "idle"
+	KEY_ROTATION_RUNNING = "running"
+	KEY_ROTATION_DONE    = "done"
+	KEY_ROTATION_FAILED  = "failed"
+)
+
+// EncryptionKeyRotation progress of the background rewrap of the PII columns on this instance
+type EncryptionKeyRotation struct {
+	Status string `json:"status"`
+	// Rotated number of users whose PII columns were rewrapped so far
+	Rotated    int        `json:"rotated"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
 }
@@ -0,0 +1,85 @@
+package model
+
+const (
+	// Bulk file format
+	BULK_FORMAT_CSV    = "csv"
+	BULK_FORMAT_NDJSON = "ndjson"
+
+	// Import row status
+	IMPORT_STATUS_CREATED = "created"
+	IMPORT_STATUS_UPDATED = "updated"
+	IMPORT_STATUS_SKIPPED = "skipped"
+	IMPORT_STATUS_INVALID = "invalid"
+
+	// IMPORT_REPORT_MAX_ROWS rows detailed in the import report, the counts cover every row
+	IMPORT_REPORT_MAX_ROWS = 1000
+)
+
+// UserBulkColumns column order of csv import and export, the names follow the json tag of UserRequest
+var UserBulkColumns = []string{
+	"email",
+	"first_name",
+	"last_name",
+	"phone_number",
+	"username",
+	"last_login",
+	"is_superuser",
+	"is_staff",
+	"is_active",
+	"verified",
+	"properties",
+	"corporate_account_id",
+	"author_id",
+}
+
+type (
+	// ImportUserOptions options of bulk import
+	ImportUserOptions struct {
+		Format string
+		// DryRun only validate and report what would happen
+		DryRun bool
+		// Upsert update the existing user with the same email instead of skipping the row
+		Upsert bool
+	}
+
+	// ImportUserReport result of bulk import
+	ImportUserReport struct {
+		DryRun  bool               `json:"dry_run"`
+		Total   int                `json:"total"`
+		Created int                `json:"created"`
+		Updated int                `json:"updated"`
+		Skipped int                `json:"skipped"`
+		Invalid int                `json:"invalid"`
+		Rows    []ImportUserResult `json:"rows"`
+		// Truncated rows holds only the first IMPORT_REPORT_MAX_ROWS results
+		Truncated bool `json:"truncated"`
+	}
+
+	// ImportUserResult result of a single row, row is 1-based and excludes the csv header
+	ImportUserResult struct {
+		Row    int      `json:"row"`
+		Email  string   `json:"email,omitempty"`
+		Status string   `json:"status"`
+		Errors []string `json:"errors,omitempty"`
+	}
+)
+
+// Add count the row result into the report, it is detailed in rows up to IMPORT_REPORT_MAX_ROWS
+func (r *ImportUserReport) Add(result ImportUserResult) {
+	r.Total++
+	switch result.Status {
+	case IMPORT_STATUS_CREATED:
+		r.Created++
+	case IMPORT_STATUS_UPDATED:
+		r.Updated++
+	case IMPORT_STATUS_SKIPPED:
+		r.Skipped++
+	case IMPORT_STATUS_INVALID:
+		r.Invalid++
+	}
+	if len(r.Rows) >= IMPORT_REPORT_MAX_ROWS {
+		r.Truncated = true
+		return
+	}
+	r.Rows = append(r.Rows, result)
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"reflect"
+	"time"
+)
+
+const (
+	// User history action
+	USER_HISTORY_CREATE = "create"
+	USER_HISTORY_UPDATE = "update"
+
+	// REDACTED value of a sensitive field in the history
+	REDACTED = "[redacted]"
+)
+
+// sensitiveUserColumns the change is recorded but never the value
+var sensitiveUserColumns = map[string]bool{
+	"password":          true,
+	"phone_number":      true,
+	"home_phone_number": true,
+	"birth_date":        true,
+	"identity_image":    true,
+	"identity_number":   true,
+}
+
+// ignoredUserColumns derived or bookkeeping columns that are not worth a history entry
+var ignoredUserColumns = map[string]bool{
+	"phone_number_bidx": true,
+	"updated_at":        true,
+}
+
+type (
+	// UserHistory before/after diff of a single change on a user
+	UserHistory struct {
+		ID        string        `json:"id" bson:"_id"`
+		UserID    int64         `json:"user_id" bson:"user_id"`
+		Action    string        `json:"action" bson:"action"`
+		ActorUID  string        `json:"actor_uid" bson:"actor_uid"`
+		RequestID string        `json:"request_id" bson:"request_id"`
+		Changes   []FieldChange `json:"changes" bson:"changes"`
+		CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+	}
+
+	// FieldChange change of one column, sensitive values are redacted
+	FieldChange struct {
+		Field  string      `json:"field" bson:"field"`
+		Before interface{} `json:"before" bson:"before"`
+		After  interface{} `json:"after" bson:"after"`
+	}
+
+	// PaginationMeta meta of a paginated response
+	PaginationMeta struct {
+		Page  int   `json:"page"`
+		Size  int   `json:"size"`
+		Total int64 `json:"total"`
+	}
+)
+
+// DiffUser list the columns that differ between before and after, keyed by the db tag
+func DiffUser(before, after User) []FieldChange {
+	changes := []FieldChange{}
+	beforeValue := reflect.ValueOf(before)
+	afterValue := reflect.ValueOf(after)
+	userType := beforeValue.Type()
+
+	for i := 0; i < userType.NumField(); i++ {
+		field := userType.Field(i).Tag.Get("db")
+		if field == "" || ignoredUserColumns[field] {
+			continue
+		}
+
+		b := indirect(beforeValue.Field(i))
+		a := indirect(afterValue.Field(i))
+		if equalValue(b, a) {
+			continue
+		}
+
+		if sensitiveUserColumns[field] {
+			b, a = redact(b), redact(a)
+		}
+		changes = append(changes, FieldChange{Field: field, Before: b, After: a})
+	}
+
+	return changes
+}
+
+// indirect dereference pointer fields so the diff holds plain values, nil stays nil
+func indirect(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+// equalValue times are equal when they are the same instant, whatever their location or monotonic
+// reading (e.g. a time read back from postgres and the one written)
+func equalValue(b, a interface{}) bool {
+	if bt, ok := b.(time.Time); ok {
+		at, ok := a.(time.Time)
+		return ok && bt.Equal(at)
+	}
+	return reflect.DeepEqual(b, a)
+}
+
+func redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return REDACTED
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffUserTimes(t *testing.T) {
+	lastLogin := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := User{LastLogin: &lastLogin}
+
+	// the same instant read back in another location
+	sameInstant := lastLogin.In(time.FixedZone("WIB", 7*60*60))
+	assert.Empty(t, DiffUser(before, User{LastLogin: &sameInstant}))
+
+	later := lastLogin.Add(time.Second)
+	changes := DiffUser(before, User{LastLogin: &later})
+	assert.Equal(t, []FieldChange{{Field: "last_login", Before: lastLogin, After: later}}, changes)
+}
@@ -0,0 +1,97 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultImageTimeout = 30 * time.Second
+
+type (
+	// ImageOutbound client of the image storage behind IMAGE_BASE_URL
+	ImageOutbound interface {
+		Download(ctx context.Context, path string) (io.ReadCloser, error)
+		Delete(ctx context.Context, path string) error
+	}
+
+	// ImageOutboundImpl implementation
+	ImageOutboundImpl struct {
+		config     model.Config
+		httpClient *http.Client
+	}
+)
+
+// NewImageOutbound initialize image storage client
+func NewImageOutbound(config model.Config) ImageOutbound {
+	return &ImageOutboundImpl{
+		config:     config,
+		httpClient: newHTTPClient(defaultImageTimeout),
+	}
+}
+
+// Download open the image stored at path, the caller must close it
+func (o *ImageOutboundImpl) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ImageOutboundImpl.Download")
+	defer span.Finish()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, utils.ErrorNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		log.Ctx(ctx).Error().Msgf("error when download image, status: %d", resp.StatusCode)
+		return nil, utils.ErrorInternalServerThirdParty
+	}
+
+	return resp.Body, nil
+}
+
+// Delete remove the image stored at path, an image that is already gone is not an error
+func (o *ImageOutboundImpl) Delete(ctx context.Context, path string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ImageOutboundImpl.Delete")
+	defer span.Finish()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, o.url(path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		log.Ctx(ctx).Error().Msgf("error when delete image, status: %d", resp.StatusCode)
+		return utils.ErrorInternalServerThirdParty
+	}
+}
+
+// url IMAGE_BASE_URL uses a trailing slash
+func (o *ImageOutboundImpl) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return fmt.Sprintf("%s%s", o.config.Image.BaseURL, strings.TrimLeft(path, "/"))
+}
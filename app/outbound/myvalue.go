@@ -0,0 +1,192 @@
+package outbound
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	myValuePointPath = "/api/v1/points/balance"
+
+	defaultMyValueTimeout        = 3 * time.Second
+	defaultMyValuePointCacheTTL  = time.Hour
+	defaultMyValuePointCacheSize = 10000
+)
+
+type (
+	// MyValueOutbound client of MyValue API
+	MyValueOutbound interface {
+		GetPointBalance(ctx context.Context, email string) (int, error)
+	}
+
+	// MyValueOutboundImpl implementation
+	MyValueOutboundImpl struct {
+		config     model.Config
+		httpClient *http.Client
+		timeout    time.Duration
+		pointCache *pointCache
+	}
+
+	// pointCache last known balances, the least recently used one is evicted beyond size. It is
+	// keyed by the hash of the email so no email is kept in memory.
+	pointCache struct {
+		ttl  time.Duration
+		size int
+
+		mu      sync.Mutex
+		entries map[[sha256.Size]byte]*list.Element
+		order   *list.List
+	}
+
+	cachedPoint struct {
+		key       [sha256.Size]byte
+		point     int
+		fetchedAt time.Time
+	}
+
+	myValuePointResponse struct {
+		Data struct {
+			Point int `json:"point"`
+		} `json:"data"`
+	}
+)
+
+// NewMyValueOutbound initialize MyValue client
+func NewMyValueOutbound(config model.Config) MyValueOutbound {
+	timeout := defaultMyValueTimeout
+	if config.MyValue.Timeout > 0 {
+		timeout = time.Duration(config.MyValue.Timeout) * time.Second
+	}
+	cacheTTL := defaultMyValuePointCacheTTL
+	if config.MyValue.PointCacheTTL > 0 {
+		cacheTTL = time.Duration(config.MyValue.PointCacheTTL) * time.Second
+	}
+	cacheSize := defaultMyValuePointCacheSize
+	if config.MyValue.PointCacheSize > 0 {
+		cacheSize = config.MyValue.PointCacheSize
+	}
+
+	return &MyValueOutboundImpl{
+		config:     config,
+		httpClient: newHTTPClient(0),
+		timeout:    timeout,
+		pointCache: newPointCache(cacheTTL, cacheSize),
+	}
+}
+
+// GetPointBalance get the points balance of the user, when MyValue is slow or down
+// the last known balance is returned as long as it is not older than the cache ttl
+func (o *MyValueOutboundImpl) GetPointBalance(ctx context.Context, email string) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MyValueOutboundImpl.GetPointBalance")
+	defer span.Finish()
+
+	point, err := o.fetchPointBalance(ctx, email)
+	if err == nil {
+		o.pointCache.set(email, point)
+		return point, nil
+	}
+
+	span.SetTag("Error", true)
+	span.LogKV("ErrorMsg", err.Error())
+
+	if point, ok := o.pointCache.get(email); ok {
+		log.Ctx(ctx).Warn().Msgf("myvalue point balance unavailable, serving cached value, err: %v", err)
+		span.SetTag("cache", "fallback")
+		return point, nil
+	}
+
+	return 0, err
+}
+
+func (o *MyValueOutboundImpl) fetchPointBalance(ctx context.Context, email string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s%s?email=%s", strings.TrimRight(o.config.MyValue.BaseURL, "/"), myValuePointPath, url.QueryEscape(email))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(o.config.MyValue.ClientID, o.config.MyValue.ClientSecret)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Ctx(ctx).Error().Msgf("error when get myvalue point balance, status: %d", resp.StatusCode)
+		return 0, utils.ErrorInternalServerThirdParty
+	}
+
+	var body myValuePointResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+
+	return body.Data.Point, nil
+}
+
+func newPointCache(ttl time.Duration, size int) *pointCache {
+	return &pointCache{
+		ttl:     ttl,
+		size:    size,
+		entries: map[[sha256.Size]byte]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// get the balance of email when it is not older than the ttl, an expired one is removed
+func (c *pointCache) get(email string) (int, bool) {
+	key := sha256.Sum256([]byte(email))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+	cached := element.Value.(cachedPoint)
+	if time.Since(cached.fetchedAt) > c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return 0, false
+	}
+	c.order.MoveToFront(element)
+	return cached.point, true
+}
+
+// set the balance of email and evict the least recently used ones beyond the size
+func (c *pointCache) set(email string, point int) {
+	key := sha256.Sum256([]byte(email))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached := cachedPoint{key: key, point: point, fetchedAt: time.Now()}
+	if element, ok := c.entries[key]; ok {
+		element.Value = cached
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(cached)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(cachedPoint).key)
+	}
+}
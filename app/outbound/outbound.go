@@ -1,3 +1,38 @@
 package outbound
 
 // for service third party
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+)
+
+// requestIDTransport forward the request id of the incoming request so the call can be traced
+// across the services
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+// newHTTPClient client of the third party services
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: requestIDTransport{base: http.DefaultTransport},
+	}
+}
+
+// RoundTrip set the request-id header when the context has one and the caller did not set it
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := model.RequestIDFromContext(req.Context())
+	if requestID == "" || req.Header.Get(constant.RequestID) != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set(constant.RequestID, requestID)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,60 @@
+package outbound
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDForwarded(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(constant.RequestID)
+	}))
+	defer server.Close()
+
+	client := newHTTPClient(0)
+	ctx := model.ContextWithRequestID(context.Background(), "01HF8Z6Q3J6W6X0Y2K9V1B4C7D")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, "01HF8Z6Q3J6W6X0Y2K9V1B4C7D", received)
+	assert.Empty(t, req.Header.Get(constant.RequestID))
+
+	req, err = http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Empty(t, received)
+}
+
+func TestPointCache(t *testing.T) {
+	cache := newPointCache(time.Hour, 2)
+	cache.set("jane.doe@example.com", 10)
+	cache.set("john.doe@example.com", 20)
+
+	// jane is used last, john is evicted
+	point, ok := cache.get("jane.doe@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, 10, point)
+	cache.set("budi@example.com", 30)
+	_, ok = cache.get("john.doe@example.com")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.order.Len())
+
+	expired := newPointCache(0, 2)
+	expired.set("jane.doe@example.com", 10)
+	_, ok = expired.get("jane.doe@example.com")
+	assert.False(t, ok)
+	assert.Zero(t, expired.order.Len())
+}
@@ -0,0 +1,41 @@
+package outbound
+
+import (
+	"context"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// Publisher deliver the domain events of the outbox to the message broker, an event may be
+	// delivered more than once so consumers must be idempotent on the event id
+	Publisher interface {
+		Publish(ctx context.Context, event model.OutboxEvent) error
+	}
+
+	// LogPublisher write the events to the log, used until a message broker is configured
+	LogPublisher struct{}
+)
+
+// NewPublisher initialize publisher of the domain events
+func NewPublisher(config model.Config) Publisher {
+	return LogPublisher{}
+}
+
+// Publish log the event
+func (p LogPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "LogPublisher.Publish")
+	defer span.Finish()
+
+	log.Info().
+		Int64("event_id", event.ID).
+		Str("event_type", event.EventType).
+		Str("aggregate_type", event.AggregateType).
+		Str("aggregate_id", event.AggregateID).
+		RawJSON("payload", []byte(event.Payload)).
+		Msg("publish event")
+
+	return nil
+}
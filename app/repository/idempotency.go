@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+var (
+	TableIdempotency = fmt.Sprintf("%v.%v", "public", "idempotency")
+
+	idempotencyColumns = `scope, idempotency_key, fingerprint, status, response_status, response_content_type,
+		response_body, locked_until, expires_at, created_at`
+)
+
+type (
+
+	// Repository Inteface
+	IdempotencyRepository interface {
+		// Acquire insert the processing key, nil when the caller owns it. Otherwise the stored key is
+		// returned: an expired key and a processing key whose lock ran out with the same fingerprint
+		// are taken over.
+		Acquire(ctx context.Context, idempotency model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+		// Complete store the response of the processing key with the same fingerprint
+		Complete(ctx context.Context, idempotency model.IdempotencyRecord) error
+		// Release delete the processing key with the same fingerprint so a retry runs again
+		Release(ctx context.Context, scope, key, fingerprint string) error
+		// DeleteExpired remove the expired keys, returns how many were removed
+		DeleteExpired(ctx context.Context) (int64, error)
+	}
+
+	// Implementation
+	IdempotencyRepositoryImpl struct {
+		postgresCollection database.PostgresCollection
+	}
+)
+
+// New Repository Idempotency
+func NewIdempotencyRepository(postgresCollection database.PostgresCollection) IdempotencyRepository {
+	return IdempotencyRepositoryImpl{
+		postgresCollection: postgresCollection,
+	}
+}
+
+// Acquire insert or take over the key in one statement, the stored key is read when neither happened
+func (r IdempotencyRepositoryImpl) Acquire(ctx context.Context, idempotency model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	if idempotency.CreatedAt.IsZero() {
+		idempotency.CreatedAt = utils.TimeNow()
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s AS i (scope, idempotency_key, fingerprint, status, locked_until, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (scope, idempotency_key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = EXCLUDED.status,
+			response_status = 0, response_content_type = '', response_body = NULL, locked_until = EXCLUDED.locked_until,
+			expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+		WHERE i.expires_at <= $8
+			OR (i.status = $4 AND i.locked_until <= $8 AND i.fingerprint = EXCLUDED.fingerprint)
+		RETURNING idempotency_key`, TableIdempotency)
+	var key string
+	err := r.postgresCollection.Writer(ctx).GetContext(ctx, &key, query,
+		idempotency.Scope, idempotency.Key, idempotency.Fingerprint, model.IDEMPOTENCY_STATUS_PROCESSING,
+		idempotency.LockedUntil, idempotency.ExpiresAt, idempotency.CreatedAt, utils.TimeNow())
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// the stored key is read on the writer, a replica may not have it yet
+	query = fmt.Sprintf("SELECT %s FROM %s WHERE scope = $1 AND idempotency_key = $2", idempotencyColumns, TableIdempotency)
+	stored := model.IdempotencyRecord{}
+	err = r.postgresCollection.Writer(ctx).GetContext(ctx, &stored, query, idempotency.Scope, idempotency.Key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// purged in between, the caller retries later like for a key in use
+		stored.Status = model.IDEMPOTENCY_STATUS_PROCESSING
+		stored.Fingerprint = idempotency.Fingerprint
+		return &stored, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// Complete set the key completed with its response
+func (r IdempotencyRepositoryImpl) Complete(ctx context.Context, idempotency model.IdempotencyRecord) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, response_status = $2, response_content_type = $3, response_body = $4
+		WHERE scope = $5 AND idempotency_key = $6 AND fingerprint = $7 AND status = $8`, TableIdempotency)
+	_, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, model.IDEMPOTENCY_STATUS_COMPLETED,
+		idempotency.ResponseStatus, idempotency.ResponseContentType, idempotency.ResponseBody,
+		idempotency.Scope, idempotency.Key, idempotency.Fingerprint, model.IDEMPOTENCY_STATUS_PROCESSING)
+
+	return err
+}
+
+// Release delete the processing key
+func (r IdempotencyRepositoryImpl) Release(ctx context.Context, scope, key, fingerprint string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE scope = $1 AND idempotency_key = $2 AND fingerprint = $3 AND status = $4`, TableIdempotency)
+	_, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, scope, key, fingerprint, model.IDEMPOTENCY_STATUS_PROCESSING)
+
+	return err
+}
+
+// DeleteExpired remove the keys past their expiry
+func (r IdempotencyRepositoryImpl) DeleteExpired(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= $1`, TableIdempotency)
+	res, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, utils.TimeNow())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils/ulid"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	CollectionLogHTTP = "log_http"
+
+	// DEFAULT_LOG_HTTP_BUFFER_SIZE used when DB_LOG_HTTP_BUFFER_SIZE is not set
+	DEFAULT_LOG_HTTP_BUFFER_SIZE = 1000
+	// DEFAULT_LOG_HTTP_TTL used when DB_LOG_HTTP_TTL is not set
+	DEFAULT_LOG_HTTP_TTL = 30 * 24 * time.Hour
+
+	// logHTTPBatchSize logs inserted per query
+	logHTTPBatchSize = 100
+	// logHTTPFlushInterval a partial batch is inserted after this long
+	logHTTPFlushInterval = time.Second
+	// logHTTPTTLIndex name mongodb gives the index of created_at
+	logHTTPTTLIndex = "created_at_1"
+)
+
+type (
+
+	// Repository Inteface
+	LogHTTPRepository interface {
+		// Write queue the log without blocking, the log is dropped when the buffer is full. The body
+		// must be redacted by the caller, see middleware.MapLogHTTP, and is cut to
+		// model.MAX_LOG_HTTP_BODY_SIZE.
+		Write(logHTTP model.LogHTTP)
+		Search(ctx context.Context, filter model.LogHTTPFilter, page, size int) ([]model.LogHTTP, int64, error)
+		// Dropped logs lost because the buffer was full or the insert failed
+		Dropped() int64
+		// Close stop accepting logs and insert the buffered ones until ctx is done
+		Close(ctx context.Context) error
+	}
+
+	// Implementation
+	LogHTTPRepositoryImpl struct {
+		mongoCollection database.MongoCollection
+		buffer          chan model.LogHTTP
+		dropped         atomic.Int64
+		done            chan struct{}
+
+		// mu guards closed, Write must not send on the closed buffer
+		mu     sync.RWMutex
+		closed bool
+	}
+)
+
+// New Repository Log HTTP, the logs are kept in the log database and removed after ttl
+func NewLogHTTPRepository(mongoCollection database.MongoCollection, bufferSize int, ttl time.Duration) LogHTTPRepository {
+	if bufferSize <= 0 {
+		bufferSize = DEFAULT_LOG_HTTP_BUFFER_SIZE
+	}
+	if ttl <= 0 {
+		ttl = DEFAULT_LOG_HTTP_TTL
+	}
+
+	r := &LogHTTPRepositoryImpl{
+		mongoCollection: mongoCollection,
+		buffer:          make(chan model.LogHTTP, bufferSize),
+		done:            make(chan struct{}),
+	}
+
+	// the log database may start degraded, the indexes are created once it is up
+	mongoCollection.State.OnUp(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := r.createIndexes(ctx, int32(ttl.Seconds())); err != nil {
+			log.Error().Msgf("error when create index of %s, err: %v", CollectionLogHTTP, err)
+		}
+	})
+
+	go r.run()
+
+	return r
+}
+
+// createIndexes of the search and the ttl, the ttl of an existing index is changed in place since
+// creating it again with another ttl conflicts
+func (r *LogHTTPRepositoryImpl) createIndexes(ctx context.Context, ttlSeconds int32) error {
+	ttlKeys := bson.D{{Key: "created_at", Value: 1}}
+
+	cursor, err := r.collection(false).Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var indexes []struct {
+		Name               string `bson:"name"`
+		ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if index.Name != logHTTPTTLIndex || index.ExpireAfterSeconds == nil || *index.ExpireAfterSeconds == int64(ttlSeconds) {
+			continue
+		}
+		err := r.collection(false).Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: CollectionLogHTTP},
+			{Key: "index", Value: bson.D{{Key: "keyPattern", Value: ttlKeys}, {Key: "expireAfterSeconds", Value: ttlSeconds}}},
+		}).Err()
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("ttl of %s changed from %ds to %ds", CollectionLogHTTP, *index.ExpireAfterSeconds, ttlSeconds)
+	}
+
+	_, err = r.collection(false).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    ttlKeys,
+			Options: options.Index().SetName(logHTTPTTLIndex).SetExpireAfterSeconds(ttlSeconds),
+		},
+		{Keys: bson.D{{Key: "request_id", Value: 1}}},
+		{Keys: bson.D{{Key: "uri", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+func (r *LogHTTPRepositoryImpl) collection(read bool) *mongo.Collection {
+	if read {
+		return r.mongoCollection.MessageSlave.Collection(CollectionLogHTTP)
+	}
+	return r.mongoCollection.MessageMaster.Collection(CollectionLogHTTP)
+}
+
+// Write queue the log, the request never waits for the log database
+func (r *LogHTTPRepositoryImpl) Write(logHTTP model.LogHTTP) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+	if logHTTP.ID == "" {
+		logHTTP.ID = ulid.GenerateUlidID()
+	}
+	logHTTP = logHTTP.Capped()
+
+	select {
+	case r.buffer <- logHTTP:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped logs lost since startup
+func (r *LogHTTPRepositoryImpl) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close stop accepting logs and wait for the buffered ones to be inserted
+func (r *LogHTTPRepositoryImpl) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.buffer)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run insert the buffered logs in batches until the buffer is closed
+func (r *LogHTTPRepositoryImpl) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(logHTTPFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]interface{}, 0, logHTTPBatchSize)
+	for {
+		select {
+		case logHTTP, ok := <-r.buffer:
+			if !ok {
+				r.insert(batch)
+				return
+			}
+			batch = append(batch, logHTTP)
+			if len(batch) >= logHTTPBatchSize {
+				r.insert(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.insert(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (r *LogHTTPRepositoryImpl) insert(batch []interface{}) {
+	if len(batch) == 0 {
+		return
+	}
+	if !r.mongoCollection.State.IsUp() {
+		r.dropped.Add(int64(len(batch)))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := r.collection(false).InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+	if err != nil {
+		inserted := 0
+		if res != nil {
+			inserted = len(res.InsertedIDs)
+		}
+		r.dropped.Add(int64(len(batch) - inserted))
+		log.Error().Msgf("error when insert %s, dropped: %d, err: %v", CollectionLogHTTP, len(batch)-inserted, err)
+	}
+}
+
+// Search list the logs matching filter, newest first
+func (r *LogHTTPRepositoryImpl) Search(ctx context.Context, filter model.LogHTTPFilter, page, size int) ([]model.LogHTTP, int64, error) {
+	query := bson.M{}
+	if filter.RequestID != "" {
+		query["request_id"] = filter.RequestID
+	}
+	if filter.URI != "" {
+		// anchored prefix so the uri index is used
+		query["uri"] = bson.M{"$regex": "^" + regexp.QuoteMeta(filter.URI)}
+	}
+	if filter.Status != 0 {
+		query["status"] = filter.Status
+	}
+
+	total, err := r.collection(true).CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := r.collection(true).Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	logs := []model.LogHTTP{}
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
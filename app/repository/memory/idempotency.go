@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sync"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+// IdempotencyRepository in-memory repository.IdempotencyRepository
+type IdempotencyRepository struct {
+	mu   sync.Mutex
+	keys map[[2]string]model.IdempotencyRecord
+}
+
+// NewIdempotencyRepository empty idempotency repository
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{keys: map[[2]string]model.IdempotencyRecord{}}
+}
+
+var _ repository.IdempotencyRepository = (*IdempotencyRepository)(nil)
+
+func (r *IdempotencyRepository) snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := maps.Clone(r.keys)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.keys = keys
+	}
+}
+
+// Acquire insert the processing key or take over an expired or abandoned one
+func (r *IdempotencyRepository) Acquire(ctx context.Context, idempotency model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	if idempotency.CreatedAt.IsZero() {
+		idempotency.CreatedAt = utils.TimeNow()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := [2]string{idempotency.Scope, idempotency.Key}
+	if stored, ok := r.keys[id]; ok {
+		due := utils.TimeNow()
+		expired := !stored.ExpiresAt.After(due)
+		abandoned := stored.Status == model.IDEMPOTENCY_STATUS_PROCESSING && !stored.LockedUntil.After(due) &&
+			stored.Fingerprint == idempotency.Fingerprint
+		if !expired && !abandoned {
+			return &stored, nil
+		}
+	}
+
+	r.keys[id] = model.IdempotencyRecord{
+		Scope:       idempotency.Scope,
+		Key:         idempotency.Key,
+		Fingerprint: idempotency.Fingerprint,
+		Status:      model.IDEMPOTENCY_STATUS_PROCESSING,
+		LockedUntil: idempotency.LockedUntil,
+		ExpiresAt:   idempotency.ExpiresAt,
+		CreatedAt:   idempotency.CreatedAt,
+	}
+	return nil, nil
+}
+
+// Complete store the response of the processing key
+func (r *IdempotencyRepository) Complete(ctx context.Context, idempotency model.IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := [2]string{idempotency.Scope, idempotency.Key}
+	stored, ok := r.keys[id]
+	if !ok || stored.Status != model.IDEMPOTENCY_STATUS_PROCESSING || stored.Fingerprint != idempotency.Fingerprint {
+		return nil
+	}
+
+	stored.Status = model.IDEMPOTENCY_STATUS_COMPLETED
+	stored.ResponseStatus = idempotency.ResponseStatus
+	stored.ResponseContentType = idempotency.ResponseContentType
+	stored.ResponseBody = append([]byte(nil), idempotency.ResponseBody...)
+	r.keys[id] = stored
+	return nil
+}
+
+// Release delete the processing key
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key, fingerprint string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := [2]string{scope, key}
+	if stored, ok := r.keys[id]; ok && stored.Status == model.IDEMPOTENCY_STATUS_PROCESSING && stored.Fingerprint == fingerprint {
+		delete(r.keys, id)
+	}
+	return nil
+}
+
+// DeleteExpired remove the keys past their expiry
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := utils.TimeNow()
+	var deleted int64
+	for id, stored := range r.keys {
+		if !stored.ExpiresAt.After(due) {
+			delete(r.keys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils/ulid"
+)
+
+// LogHTTPRepository in-memory repository.LogHTTPRepository, Write stores the log right away
+type LogHTTPRepository struct {
+	mu      sync.RWMutex
+	logs    []model.LogHTTP
+	closed  bool
+	dropped atomic.Int64
+}
+
+// NewLogHTTPRepository empty http log repository
+func NewLogHTTPRepository() *LogHTTPRepository {
+	return &LogHTTPRepository{}
+}
+
+var _ repository.LogHTTPRepository = (*LogHTTPRepository)(nil)
+
+// Write store the log, dropped after Close
+func (r *LogHTTPRepository) Write(logHTTP model.LogHTTP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+	if logHTTP.ID == "" {
+		logHTTP.ID = ulid.GenerateUlidID()
+	}
+	r.logs = append(r.logs, logHTTP.Capped())
+}
+
+// Search list the logs matching filter, newest first
+func (r *LogHTTPRepository) Search(ctx context.Context, filter model.LogHTTPFilter, page, size int) ([]model.LogHTTP, int64, error) {
+	r.mu.RLock()
+	matched := []model.LogHTTP{}
+	for _, logHTTP := range r.logs {
+		if filter.RequestID != "" && logHTTP.RequestID != filter.RequestID {
+			continue
+		}
+		if filter.URI != "" && !strings.HasPrefix(logHTTP.URI, filter.URI) {
+			continue
+		}
+		if filter.Status != 0 && logHTTP.Status != filter.Status {
+			continue
+		}
+		matched = append(matched, logHTTP)
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+	return paginate(matched, page, size), int64(len(matched)), nil
+}
+
+// Dropped logs written after Close
+func (r *LogHTTPRepository) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close stop accepting logs
+func (r *LogHTTPRepository) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
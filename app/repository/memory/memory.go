@@ -0,0 +1,81 @@
+// Package memory in-memory implementations of the repositories for hermetic service and handler
+// tests. They follow the behaviour of the real repositories checked by repositorytest, not their
+// performance: every call takes a lock and scans everything.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+type (
+	// TxManager database.TxManager restoring its repositories to their state before fn when fn
+	// fails, a nested call restores only the work of the inner fn like a savepoint. The calls are
+	// not isolated from each other.
+	TxManager struct {
+		repos []Snapshotter
+	}
+
+	// Snapshotter in-memory repository of a transactional database, snapshot copies its state and
+	// returns the func restoring it. The ids are not restored, like a postgres sequence.
+	Snapshotter interface {
+		snapshot() (restore func())
+	}
+)
+
+var _ database.TxManager = TxManager{}
+
+// NewTxManager transaction manager of the in-memory repositories, repos are the ones rolled back.
+// The mongo repositories are left out as their writes are not part of the transaction either.
+func NewTxManager(repos ...Snapshotter) TxManager {
+	return TxManager{repos: repos}
+}
+
+// WithinTx run fn and roll the repositories back when it fails
+func (m TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxIsolation(ctx, sql.LevelDefault, fn)
+}
+
+// WithinTxIsolation WithinTx, the isolation level is ignored
+func (m TxManager) WithinTxIsolation(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) (err error) {
+	restores := make([]func(), 0, len(m.repos))
+	for _, repo := range m.repos {
+		restores = append(restores, repo.snapshot())
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			restore(restores)
+			panic(p)
+		}
+		if err != nil {
+			restore(restores)
+		}
+	}()
+
+	return fn(ctx)
+}
+
+func restore(restores []func()) {
+	for _, restore := range restores {
+		restore()
+	}
+}
+
+// now with the microsecond precision of a postgres timestamptz
+func now() time.Time {
+	return utils.TimeNow().Truncate(time.Microsecond)
+}
+
+// nextVersion a new updated_at that is always after previous, even within the same microsecond
+func nextVersion(previous time.Time) time.Time {
+	next := now()
+	if !next.After(previous) {
+		next = previous.Add(time.Microsecond)
+	}
+	return next
+}
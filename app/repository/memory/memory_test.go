@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/app/repository/repositorytest"
+)
+
+func TestUserRepository(t *testing.T) {
+	repositorytest.UserRepositoryContract(t, func(t *testing.T) repository.UserRepository {
+		return NewUserRepository()
+	})
+}
+
+func TestUserHistoryRepository(t *testing.T) {
+	repositorytest.UserHistoryRepositoryContract(t, func(t *testing.T) repository.UserHistoryRepository {
+		return NewUserHistoryRepository()
+	})
+}
+
+func TestOutboxRepository(t *testing.T) {
+	repositorytest.OutboxRepositoryContract(t, func(t *testing.T) repository.OutboxRepository {
+		return NewOutboxRepository()
+	})
+}
+
+func TestLogHTTPRepository(t *testing.T) {
+	repositorytest.LogHTTPRepositoryContract(t, func(t *testing.T) repository.LogHTTPRepository {
+		return NewLogHTTPRepository()
+	})
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	repositorytest.IdempotencyRepositoryContract(t, func(t *testing.T) repository.IdempotencyRepository {
+		return NewIdempotencyRepository()
+	})
+}
+
+func TestPrivacyJobRepository(t *testing.T) {
+	repositorytest.PrivacyJobRepositoryContract(t, func(t *testing.T) repository.PrivacyJobRepository {
+		return NewPrivacyJobRepository()
+	})
+}
+
+func TestTxManager(t *testing.T) {
+	repositorytest.TxManagerContract(t, func(t *testing.T) (database.TxManager, repository.UserRepository) {
+		userRepo := NewUserRepository()
+		return NewTxManager(userRepo), userRepo
+	})
+}
@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+// OutboxRepository in-memory repository.OutboxRepository
+type OutboxRepository struct {
+	mu     sync.RWMutex
+	events map[int64]model.OutboxEvent
+	nextID int64
+}
+
+// NewOutboxRepository empty outbox repository
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{events: map[int64]model.OutboxEvent{}}
+}
+
+var _ repository.OutboxRepository = (*OutboxRepository)(nil)
+
+func (r *OutboxRepository) snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := maps.Clone(r.events)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = events
+	}
+}
+
+// Insert add a pending event
+func (r *OutboxRepository) Insert(ctx context.Context, event model.OutboxEvent) (int64, error) {
+	if event.AvailableAt.IsZero() {
+		event.AvailableAt = utils.TimeNow()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = utils.TimeNow()
+	}
+	if event.Status == "" {
+		event.Status = model.OUTBOX_STATUS_PENDING
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	event.ID = r.nextID
+	event.Attempts = 0
+	r.events[event.ID] = event
+
+	return event.ID, nil
+}
+
+// ClaimPending lease the oldest due pending events
+func (r *OutboxRepository) ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]model.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := utils.TimeNow()
+	events := []model.OutboxEvent{}
+	for _, event := range r.events {
+		if event.Status == model.OUTBOX_STATUS_PENDING && !event.AvailableAt.After(due) &&
+			(event.LockedUntil == nil || !event.LockedUntil.After(due)) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].AvailableAt.Equal(events[j].AvailableAt) {
+			return events[i].AvailableAt.Before(events[j].AvailableAt)
+		}
+		return events[i].ID < events[j].ID
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	for i := range events {
+		events[i].LockedUntil = &lockedUntil
+		r.events[events[i].ID] = events[i]
+	}
+	return events, nil
+}
+
+// MarkPublished set the event published
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	return r.update(id, func(event *model.OutboxEvent) {
+		event.Status = model.OUTBOX_STATUS_PUBLISHED
+		event.Attempts++
+		event.LastError = nil
+		event.LockedUntil = nil
+		event.PublishedAt = utils.ValueToPtr(utils.TimeNow())
+	})
+}
+
+// MarkFailed count the attempt and keep the error
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error {
+	return r.update(id, func(event *model.OutboxEvent) {
+		event.Status = model.OUTBOX_STATUS_PENDING
+		if dead {
+			event.Status = model.OUTBOX_STATUS_DEAD
+		}
+		event.Attempts++
+		event.LastError = &lastError
+		event.AvailableAt = retryAt
+		event.LockedUntil = nil
+	})
+}
+
+// ListDead dead events, newest first
+func (r *OutboxRepository) ListDead(ctx context.Context, page, size int) ([]model.OutboxEvent, int64, error) {
+	events := r.list(func(event model.OutboxEvent) bool { return event.Status == model.OUTBOX_STATUS_DEAD })
+	sort.Slice(events, func(i, j int) bool { return events[i].ID > events[j].ID })
+	return paginate(events, page, size), int64(len(events)), nil
+}
+
+// Requeue dead event to pending, not found when the event is not dead
+func (r *OutboxRepository) Requeue(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event, ok := r.events[id]
+	if !ok || event.Status != model.OUTBOX_STATUS_DEAD {
+		return utils.ErrorNotFound
+	}
+	event.Status = model.OUTBOX_STATUS_PENDING
+	event.Attempts = 0
+	event.AvailableAt = utils.TimeNow()
+	event.LockedUntil = nil
+	r.events[id] = event
+	return nil
+}
+
+// DeletePublishedBefore remove the published events
+func (r *OutboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, event := range r.events {
+		if event.Status == model.OUTBOX_STATUS_PUBLISHED && event.PublishedAt != nil && event.PublishedAt.Before(before) {
+			delete(r.events, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *OutboxRepository) list(fn func(event model.OutboxEvent) bool) []model.OutboxEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	events := []model.OutboxEvent{}
+	for _, event := range r.events {
+		if fn(event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// update apply fn to the event, a missing event is ignored like an UPDATE matching no row
+func (r *OutboxRepository) update(id int64, fn func(event *model.OutboxEvent)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event, ok := r.events[id]
+	if !ok {
+		return nil
+	}
+	fn(&event)
+	r.events[id] = event
+	return nil
+}
@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+// PrivacyJobRepository in-memory repository.PrivacyJobRepository
+type PrivacyJobRepository struct {
+	mu       sync.Mutex
+	jobs     map[string]model.PrivacyJob
+	archives map[string][]byte
+}
+
+// NewPrivacyJobRepository empty privacy job repository
+func NewPrivacyJobRepository() *PrivacyJobRepository {
+	return &PrivacyJobRepository{jobs: map[string]model.PrivacyJob{}, archives: map[string][]byte{}}
+}
+
+var _ repository.PrivacyJobRepository = (*PrivacyJobRepository)(nil)
+
+func (r *PrivacyJobRepository) snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs, archives := maps.Clone(r.jobs), maps.Clone(r.archives)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.jobs, r.archives = jobs, archives
+	}
+}
+
+// Create insert the pending job unless the user has an active one of the type
+func (r *PrivacyJobRepository) Create(ctx context.Context, job model.PrivacyJob) (*model.PrivacyJob, error) {
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stored := range r.jobs {
+		if stored.UserID == job.UserID && stored.Type == job.Type && stored.IsActive() {
+			return &stored, nil
+		}
+	}
+
+	job = model.PrivacyJob{ID: job.ID, UserID: job.UserID, Type: job.Type, Status: model.PRIVACY_JOB_PENDING,
+		RequestID: job.RequestID, CreatedAt: job.CreatedAt}
+	r.jobs[job.ID] = job
+	return &job, nil
+}
+
+// GetByID get job by id
+func (r *PrivacyJobRepository) GetByID(ctx context.Context, id string) (*model.PrivacyJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, utils.ErrorNotFound
+	}
+	return &job, nil
+}
+
+// ClaimPending lease the due jobs, oldest first
+func (r *PrivacyJobRepository) ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]model.PrivacyJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := utils.TimeNow()
+	jobs := []model.PrivacyJob{}
+	for _, job := range r.jobs {
+		if job.IsActive() && (job.LockedUntil == nil || !job.LockedUntil.After(due)) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+
+	for i := range jobs {
+		jobs[i].Status = model.PRIVACY_JOB_RUNNING
+		jobs[i].Attempts++
+		jobs[i].LockedUntil = &lockedUntil
+		r.jobs[jobs[i].ID] = jobs[i]
+	}
+	return jobs, nil
+}
+
+// SetStep record the step
+func (r *PrivacyJobRepository) SetStep(ctx context.Context, id, step string) error {
+	return r.update(id, func(job *model.PrivacyJob) { job.Step = step })
+}
+
+// Release set the job pending until retryAt
+func (r *PrivacyJobRepository) Release(ctx context.Context, id, lastError string, retryAt time.Time) error {
+	return r.update(id, func(job *model.PrivacyJob) {
+		job.Status = model.PRIVACY_JOB_PENDING
+		job.Error = lastError
+		job.LockedUntil = &retryAt
+	})
+}
+
+// Finish set the job done or failed
+func (r *PrivacyJobRepository) Finish(ctx context.Context, id, lastError string, archive []byte) error {
+	err := r.update(id, func(job *model.PrivacyJob) {
+		job.Status = model.PRIVACY_JOB_DONE
+		if lastError != "" {
+			job.Status = model.PRIVACY_JOB_FAILED
+		}
+		finishedAt := now()
+		job.Error = lastError
+		job.LockedUntil = nil
+		job.FinishedAt = &finishedAt
+	})
+	if err != nil || archive == nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.archives[id] = append([]byte(nil), archive...)
+	return nil
+}
+
+// GetArchive archive of the job
+func (r *PrivacyJobRepository) GetArchive(ctx context.Context, id string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	archive, ok := r.archives[id]
+	if !ok {
+		return nil, utils.ErrorNotFound
+	}
+	return archive, nil
+}
+
+// DeleteFinishedBefore remove the finished jobs
+func (r *PrivacyJobRepository) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, job := range r.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(before) {
+			delete(r.jobs, id)
+			delete(r.archives, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// update the stored job, nothing happens when it does not exist like the UPDATE of postgres
+func (r *PrivacyJobRepository) update(id string, fn func(job *model.PrivacyJob)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil
+	}
+	fn(&job)
+	r.jobs[id] = job
+	return nil
+}
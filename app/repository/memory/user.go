@@ -0,0 +1,249 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+)
+
+// UserRepository in-memory repository.UserRepository, the rows are kept decrypted
+type UserRepository struct {
+	mu     sync.RWMutex
+	users  map[int64]model.User
+	nextID int64
+}
+
+// NewUserRepository empty user repository
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: map[int64]model.User{}}
+}
+
+var _ repository.UserRepository = (*UserRepository)(nil)
+
+func (r *UserRepository) snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := maps.Clone(r.users)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.users = users
+	}
+}
+
+// Create insert user, email and username are unique case insensitive and the phone number is
+// unique like the indexes of table user
+func (r *UserRepository) Create(ctx context.Context, user model.User) (*model.User, error) {
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = utils.TimeNow()
+	}
+	if err := setPhoneNumberIndex(&user); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkUniqueLocked(user); err != nil {
+		return nil, err
+	}
+
+	r.nextID++
+	user.ID = r.nextID
+	user.UpdatedAt = now()
+	r.users[user.ID] = user
+
+	return &user, nil
+}
+
+// GetByID get user by id
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	return r.find(func(user model.User) bool { return user.ID == id })
+}
+
+// GetByEmail get user by email, the email is case insensitive
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.find(func(user model.User) bool { return strings.EqualFold(user.Email, email) })
+}
+
+// GetByPhoneNumber exact-match lookup through the blind index
+func (r *UserRepository) GetByPhoneNumber(ctx context.Context, phoneNumber string) (*model.User, error) {
+	keyring := fieldcrypt.Default()
+	if keyring == nil {
+		return nil, fieldcrypt.ErrKeyringNotConfigured
+	}
+
+	index := keyring.BlindIndex(phoneNumber)
+	return r.find(func(user model.User) bool { return utils.PtrToValue(user.PhoneNumberIndex) == index })
+}
+
+// ExistsByUsername check if username is taken, deleted users included
+func (r *UserRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if user.Username != nil && strings.EqualFold(*user.Username, username) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Update save user when its UpdatedAt still matches
+func (r *UserRepository) Update(ctx context.Context, user *model.User) error {
+	if err := setPhoneNumberIndex(user); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[user.ID]
+	if !ok || stored.IsDeleted {
+		return utils.ErrorNotFound
+	}
+	if !stored.UpdatedAt.Equal(user.UpdatedAt) {
+		return utils.ErrorConflict
+	}
+	if err := r.checkUniqueLocked(*user); err != nil {
+		return err
+	}
+
+	// the columns Update does not write are kept
+	updated := *user
+	updated.Password = stored.Password
+	updated.LastLogin = stored.LastLogin
+	updated.IsGuest = stored.IsGuest
+	updated.IsDeleted = stored.IsDeleted
+	updated.CreatedAt = stored.CreatedAt
+	updated.UpdatedAt = nextVersion(stored.UpdatedAt)
+	r.users[user.ID] = updated
+	user.UpdatedAt = updated.UpdatedAt
+
+	return nil
+}
+
+// Anonymize erase the personal data of user and mark it deleted
+func (r *UserRepository) Anonymize(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return utils.ErrorNotFound
+	}
+
+	r.users[id] = model.User{
+		ID:                 id,
+		Email:              model.AnonymizedEmail(id),
+		Username:           utils.ValueToPtr(model.AnonymizedUsername(id)),
+		IsSuperUser:        user.IsSuperUser,
+		IsStaff:            user.IsStaff,
+		IsVerified:         user.IsVerified,
+		IsGuest:            user.IsGuest,
+		IsDeleted:          true,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          nextVersion(user.UpdatedAt),
+		CorporateAccountID: user.CorporateAccountID,
+		AuthorID:           user.AuthorID,
+	}
+	return nil
+}
+
+// Iterate the users matching the filter ordered by id
+func (r *UserRepository) Iterate(ctx context.Context, filter repository.UserRepositoryFilter, fn func(user model.User) error) error {
+	r.mu.RLock()
+	users := make([]model.User, 0, len(r.users))
+	for _, user := range r.users {
+		if !user.IsDeleted && matchUserFilter(user, filter) {
+			users = append(users, user)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RotateEncryptionKeys nothing is stored encrypted in memory
+func (r *UserRepository) RotateEncryptionKeys(ctx context.Context, afterID int64, limit int) (int, int64, error) {
+	if fieldcrypt.Default() == nil {
+		return 0, 0, fieldcrypt.ErrKeyringNotConfigured
+	}
+	return 0, 0, nil
+}
+
+// find the first user not deleted matching fn
+func (r *UserRepository) find(fn func(user model.User) bool) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if !user.IsDeleted && fn(user) {
+			return &user, nil
+		}
+	}
+	return nil, utils.ErrorNotFound
+}
+
+func (r *UserRepository) checkUniqueLocked(user model.User) error {
+	for _, other := range r.users {
+		if other.ID == user.ID {
+			continue
+		}
+		if strings.EqualFold(other.Email, user.Email) {
+			return utils.ErrorDuplicateData
+		}
+		if user.Username != nil && other.Username != nil && strings.EqualFold(*other.Username, *user.Username) {
+			return utils.ErrorDuplicateUsername
+		}
+		if user.PhoneNumberIndex != nil && other.PhoneNumberIndex != nil && *other.PhoneNumberIndex == *user.PhoneNumberIndex {
+			return utils.ErrorDuplicatePhoneNumber
+		}
+	}
+	return nil
+}
+
+func matchUserFilter(user model.User, filter repository.UserRepositoryFilter) bool {
+	contains := func(value *string, part string) bool {
+		return value != nil && strings.Contains(strings.ToLower(*value), strings.ToLower(part))
+	}
+
+	if filter.FirstName != nil && !contains(user.FirstName, *filter.FirstName) {
+		return false
+	}
+	if filter.LastName != nil && !contains(user.LastName, *filter.LastName) {
+		return false
+	}
+	if filter.Username != nil && utils.PtrToValue(user.Username) != *filter.Username {
+		return false
+	}
+	if filter.Email != nil && !strings.EqualFold(user.Email, *filter.Email) {
+		return false
+	}
+	return true
+}
+
+// setPhoneNumberIndex keep the blind index in sync with the phone number like the real repository
+func setPhoneNumberIndex(user *model.User) error {
+	if user.PhoneNumber == nil {
+		user.PhoneNumberIndex = nil
+		return nil
+	}
+
+	keyring := fieldcrypt.Default()
+	if keyring == nil {
+		return fieldcrypt.ErrKeyringNotConfigured
+	}
+	index := keyring.BlindIndex(user.PhoneNumber.String())
+	user.PhoneNumberIndex = &index
+	return nil
+}
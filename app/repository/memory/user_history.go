@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/ulid"
+)
+
+// UserHistoryRepository in-memory repository.UserHistoryRepository
+type UserHistoryRepository struct {
+	mu        sync.RWMutex
+	histories []model.UserHistory
+}
+
+// NewUserHistoryRepository empty user history repository
+func NewUserHistoryRepository() *UserHistoryRepository {
+	return &UserHistoryRepository{}
+}
+
+var _ repository.UserHistoryRepository = (*UserHistoryRepository)(nil)
+
+// Create insert history, ErrorDuplicateData when the given id exists
+func (r *UserHistoryRepository) Create(ctx context.Context, history model.UserHistory) error {
+	if history.ID == "" {
+		history.ID = ulid.GenerateUlidID()
+	}
+	history.Changes = append([]model.FieldChange{}, history.Changes...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stored := range r.histories {
+		if stored.ID == history.ID {
+			return utils.ErrorDuplicateData
+		}
+	}
+	r.histories = append(r.histories, history)
+	return nil
+}
+
+// ListByUserID list history of user, newest first
+func (r *UserHistoryRepository) ListByUserID(ctx context.Context, userID int64, page, size int) ([]model.UserHistory, int64, error) {
+	r.mu.RLock()
+	matched := []model.UserHistory{}
+	for _, history := range r.histories {
+		if history.UserID == userID {
+			matched = append(matched, history)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+	return paginate(matched, page, size), int64(len(matched)), nil
+}
+
+// AnonymizeByUserID redact the before and after values of every change of user
+func (r *UserHistoryRepository) AnonymizeByUserID(ctx context.Context, userID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, history := range r.histories {
+		if history.UserID != userID {
+			continue
+		}
+		changes := make([]model.FieldChange, len(history.Changes))
+		for j, change := range history.Changes {
+			changes[j] = model.FieldChange{Field: change.Field, Before: model.REDACTED, After: model.REDACTED}
+		}
+		r.histories[i].Changes = changes
+	}
+	return nil
+}
+
+// paginate page of items, page starts at 1
+func paginate[T any](items []T, page, size int) []T {
+	start := (page - 1) * size
+	if start >= len(items) || start < 0 {
+		return []T{}
+	}
+	end := start + size
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+var (
+	TableOutbox = fmt.Sprintf("%v.%v", "public", "outbox")
+
+	outboxColumns = `id, event_type, aggregate_type, aggregate_id, payload, status, attempts, last_error,
+		available_at, locked_until, created_at, published_at`
+)
+
+type (
+
+	// Repository Inteface
+	OutboxRepository interface {
+		// Insert add the event, call it in the transaction of the change so both are committed together
+		Insert(ctx context.Context, event model.OutboxEvent) (int64, error)
+		// ClaimPending lease up to limit due pending events until lockedUntil, oldest first. An event
+		// is due when it is available and not leased by another relay, or its lease ran out.
+		ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]model.OutboxEvent, error)
+		// MarkPublished set the event published and end its lease
+		MarkPublished(ctx context.Context, id int64) error
+		// MarkFailed record the failed attempt and end the lease, the event is retried at retryAt or
+		// marked dead
+		MarkFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error
+		// DeletePublishedBefore remove the events published before, returns how many were removed
+		DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+		// ListDead events the relay gave up on, newest first
+		ListDead(ctx context.Context, page, size int) ([]model.OutboxEvent, int64, error)
+		// Requeue set a dead event pending again with its attempts reset
+		Requeue(ctx context.Context, id int64) error
+	}
+
+	// Implementation
+	OutboxRepositoryImpl struct {
+		postgresCollection database.PostgresCollection
+	}
+)
+
+// New Repository Outbox
+func NewOutboxRepository(postgresCollection database.PostgresCollection) OutboxRepository {
+	return OutboxRepositoryImpl{
+		postgresCollection: postgresCollection,
+	}
+}
+
+// Insert add a pending event
+func (r OutboxRepositoryImpl) Insert(ctx context.Context, event model.OutboxEvent) (int64, error) {
+	if event.AvailableAt.IsZero() {
+		event.AvailableAt = utils.TimeNow()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = utils.TimeNow()
+	}
+	if event.Status == "" {
+		event.Status = model.OUTBOX_STATUS_PENDING
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (event_type, aggregate_type, aggregate_id, payload, status, available_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`, TableOutbox)
+	var id int64
+	err := r.postgresCollection.Writer(ctx).GetContext(ctx, &id, query,
+		event.EventType, event.AggregateType, event.AggregateID, event.Payload, event.Status,
+		event.AvailableAt, event.CreatedAt)
+
+	return id, err
+}
+
+// ClaimPending lease the oldest due pending events in one statement, FOR UPDATE SKIP LOCKED keeps
+// two relays from claiming the same event. The lease is committed before the events are published.
+func (r OutboxRepositoryImpl) ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]model.OutboxEvent, error) {
+	query := fmt.Sprintf(`UPDATE %s SET locked_until = $1
+		WHERE id IN (
+			SELECT id FROM %s WHERE status = $2 AND available_at <= $3 AND (locked_until IS NULL OR locked_until <= $3)
+			ORDER BY available_at, id LIMIT $4 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s`, TableOutbox, TableOutbox, outboxColumns)
+	events := []model.OutboxEvent{}
+	err := r.postgresCollection.Writer(ctx).SelectContext(ctx, &events, query, lockedUntil, model.OUTBOX_STATUS_PENDING, utils.TimeNow(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].AvailableAt.Equal(events[j].AvailableAt) {
+			return events[i].AvailableAt.Before(events[j].AvailableAt)
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
+
+// MarkPublished set the event published
+func (r OutboxRepositoryImpl) MarkPublished(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, attempts = attempts + 1, last_error = NULL, locked_until = NULL,
+		published_at = $2 WHERE id = $3`, TableOutbox)
+	_, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, model.OUTBOX_STATUS_PUBLISHED, utils.TimeNow(), id)
+
+	return err
+}
+
+// MarkFailed count the attempt and keep the error
+func (r OutboxRepositoryImpl) MarkFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error {
+	status := model.OUTBOX_STATUS_PENDING
+	if dead {
+		status = model.OUTBOX_STATUS_DEAD
+	}
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, attempts = attempts + 1, last_error = $2, available_at = $3,
+		locked_until = NULL WHERE id = $4`, TableOutbox)
+	_, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, status, lastError, retryAt, id)
+
+	return err
+}
+
+// ListDead dead events with their last error
+func (r OutboxRepositoryImpl) ListDead(ctx context.Context, page, size int) ([]model.OutboxEvent, int64, error) {
+	var total int64
+	count := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = $1", TableOutbox)
+	if err := r.postgresCollection.Reader(ctx).GetContext(ctx, &total, count, model.OUTBOX_STATUS_DEAD); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE status = $1 ORDER BY id DESC LIMIT $2 OFFSET $3", outboxColumns, TableOutbox)
+	events := []model.OutboxEvent{}
+	err := r.postgresCollection.Reader(ctx).SelectContext(ctx, &events, query, model.OUTBOX_STATUS_DEAD, size, (page-1)*size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// Requeue dead event to pending, not found when the event is not dead
+func (r OutboxRepositoryImpl) Requeue(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, attempts = 0, available_at = $2, locked_until = NULL
+		WHERE id = $3 AND status = $4`, TableOutbox)
+	res, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, model.OUTBOX_STATUS_PENDING, utils.TimeNow(), id, model.OUTBOX_STATUS_DEAD)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return utils.ErrorNotFound
+	}
+
+	return nil
+}
+
+// DeletePublishedBefore remove the published events
+func (r OutboxRepositoryImpl) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE status = $1 AND published_at < $2`, TableOutbox)
+	res, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, model.OUTBOX_STATUS_PUBLISHED, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
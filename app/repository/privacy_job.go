@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+)
+
+var (
+	TablePrivacyJob = fmt.Sprintf("%v.%v", "public", "privacy_job")
+
+	// privacyJobColumns every column but the archive, it is only read by GetArchive
+	privacyJobColumns = `id, user_id, type, status, step, attempts, error, request_id, locked_until, created_at, finished_at`
+)
+
+type (
+
+	// Repository Inteface
+	PrivacyJobRepository interface {
+		// Create insert the pending job, the pending or running job of the same user and type is
+		// returned instead when there is one
+		Create(ctx context.Context, job model.PrivacyJob) (*model.PrivacyJob, error)
+		GetByID(ctx context.Context, id string) (*model.PrivacyJob, error)
+		// ClaimPending set up to limit due jobs running until lockedUntil, oldest first. A due job
+		// is pending or running with its lease run out, e.g. its instance crashed.
+		ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]model.PrivacyJob, error)
+		// SetStep record the last step of the job done
+		SetStep(ctx context.Context, id, step string) error
+		// Release set the job pending again with the error of the attempt, it is due at retryAt
+		Release(ctx context.Context, id, lastError string, retryAt time.Time) error
+		// Finish set the job done with its archive, or failed when lastError is not empty
+		Finish(ctx context.Context, id, lastError string, archive []byte) error
+		GetArchive(ctx context.Context, id string) ([]byte, error)
+		// DeleteFinishedBefore remove the jobs finished before with their archive, returns how many
+		// were removed
+		DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	// Implementation
+	PrivacyJobRepositoryImpl struct {
+		postgresCollection database.PostgresCollection
+	}
+)
+
+// New Repository Privacy Job
+func NewPrivacyJobRepository(postgresCollection database.PostgresCollection) PrivacyJobRepository {
+	return PrivacyJobRepositoryImpl{
+		postgresCollection: postgresCollection,
+	}
+}
+
+// Create insert the job, the partial unique index keeps one active job per user and type
+func (r PrivacyJobRepositoryImpl) Create(ctx context.Context, job model.PrivacyJob) (*model.PrivacyJob, error) {
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = utils.TimeNow()
+	}
+
+	insert := fmt.Sprintf(`INSERT INTO %s (id, user_id, type, status, request_id, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, type) WHERE status IN ('pending', 'running') DO NOTHING
+		RETURNING %s`, TablePrivacyJob, privacyJobColumns)
+	active := fmt.Sprintf(`SELECT %s FROM %s WHERE user_id = $1 AND type = $2 AND status IN ($3, $4)`,
+		privacyJobColumns, TablePrivacyJob)
+
+	// the active job may finish between the insert and the select, then the insert is tried again
+	for attempt := 1; ; attempt++ {
+		stored := model.PrivacyJob{}
+		err := r.postgresCollection.Writer(ctx).GetContext(ctx, &stored, insert,
+			job.ID, job.UserID, job.Type, model.PRIVACY_JOB_PENDING, job.RequestID, job.CreatedAt)
+		if err == nil {
+			return &stored, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		err = r.postgresCollection.Writer(ctx).GetContext(ctx, &stored, active,
+			job.UserID, job.Type, model.PRIVACY_JOB_PENDING, model.PRIVACY_JOB_RUNNING)
+		if err == nil {
+			return &stored, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) || attempt == 2 {
+			return nil, err
+		}
+	}
+}
+
+// GetByID get job by id, read on the writer so a job is found right after it was created
+func (r PrivacyJobRepositoryImpl) GetByID(ctx context.Context, id string) (*model.PrivacyJob, error) {
+	job := model.PrivacyJob{}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", privacyJobColumns, TablePrivacyJob)
+	err := r.postgresCollection.Writer(ctx).GetContext(ctx, &job, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrorNotFound
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ClaimPending lease the due jobs in one statement, FOR UPDATE SKIP LOCKED keeps two runners from
+// claiming the same job
+func (r PrivacyJobRepositoryImpl) ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]model.PrivacyJob, error) {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, attempts = attempts + 1, locked_until = $2
+		WHERE id IN (
+			SELECT id FROM %s WHERE status IN ($3, $1) AND (locked_until IS NULL OR locked_until <= $4)
+			ORDER BY created_at LIMIT $5 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s`, TablePrivacyJob, TablePrivacyJob, privacyJobColumns)
+	jobs := []model.PrivacyJob{}
+	err := r.postgresCollection.Writer(ctx).SelectContext(ctx, &jobs, query,
+		model.PRIVACY_JOB_RUNNING, lockedUntil, model.PRIVACY_JOB_PENDING, utils.TimeNow(), limit)
+
+	return jobs, err
+}
+
+// SetStep record the step
+func (r PrivacyJobRepositoryImpl) SetStep(ctx context.Context, id, step string) error {
+	query := fmt.Sprintf("UPDATE %s SET step = $2 WHERE id = $1", TablePrivacyJob)
+	_, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, id, step)
+
+	return err
+}
+
+// Release set the job pending until retryAt
+func (r PrivacyJobRepositoryImpl) Release(ctx context.Context, id, lastError string, retryAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET status = $2, error = $3, locked_until = $4 WHERE id = $1", TablePrivacyJob)
+	_, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, id, model.PRIVACY_JOB_PENDING, lastError, retryAt)
+
+	return err
+}
+
+// Finish set the job done or failed
+func (r PrivacyJobRepositoryImpl) Finish(ctx context.Context, id, lastError string, archive []byte) error {
+	status := model.PRIVACY_JOB_DONE
+	if lastError != "" {
+		status = model.PRIVACY_JOB_FAILED
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET status = $2, error = $3, archive = $4, locked_until = NULL, finished_at = $5
+		WHERE id = $1`, TablePrivacyJob)
+	_, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, id, status, lastError, archive, utils.TimeNow())
+
+	return err
+}
+
+// GetArchive archive of the job, ErrorNotFound when the job has none
+func (r PrivacyJobRepositoryImpl) GetArchive(ctx context.Context, id string) ([]byte, error) {
+	var archive []byte
+	query := fmt.Sprintf("SELECT archive FROM %s WHERE id = $1 AND archive IS NOT NULL", TablePrivacyJob)
+	err := r.postgresCollection.Writer(ctx).GetContext(ctx, &archive, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrorNotFound
+		}
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// DeleteFinishedBefore remove the finished jobs
+func (r PrivacyJobRepositoryImpl) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE finished_at < $1", TablePrivacyJob)
+	res, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,133 @@
+package repositorytest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// IdempotencyRepositoryContract run the contract of repository.IdempotencyRepository
+func IdempotencyRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.IdempotencyRepository) {
+	ctx := context.Background()
+
+	newKey := func(fingerprint string) model.IdempotencyRecord {
+		return model.IdempotencyRecord{
+			Scope:       unique("uid"),
+			Key:         unique("key"),
+			Fingerprint: strings.Repeat(fingerprint, 64),
+			LockedUntil: utils.TimeNow().Add(time.Minute),
+			ExpiresAt:   utils.TimeNow().Add(time.Hour),
+		}
+	}
+
+	t.Run("acquire then replay the response", func(t *testing.T) {
+		repo := newRepo(t)
+		key := newKey("a")
+		stored, err := repo.Acquire(ctx, key)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+
+		stored, err = repo.Acquire(ctx, key)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, model.IDEMPOTENCY_STATUS_PROCESSING, stored.Status)
+		assert.Equal(t, key.Fingerprint, stored.Fingerprint)
+
+		// the same key of another scope is another key
+		other := key
+		other.Scope = unique("uid")
+		stored, err = repo.Acquire(ctx, other)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+
+		key.ResponseStatus = 201
+		key.ResponseContentType = "application/json"
+		key.ResponseBody = []byte(`{"id":1}`)
+		require.NoError(t, repo.Complete(ctx, key))
+
+		stored, err = repo.Acquire(ctx, key)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, model.IDEMPOTENCY_STATUS_COMPLETED, stored.Status)
+		assert.Equal(t, 201, stored.ResponseStatus)
+		assert.Equal(t, "application/json", stored.ResponseContentType)
+		assert.Equal(t, `{"id":1}`, string(stored.ResponseBody))
+	})
+
+	t.Run("release lets the retry run", func(t *testing.T) {
+		repo := newRepo(t)
+		key := newKey("b")
+		_, err := repo.Acquire(ctx, key)
+		require.NoError(t, err)
+
+		// only the owner of the fingerprint releases the key
+		require.NoError(t, repo.Release(ctx, key.Scope, key.Key, strings.Repeat("c", 64)))
+		stored, err := repo.Acquire(ctx, key)
+		require.NoError(t, err)
+		assert.NotNil(t, stored)
+
+		require.NoError(t, repo.Release(ctx, key.Scope, key.Key, key.Fingerprint))
+		stored, err = repo.Acquire(ctx, key)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("abandoned and expired keys are taken over", func(t *testing.T) {
+		repo := newRepo(t)
+		abandoned := newKey("d")
+		abandoned.LockedUntil = utils.TimeNow().Add(-time.Second)
+		_, err := repo.Acquire(ctx, abandoned)
+		require.NoError(t, err)
+
+		// another request keeps getting the stored key
+		mismatch := abandoned
+		mismatch.Fingerprint = strings.Repeat("e", 64)
+		stored, err := repo.Acquire(ctx, mismatch)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, abandoned.Fingerprint, stored.Fingerprint)
+
+		stored, err = repo.Acquire(ctx, abandoned)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+
+		expired := newKey("f")
+		expired.ExpiresAt = utils.TimeNow().Add(-time.Second)
+		_, err = repo.Acquire(ctx, expired)
+		require.NoError(t, err)
+		require.NoError(t, repo.Complete(ctx, expired))
+
+		renewed := expired
+		renewed.Fingerprint = strings.Repeat("0", 64)
+		renewed.ExpiresAt = utils.TimeNow().Add(time.Hour)
+		stored, err = repo.Acquire(ctx, renewed)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("delete expired", func(t *testing.T) {
+		repo := newRepo(t)
+		expired := newKey("1")
+		expired.ExpiresAt = utils.TimeNow().Add(-time.Second)
+		_, err := repo.Acquire(ctx, expired)
+		require.NoError(t, err)
+		kept := newKey("2")
+		_, err = repo.Acquire(ctx, kept)
+		require.NoError(t, err)
+
+		deleted, err := repo.DeleteExpired(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+
+		stored, err := repo.Acquire(ctx, kept)
+		require.NoError(t, err)
+		assert.NotNil(t, stored)
+	})
+}
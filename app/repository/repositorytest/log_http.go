@@ -0,0 +1,81 @@
+package repositorytest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LogHTTPRepositoryContract run the contract of repository.LogHTTPRepository
+func LogHTTPRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.LogHTTPRepository) {
+	ctx := context.Background()
+
+	t.Run("search written logs", func(t *testing.T) {
+		repo := newRepo(t)
+		requestID := unique("request")
+		uri := "/" + unique("path")
+		start := time.Now().UTC().Truncate(time.Millisecond)
+		logs := []model.LogHTTP{
+			{RequestID: requestID, Method: http.MethodGet, URI: uri + "/a", Status: http.StatusOK, CreatedAt: start},
+			{RequestID: unique("request"), Method: http.MethodPost, URI: uri + "/b?x=1", Status: http.StatusConflict, CreatedAt: start.Add(time.Second)},
+			{RequestID: unique("request"), Method: http.MethodGet, URI: "/other" + uri, Status: http.StatusOK, CreatedAt: start.Add(2 * time.Second)},
+		}
+		for _, logHTTP := range logs {
+			repo.Write(logHTTP)
+		}
+
+		// Close waits for the buffered logs
+		closeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		require.NoError(t, repo.Close(closeCtx))
+		assert.Zero(t, repo.Dropped())
+
+		found, total, err := repo.Search(ctx, model.LogHTTPFilter{RequestID: requestID}, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		require.Len(t, found, 1)
+		assert.Equal(t, uri+"/a", found[0].URI)
+		assert.NotEmpty(t, found[0].ID)
+
+		found, total, err = repo.Search(ctx, model.LogHTTPFilter{URI: uri}, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		require.Len(t, found, 2)
+		assert.Equal(t, uri+"/b?x=1", found[0].URI)
+
+		found, total, err = repo.Search(ctx, model.LogHTTPFilter{URI: uri, Status: http.StatusConflict}, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		require.Len(t, found, 1)
+	})
+
+	t.Run("body is capped", func(t *testing.T) {
+		repo := newRepo(t)
+		requestID := unique("request")
+		repo.Write(model.LogHTTP{RequestID: requestID, Body: strings.Repeat("a", model.MAX_LOG_HTTP_BODY_SIZE+1), CreatedAt: time.Now().UTC()})
+
+		closeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		require.NoError(t, repo.Close(closeCtx))
+
+		found, _, err := repo.Search(ctx, model.LogHTTPFilter{RequestID: requestID}, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		assert.Len(t, found[0].Body, model.MAX_LOG_HTTP_BODY_SIZE)
+		assert.True(t, found[0].BodyTruncated)
+	})
+
+	t.Run("write after close is dropped", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Close(ctx))
+		repo.Write(model.LogHTTP{RequestID: unique("request"), CreatedAt: time.Now().UTC()})
+		assert.Equal(t, int64(1), repo.Dropped())
+	})
+}
@@ -0,0 +1,149 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// OutboxRepositoryContract run the contract of repository.OutboxRepository, newRepo must return a
+// repository with an empty outbox
+func OutboxRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.OutboxRepository) {
+	ctx := context.Background()
+	// expired lease, the claimed events can be claimed again at once
+	expired := utils.TimeNow().Add(-time.Second)
+
+	newEvent := func(t *testing.T) model.OutboxEvent {
+		event, err := model.NewOutboxEvent(model.EVENT_USER_CREATED, model.AGGREGATE_USER, unique("user"), map[string]int{"id": 1})
+		require.NoError(t, err)
+		return event
+	}
+	ids := func(events []model.OutboxEvent) []int64 {
+		result := []int64{}
+		for _, event := range events {
+			result = append(result, event.ID)
+		}
+		return result
+	}
+
+	t.Run("claim due pending events", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+		second, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+		later := newEvent(t)
+		later.AvailableAt = utils.TimeNow().Add(time.Hour)
+		_, err = repo.Insert(ctx, later)
+		require.NoError(t, err)
+
+		events, err := repo.ClaimPending(ctx, 10, expired)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{first, second}, ids(events))
+		assert.JSONEq(t, `{"id": 1}`, events[0].Payload.String())
+		assert.Equal(t, model.OUTBOX_STATUS_PENDING, events[0].Status)
+
+		events, err = repo.ClaimPending(ctx, 1, expired)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{first}, ids(events))
+	})
+
+	t.Run("published and retried events are not claimed", func(t *testing.T) {
+		repo := newRepo(t)
+		published, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+		retried, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+
+		require.NoError(t, repo.MarkPublished(ctx, published))
+		require.NoError(t, repo.MarkFailed(ctx, retried, "broker down", utils.TimeNow().Add(time.Hour), false))
+
+		events, err := repo.ClaimPending(ctx, 10, expired)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		require.NoError(t, repo.MarkFailed(ctx, retried, "broker down", utils.TimeNow().Add(-time.Second), false))
+		events, err = repo.ClaimPending(ctx, 10, expired)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, 2, events[0].Attempts)
+		assert.Equal(t, "broker down", utils.PtrToValue(events[0].LastError))
+	})
+
+	t.Run("dead letter and requeue", func(t *testing.T) {
+		repo := newRepo(t)
+		dead, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+		pending, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+
+		require.NoError(t, repo.MarkFailed(ctx, dead, "rejected", utils.TimeNow(), true))
+		events, total, err := repo.ListDead(ctx, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []int64{dead}, ids(events))
+		assert.Equal(t, "rejected", utils.PtrToValue(events[0].LastError))
+
+		events, err = repo.ClaimPending(ctx, 10, expired)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{pending}, ids(events))
+
+		assert.Equal(t, utils.ErrorNotFound, repo.Requeue(ctx, pending))
+		require.NoError(t, repo.Requeue(ctx, dead))
+		events, err = repo.ClaimPending(ctx, 10, expired)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{pending, dead}, ids(events))
+		for _, event := range events {
+			assert.Zero(t, event.Attempts)
+		}
+	})
+
+	t.Run("claimed events are leased", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+
+		events, err := repo.ClaimPending(ctx, 10, utils.TimeNow().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []int64{first}, ids(events))
+		assert.NotNil(t, events[0].LockedUntil)
+
+		second, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+		events, err = repo.ClaimPending(ctx, 10, utils.TimeNow().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []int64{second}, ids(events))
+
+		// a failed attempt ends the lease
+		require.NoError(t, repo.MarkFailed(ctx, first, "broker down", utils.TimeNow().Add(-time.Second), false))
+		events, err = repo.ClaimPending(ctx, 10, expired)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{first}, ids(events))
+	})
+
+	t.Run("delete published before", func(t *testing.T) {
+		repo := newRepo(t)
+		published, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+		pending, err := repo.Insert(ctx, newEvent(t))
+		require.NoError(t, err)
+		require.NoError(t, repo.MarkPublished(ctx, published))
+
+		deleted, err := repo.DeletePublishedBefore(ctx, utils.TimeNow().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+
+		deleted, err = repo.DeletePublishedBefore(ctx, utils.TimeNow().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+		events, err := repo.ClaimPending(ctx, 10, expired)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{pending}, ids(events))
+	})
+}
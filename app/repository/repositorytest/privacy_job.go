@@ -0,0 +1,130 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// PrivacyJobRepositoryContract run the contract of repository.PrivacyJobRepository, newRepo must
+// return a repository without jobs
+func PrivacyJobRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.PrivacyJobRepository) {
+	ctx := context.Background()
+
+	newJob := func(jobType string) model.PrivacyJob {
+		return model.PrivacyJob{
+			ID:        ulid.GenerateUlidID(),
+			UserID:    time.Now().UnixNano() + sequence.Add(1),
+			Type:      jobType,
+			RequestID: unique("request"),
+		}
+	}
+
+	t.Run("one active job per user and type", func(t *testing.T) {
+		repo := newRepo(t)
+		job := newJob(model.PRIVACY_JOB_EXPORT)
+		created, err := repo.Create(ctx, job)
+		require.NoError(t, err)
+		assert.Equal(t, job.ID, created.ID)
+		assert.Equal(t, model.PRIVACY_JOB_PENDING, created.Status)
+
+		again := job
+		again.ID = ulid.GenerateUlidID()
+		active, err := repo.Create(ctx, again)
+		require.NoError(t, err)
+		assert.Equal(t, job.ID, active.ID)
+
+		erasure := job
+		erasure.ID = ulid.GenerateUlidID()
+		erasure.Type = model.PRIVACY_JOB_ERASURE
+		created, err = repo.Create(ctx, erasure)
+		require.NoError(t, err)
+		assert.Equal(t, erasure.ID, created.ID)
+
+		// a finished job does not block a new one
+		require.NoError(t, repo.Finish(ctx, job.ID, "", []byte("zip")))
+		created, err = repo.Create(ctx, again)
+		require.NoError(t, err)
+		assert.Equal(t, again.ID, created.ID)
+
+		_, err = repo.GetByID(ctx, ulid.GenerateUlidID())
+		assert.Equal(t, utils.ErrorNotFound, err)
+	})
+
+	t.Run("claim, release and finish", func(t *testing.T) {
+		repo := newRepo(t)
+		first := newJob(model.PRIVACY_JOB_ERASURE)
+		_, err := repo.Create(ctx, first)
+		require.NoError(t, err)
+
+		claimed, err := repo.ClaimPending(ctx, 10, utils.TimeNow().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, first.ID, claimed[0].ID)
+		assert.Equal(t, model.PRIVACY_JOB_RUNNING, claimed[0].Status)
+		assert.Equal(t, 1, claimed[0].Attempts)
+		assert.Equal(t, first.RequestID, claimed[0].RequestID)
+
+		// leased
+		claimed, err = repo.ClaimPending(ctx, 10, utils.TimeNow().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Empty(t, claimed)
+
+		require.NoError(t, repo.SetStep(ctx, first.ID, model.PRIVACY_STEP_IMAGE_DELETED))
+		require.NoError(t, repo.Release(ctx, first.ID, "image storage is down", utils.TimeNow().Add(-time.Second)))
+		claimed, err = repo.ClaimPending(ctx, 10, utils.TimeNow().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, 2, claimed[0].Attempts)
+		assert.Equal(t, model.PRIVACY_STEP_IMAGE_DELETED, claimed[0].Step)
+		assert.Equal(t, "image storage is down", claimed[0].Error)
+
+		require.NoError(t, repo.Finish(ctx, first.ID, "gave up", nil))
+		job, err := repo.GetByID(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.PRIVACY_JOB_FAILED, job.Status)
+		assert.NotNil(t, job.FinishedAt)
+		_, err = repo.GetArchive(ctx, first.ID)
+		assert.Equal(t, utils.ErrorNotFound, err)
+
+		// the lease of a crashed instance runs out
+		crashed := newJob(model.PRIVACY_JOB_EXPORT)
+		_, err = repo.Create(ctx, crashed)
+		require.NoError(t, err)
+		_, err = repo.ClaimPending(ctx, 10, utils.TimeNow().Add(-time.Second))
+		require.NoError(t, err)
+		claimed, err = repo.ClaimPending(ctx, 10, utils.TimeNow().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, crashed.ID, claimed[0].ID)
+	})
+
+	t.Run("archive and purge", func(t *testing.T) {
+		repo := newRepo(t)
+		job := newJob(model.PRIVACY_JOB_EXPORT)
+		_, err := repo.Create(ctx, job)
+		require.NoError(t, err)
+		require.NoError(t, repo.Finish(ctx, job.ID, "", []byte("zip")))
+
+		archive, err := repo.GetArchive(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "zip", string(archive))
+
+		deleted, err := repo.DeleteFinishedBefore(ctx, utils.TimeNow().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+
+		deleted, err = repo.DeleteFinishedBefore(ctx, utils.TimeNow().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+		_, err = repo.GetByID(ctx, job.ID)
+		assert.Equal(t, utils.ErrorNotFound, err)
+	})
+}
@@ -0,0 +1,38 @@
+// Package repositorytest behavioural contract of the repositories. The same suite runs against the
+// in-memory fakes of package memory and, when TEST_POSTGRES_DSN and TEST_MONGO_DSN are set, against
+// the real repositories, so a fake cannot drift from the behaviour the services rely on.
+package repositorytest
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/stretchr/testify/require"
+)
+
+var sequence atomic.Int64
+
+// SetupKeyring set a test keyring as the fieldcrypt default when none is set
+func SetupKeyring(t *testing.T) {
+	t.Helper()
+	if fieldcrypt.Default() != nil {
+		return
+	}
+	keyring, err := fieldcrypt.NewKeyring(map[uint32][]byte{1: bytes.Repeat([]byte{1}, 32)}, 1, []byte("repositorytest"))
+	require.NoError(t, err)
+	fieldcrypt.SetDefault(keyring)
+}
+
+// unique suffix so the rows of a run never collide with the rows of another one
+func unique(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), sequence.Add(1))
+}
+
+// uniquePhoneNumber Indonesian mobile number in E.164 that changes every call
+func uniquePhoneNumber() string {
+	return fmt.Sprintf("+62812%08d", (time.Now().UnixNano()/1000+sequence.Add(1))%100_000_000)
+}
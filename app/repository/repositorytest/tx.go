@@ -0,0 +1,82 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TxManagerContract run the contract of database.TxManager, newTx returns the manager and a user
+// repository taking part in its transactions
+func TxManagerContract(t *testing.T, newTx func(t *testing.T) (database.TxManager, repository.UserRepository)) {
+	SetupKeyring(t)
+	ctx := context.Background()
+
+	newUser := func() model.User {
+		name := unique("tx")
+		return model.User{Email: name + "@example.com", Username: utils.ValueToPtr(name), IsActive: true}
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		txManager, repo := newTx(t)
+		var created *model.User
+		err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+			var err error
+			created, err = repo.Create(ctx, newUser())
+			return err
+		})
+		require.NoError(t, err)
+
+		_, err = repo.GetByID(ctx, created.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		txManager, repo := newTx(t)
+		user := newUser()
+		err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := repo.Create(ctx, user); err != nil {
+				return err
+			}
+			return assert.AnError
+		})
+		assert.Equal(t, assert.AnError, err)
+
+		_, err = repo.GetByEmail(ctx, user.Email)
+		assert.Equal(t, utils.ErrorNotFound, err)
+	})
+
+	t.Run("nested call rolls back only its work", func(t *testing.T) {
+		txManager, repo := newTx(t)
+		outer, inner := newUser(), newUser()
+		err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := repo.Create(ctx, outer); err != nil {
+				return err
+			}
+			err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+				if _, err := repo.Create(ctx, inner); err != nil {
+					return err
+				}
+				// the unique violation is recovered by the outer transaction
+				_, err := repo.Create(ctx, outer)
+				return err
+			})
+			if err != utils.ErrorDuplicateData {
+				return err
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		_, err = repo.GetByEmail(ctx, outer.Email)
+		assert.NoError(t, err)
+		_, err = repo.GetByEmail(ctx, inner.Email)
+		assert.Equal(t, utils.ErrorNotFound, err)
+	})
+}
@@ -0,0 +1,182 @@
+package repositorytest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UserRepositoryContract run the contract of repository.UserRepository, newRepo is called for every
+// subtest
+func UserRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.UserRepository) {
+	SetupKeyring(t)
+	ctx := context.Background()
+
+	newUser := func() model.User {
+		name := unique("user")
+		return model.User{
+			Email:     name + "@example.com",
+			Username:  utils.ValueToPtr(name),
+			FirstName: utils.ValueToPtr("Budi"),
+			IsActive:  true,
+		}
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		created, err := repo.Create(ctx, user)
+		require.NoError(t, err)
+		assert.NotZero(t, created.ID)
+		assert.False(t, created.UpdatedAt.IsZero())
+
+		got, err := repo.GetByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, got.Email)
+		assert.Equal(t, created.ETag(), got.ETag())
+
+		got, err = repo.GetByEmail(ctx, strings.ToUpper(user.Email))
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, got.ID)
+
+		_, err = repo.GetByID(ctx, created.ID+1_000_000)
+		assert.Equal(t, utils.ErrorNotFound, err)
+		_, err = repo.GetByEmail(ctx, unique("missing")+"@example.com")
+		assert.Equal(t, utils.ErrorNotFound, err)
+	})
+
+	t.Run("unique email and username", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		_, err := repo.Create(ctx, user)
+		require.NoError(t, err)
+
+		sameEmail := newUser()
+		sameEmail.Email = strings.ToUpper(user.Email)
+		_, err = repo.Create(ctx, sameEmail)
+		assert.Equal(t, utils.ErrorDuplicateData, err)
+
+		sameUsername := newUser()
+		sameUsername.Username = utils.ValueToPtr(strings.ToUpper(*user.Username))
+		_, err = repo.Create(ctx, sameUsername)
+		assert.Equal(t, utils.ErrorDuplicateUsername, err)
+
+		exists, err := repo.ExistsByUsername(ctx, strings.ToUpper(*user.Username))
+		require.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = repo.ExistsByUsername(ctx, unique("missing"))
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("phone number", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		phoneNumber := uniquePhoneNumber()
+		user.PhoneNumber = utils.ValueToPtr(fieldcrypt.EncryptedString(phoneNumber))
+		created, err := repo.Create(ctx, user)
+		require.NoError(t, err)
+
+		got, err := repo.GetByPhoneNumber(ctx, phoneNumber)
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, got.ID)
+		assert.Equal(t, phoneNumber, got.PhoneNumber.String())
+
+		samePhone := newUser()
+		samePhone.PhoneNumber = user.PhoneNumber
+		_, err = repo.Create(ctx, samePhone)
+		assert.Equal(t, utils.ErrorDuplicatePhoneNumber, err)
+	})
+
+	t.Run("update checks the version", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newUser())
+		require.NoError(t, err)
+
+		first, err := repo.GetByID(ctx, created.ID)
+		require.NoError(t, err)
+		stale := *first
+
+		first.LastName = utils.ValueToPtr("Santoso")
+		require.NoError(t, repo.Update(ctx, first))
+		assert.NotEqual(t, stale.ETag(), first.ETag())
+
+		got, err := repo.GetByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Santoso", utils.PtrToValue(got.LastName))
+		assert.Equal(t, first.ETag(), got.ETag())
+
+		stale.LastName = utils.ValueToPtr("Wijaya")
+		assert.Equal(t, utils.ErrorConflict, repo.Update(ctx, &stale))
+
+		missing := *got
+		missing.ID += 1_000_000
+		assert.Equal(t, utils.ErrorNotFound, repo.Update(ctx, &missing))
+	})
+
+	t.Run("update keeps the email unique", func(t *testing.T) {
+		repo := newRepo(t)
+		other, err := repo.Create(ctx, newUser())
+		require.NoError(t, err)
+		user, err := repo.Create(ctx, newUser())
+		require.NoError(t, err)
+
+		user.Email = other.Email
+		assert.Equal(t, utils.ErrorDuplicateData, repo.Update(ctx, user))
+	})
+
+	t.Run("anonymize", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		created, err := repo.Create(ctx, user)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Anonymize(ctx, created.ID))
+		_, err = repo.GetByID(ctx, created.ID)
+		assert.Equal(t, utils.ErrorNotFound, err)
+		_, err = repo.GetByEmail(ctx, user.Email)
+		assert.Equal(t, utils.ErrorNotFound, err)
+		assert.Equal(t, utils.ErrorNotFound, repo.Update(ctx, created))
+
+		assert.Equal(t, utils.ErrorNotFound, repo.Anonymize(ctx, created.ID+1_000_000))
+	})
+
+	t.Run("iterate", func(t *testing.T) {
+		repo := newRepo(t)
+		lastName := unique("family")
+		var ids []int64
+		for i := 0; i < 3; i++ {
+			user := newUser()
+			user.LastName = utils.ValueToPtr(lastName)
+			created, err := repo.Create(ctx, user)
+			require.NoError(t, err)
+			ids = append(ids, created.ID)
+		}
+		require.NoError(t, repo.Anonymize(ctx, ids[1]))
+
+		var got []int64
+		filter := repository.UserRepositoryFilter{LastName: utils.ValueToPtr(strings.ToUpper(lastName))}
+		err := repo.Iterate(ctx, filter, func(user model.User) error {
+			got = append(got, user.ID)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{ids[0], ids[2]}, got)
+
+		stop := assert.AnError
+		calls := 0
+		err = repo.Iterate(ctx, filter, func(user model.User) error {
+			calls++
+			return stop
+		})
+		assert.Equal(t, stop, err)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,70 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UserHistoryRepositoryContract run the contract of repository.UserHistoryRepository
+func UserHistoryRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.UserHistoryRepository) {
+	ctx := context.Background()
+
+	t.Run("list newest first", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := sequence.Add(1) + time.Now().UnixNano()%1_000_000_000
+		start := time.Now().UTC().Truncate(time.Millisecond)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.Create(ctx, model.UserHistory{
+				UserID:    userID,
+				Action:    model.USER_HISTORY_UPDATE,
+				Changes:   []model.FieldChange{{Field: "first_name", Before: "a", After: "b"}},
+				CreatedAt: start.Add(time.Duration(i) * time.Second),
+			}))
+		}
+		require.NoError(t, repo.Create(ctx, model.UserHistory{UserID: userID + 1, CreatedAt: start}))
+
+		histories, total, err := repo.ListByUserID(ctx, userID, 1, 2)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		require.Len(t, histories, 2)
+		assert.True(t, histories[0].CreatedAt.Equal(start.Add(2*time.Second)))
+		assert.True(t, histories[1].CreatedAt.Equal(start.Add(time.Second)))
+		assert.NotEmpty(t, histories[0].ID)
+
+		histories, _, err = repo.ListByUserID(ctx, userID, 2, 2)
+		require.NoError(t, err)
+		require.Len(t, histories, 1)
+		assert.True(t, histories[0].CreatedAt.Equal(start))
+	})
+
+	t.Run("anonymize", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := sequence.Add(1) + time.Now().UnixNano()%1_000_000_000
+		require.NoError(t, repo.Create(ctx, model.UserHistory{
+			UserID:    userID,
+			Action:    model.USER_HISTORY_UPDATE,
+			Changes:   []model.FieldChange{{Field: "email", Before: "a@example.com", After: "b@example.com"}},
+			CreatedAt: time.Now().UTC(),
+		}))
+
+		require.NoError(t, repo.AnonymizeByUserID(ctx, userID))
+		histories, _, err := repo.ListByUserID(ctx, userID, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, histories, 1)
+		assert.Equal(t, []model.FieldChange{{Field: "email", Before: model.REDACTED, After: model.REDACTED}}, histories[0].Changes)
+	})
+
+	t.Run("create with an existing id", func(t *testing.T) {
+		repo := newRepo(t)
+		history := model.UserHistory{ID: unique("history"), UserID: 1, Action: model.USER_HISTORY_UPDATE, CreatedAt: time.Now().UTC()}
+		require.NoError(t, repo.Create(ctx, history))
+		assert.Equal(t, utils.ErrorDuplicateData, repo.Create(ctx, history))
+	})
+}
@@ -2,16 +2,35 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/erwinwahyura/go-boilerplate/app/database"
 	"github.com/erwinwahyura/go-boilerplate/app/model"
 
 	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 var (
 	TableUser = fmt.Sprintf("%v.%v", "public", "user")
+
+	userColumns = `id, email, first_name, last_name, phone_number, username, password, last_login,
+		is_superuser, is_staff, is_active, verified, is_guest, is_deleted, date_joined, properties,
+		corporate_account_id, author_id, birth_place, birth_date, gender, home_phone_number, occupation,
+		hobby, identity_image, identity_number, identity_type, phone_number_bidx, updated_at`
+
+	// unique constraints of table user
+	constraintUserEmail    = "user_email_key"
+	constraintUserUsername = "user_username_key"
+	constraintUserPhone    = "user_phone_number_bidx_key"
+
+	pqUniqueViolation pq.ErrorCode = "23505"
 )
 
 type UserRepositoryFilter struct {
@@ -27,7 +46,22 @@ type (
 
 	// Repository Inteface
 	UserRepository interface {
-		Create(ctx context.Context, user string) (*model.User, error)
+		Create(ctx context.Context, user model.User) (*model.User, error)
+		GetByID(ctx context.Context, id int64) (*model.User, error)
+		GetByEmail(ctx context.Context, email string) (*model.User, error)
+		GetByPhoneNumber(ctx context.Context, phoneNumber string) (*model.User, error)
+		ExistsByUsername(ctx context.Context, username string) (bool, error)
+		// Update save user when its UpdatedAt still matches the row, then set the new UpdatedAt.
+		// ErrorConflict when the row was updated since user was read.
+		Update(ctx context.Context, user *model.User) error
+		// Anonymize erase the personal data of user, the row and its foreign keys are kept
+		Anonymize(ctx context.Context, id int64) error
+		// Iterate stream the users matching the filter without loading the whole table
+		Iterate(ctx context.Context, filter UserRepositoryFilter, fn func(user model.User) error) error
+		// RotateEncryptionKeys rewrap the encrypted columns of up to limit rows after afterID with
+		// the current master key and encrypt the legacy plaintext, returns the number of rows
+		// updated and the last id of the batch, 0 when no row is left
+		RotateEncryptionKeys(ctx context.Context, afterID int64, limit int) (int, int64, error)
 	}
 
 	// Implementation
@@ -43,13 +77,333 @@ func NewUserRepository(postgresCollection database.PostgresCollection) UserRepos
 	}
 }
 
-func (r UserRepositoryImpl) Create(ctx context.Context, user string) (*model.User, error) {
-	var responseUser model.User
-	// TODO: add get user
-	responseUser.AuthorID = 1
-	responseUser.FirstName = &user
+// Create insert new user
+func (r UserRepositoryImpl) Create(ctx context.Context, user model.User) (*model.User, error) {
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = utils.TimeNow()
+	}
+	if err := setPhoneNumberIndex(&user); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (
+		email, first_name, last_name, phone_number, username, password, last_login, is_superuser,
+		is_staff, is_active, verified, is_guest, is_deleted, date_joined, properties, corporate_account_id,
+		author_id, birth_place, birth_date, gender, home_phone_number, occupation, hobby, identity_image,
+		identity_number, identity_type, phone_number_bidx
+	) VALUES (
+		:email, :first_name, :last_name, :phone_number, :username, :password, :last_login, :is_superuser,
+		:is_staff, :is_active, :verified, :is_guest, :is_deleted, :date_joined, :properties, :corporate_account_id,
+		:author_id, :birth_place, :birth_date, :gender, :home_phone_number, :occupation, :hobby, :identity_image,
+		:identity_number, :identity_type, :phone_number_bidx
+	) RETURNING id, updated_at`, TableUser)
+	query, args, err := sqlx.Named(query, user)
+	if err != nil {
+		return nil, err
+	}
+	writer := r.postgresCollection.Writer(ctx)
+	query = writer.Rebind(query)
+
+	var inserted struct {
+		ID        int64     `db:"id"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+	if err := writer.GetContext(ctx, &inserted, query, args...); err != nil {
+		return nil, mapUniqueViolation(err)
+	}
+	user.ID, user.UpdatedAt = inserted.ID, inserted.UpdatedAt
+
+	return &user, nil
+}
 
-	// call db
+// GetByID get user by id
+func (r UserRepositoryImpl) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1 AND is_deleted = false", userColumns, TableUser)
+	err := r.postgresCollection.Reader(ctx).GetContext(ctx, &user, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrorNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetByEmail get user by email, the email is case insensitive
+func (r UserRepositoryImpl) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE lower(email) = lower($1) AND is_deleted = false", userColumns, TableUser)
+	err := r.postgresCollection.Reader(ctx).GetContext(ctx, &user, query, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrorNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetByPhoneNumber exact-match lookup through the blind index, phoneNumber must be in E.164 (see utils/phone)
+func (r UserRepositoryImpl) GetByPhoneNumber(ctx context.Context, phoneNumber string) (*model.User, error) {
+	keyring := fieldcrypt.Default()
+	if keyring == nil {
+		return nil, fieldcrypt.ErrKeyringNotConfigured
+	}
+
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE phone_number_bidx = $1 AND is_deleted = false", userColumns, TableUser)
+	err := r.postgresCollection.Reader(ctx).GetContext(ctx, &user, query, keyring.BlindIndex(phoneNumber))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrorNotFound
+		}
+		return nil, err
+	}
 
-	return nil, utils.ErrorNotFound
+	return &user, nil
+}
+
+// ExistsByUsername check if username is taken, the username is case insensitive
+func (r UserRepositoryImpl) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE lower(username) = lower($1))", TableUser)
+	err := r.postgresCollection.Reader(ctx).GetContext(ctx, &exists, query, username)
+	return exists, err
+}
+
+// Update update the mutable columns of user
+func (r UserRepositoryImpl) Update(ctx context.Context, user *model.User) error {
+	if err := setPhoneNumberIndex(user); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET
+		email = :email, first_name = :first_name, last_name = :last_name, phone_number = :phone_number,
+		username = :username, is_superuser = :is_superuser, is_staff = :is_staff, is_active = :is_active,
+		verified = :verified, properties = :properties, corporate_account_id = :corporate_account_id,
+		author_id = :author_id, birth_place = :birth_place, birth_date = :birth_date, gender = :gender,
+		home_phone_number = :home_phone_number, occupation = :occupation, hobby = :hobby,
+		identity_image = :identity_image, identity_number = :identity_number, identity_type = :identity_type,
+		phone_number_bidx = :phone_number_bidx, updated_at = clock_timestamp()
+		WHERE id = :id AND is_deleted = false AND updated_at = :updated_at
+		RETURNING updated_at`, TableUser)
+	query, args, err := sqlx.Named(query, user)
+	if err != nil {
+		return err
+	}
+	writer := r.postgresCollection.Writer(ctx)
+	query = writer.Rebind(query)
+
+	var updatedAt time.Time
+	if err := writer.GetContext(ctx, &updatedAt, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.missingOrConflict(ctx, user.ID)
+		}
+		return mapUniqueViolation(err)
+	}
+	user.UpdatedAt = updatedAt
+
+	return nil
+}
+
+// missingOrConflict why an update matched no row, checked on the writer so a lagging slave does
+// not report a conflict as not found
+func (r UserRepositoryImpl) missingOrConflict(ctx context.Context, id int64) error {
+	var exists bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND is_deleted = false)", TableUser)
+	if err := r.postgresCollection.Writer(ctx).GetContext(ctx, &exists, query, id); err != nil {
+		return err
+	}
+	if !exists {
+		return utils.ErrorNotFound
+	}
+	return utils.ErrorConflict
+}
+
+// Anonymize erase the personal data of user and mark it deleted, author_id and
+// corporate_account_id are kept so the referencing rows stay valid
+func (r UserRepositoryImpl) Anonymize(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET
+		email = $2, username = $3, first_name = NULL, last_name = NULL, phone_number = NULL,
+		phone_number_bidx = NULL, password = NULL, last_login = NULL, is_active = false, is_deleted = true,
+		properties = '', birth_place = NULL, birth_date = NULL, gender = NULL, home_phone_number = NULL,
+		occupation = NULL, hobby = NULL, identity_image = NULL, identity_number = NULL, identity_type = NULL,
+		updated_at = clock_timestamp()
+		WHERE id = $1`, TableUser)
+	res, err := r.postgresCollection.Writer(ctx).ExecContext(ctx, query, id, model.AnonymizedEmail(id), model.AnonymizedUsername(id))
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return utils.ErrorNotFound
+	}
+
+	return nil
+}
+
+// Iterate stream the users matching the filter ordered by id
+func (r UserRepositoryImpl) Iterate(ctx context.Context, filter UserRepositoryFilter, fn func(user model.User) error) error {
+	where, args := buildUserFilter(filter)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY id", userColumns, TableUser, where)
+
+	rows, err := r.postgresCollection.Reader(ctx).QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user model.User
+		if err := rows.StructScan(&user); err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// buildUserFilter build the where clause of UserRepositoryFilter
+func buildUserFilter(filter UserRepositoryFilter) (string, []interface{}) {
+	conditions := []string{"is_deleted = false"}
+	args := []interface{}{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.FirstName != nil {
+		addCondition("first_name ILIKE $%d", "%"+*filter.FirstName+"%")
+	}
+	if filter.LastName != nil {
+		addCondition("last_name ILIKE $%d", "%"+*filter.LastName+"%")
+	}
+	if filter.Username != nil {
+		addCondition("username = $%d", *filter.Username)
+	}
+	if filter.Email != nil {
+		addCondition("lower(email) = lower($%d)", *filter.Email)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// encryptedUserColumns raw value of the encrypted columns of table user
+type encryptedUserColumns struct {
+	ID             int64   `db:"id"`
+	IdentityNumber *string `db:"identity_number"`
+	PhoneNumber    *string `db:"phone_number"`
+	HomePhone      *string `db:"home_phone_number"`
+	BirthDate      *string `db:"birth_date"`
+}
+
+// RotateEncryptionKeys rewrap one batch in id order, the caller keeps the last id so a row failing
+// to rotate is not selected again by the next batch
+func (r UserRepositoryImpl) RotateEncryptionKeys(ctx context.Context, afterID int64, limit int) (int, int64, error) {
+	keyring := fieldcrypt.Default()
+	if keyring == nil {
+		return 0, 0, fieldcrypt.ErrKeyringNotConfigured
+	}
+
+	current := fmt.Sprintf("enc:v1:%d:%%", keyring.CurrentVersion())
+	selectQuery := fmt.Sprintf(`SELECT id, identity_number, phone_number, home_phone_number, birth_date::text AS birth_date
+		FROM %s
+		WHERE id > $2 AND ((identity_number IS NOT NULL AND identity_number NOT LIKE $1)
+			OR (phone_number IS NOT NULL AND phone_number NOT LIKE $1)
+			OR (home_phone_number IS NOT NULL AND home_phone_number NOT LIKE $1)
+			OR (birth_date IS NOT NULL AND birth_date::text NOT LIKE $1))
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE`, TableUser)
+	updateQuery := fmt.Sprintf(`UPDATE %s SET identity_number = :identity_number, phone_number = :phone_number,
+		home_phone_number = :home_phone_number, birth_date = :birth_date WHERE id = :id`, TableUser)
+
+	var rows []encryptedUserColumns
+	if err := r.postgresCollection.Writer(ctx).SelectContext(ctx, &rows, selectQuery, current, afterID, limit); err != nil {
+		return 0, 0, err
+	}
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+
+	for _, row := range rows {
+		for _, column := range []*string{row.IdentityNumber, row.PhoneNumber, row.HomePhone, row.BirthDate} {
+			if err := rotateColumn(keyring, column); err != nil {
+				return 0, 0, fmt.Errorf("rotate user %d: %w", row.ID, err)
+			}
+		}
+		if _, err := r.postgresCollection.Writer(ctx).NamedExecContext(ctx, updateQuery, row); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(rows), rows[len(rows)-1].ID, nil
+}
+
+// rotateColumn rewrap value in place, legacy plaintext is encrypted
+func rotateColumn(keyring *fieldcrypt.Keyring, value *string) error {
+	if value == nil {
+		return nil
+	}
+	if !fieldcrypt.IsEncrypted(*value) {
+		encrypted, err := keyring.Encrypt([]byte(*value))
+		if err != nil {
+			return err
+		}
+		*value = encrypted
+		return nil
+	}
+
+	rotated, _, err := keyring.Rotate(*value)
+	if err != nil {
+		return err
+	}
+	*value = rotated
+	return nil
+}
+
+// setPhoneNumberIndex keep the blind index in sync with the phone number
+func setPhoneNumberIndex(user *model.User) error {
+	if user.PhoneNumber == nil {
+		user.PhoneNumberIndex = nil
+		return nil
+	}
+
+	keyring := fieldcrypt.Default()
+	if keyring == nil {
+		return fieldcrypt.ErrKeyringNotConfigured
+	}
+	index := keyring.BlindIndex(user.PhoneNumber.String())
+	user.PhoneNumberIndex = &index
+	return nil
+}
+
+// mapUniqueViolation map the unique constraints of table user into utils errors
+func mapUniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != pqUniqueViolation {
+		return err
+	}
+
+	switch pqErr.Constraint {
+	case constraintUserUsername:
+		return utils.ErrorDuplicateUsername
+	case constraintUserPhone:
+		return utils.ErrorDuplicatePhoneNumber
+	case constraintUserEmail:
+		return utils.ErrorDuplicateData
+	default:
+		return utils.ErrorDuplicateData
+	}
 }
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/ulid"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	CollectionUserHistory = "user_history"
+)
+
+type (
+
+	// Repository Inteface
+	UserHistoryRepository interface {
+		// Create insert history, ErrorDuplicateData when the id is set and exists
+		Create(ctx context.Context, history model.UserHistory) error
+		ListByUserID(ctx context.Context, userID int64, page, size int) ([]model.UserHistory, int64, error)
+		// AnonymizeByUserID redact every recorded value of user, who changed which field and when is kept
+		AnonymizeByUserID(ctx context.Context, userID int64) error
+	}
+
+	// Implementation
+	UserHistoryRepositoryImpl struct {
+		mongoCollection database.MongoCollection
+	}
+)
+
+// New Repository User History, the history is kept in the log database
+func NewUserHistoryRepository(mongoCollection database.MongoCollection) UserHistoryRepository {
+	r := UserHistoryRepositoryImpl{
+		mongoCollection: mongoCollection,
+	}
+
+	// the log database may start degraded, the index is created once it is up
+	mongoCollection.State.OnUp(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := r.collection(false).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		})
+		if err != nil {
+			log.Error().Msgf("error when create index of %s, err: %v", CollectionUserHistory, err)
+		}
+	})
+
+	return r
+}
+
+func (r UserHistoryRepositoryImpl) collection(read bool) *mongo.Collection {
+	if read {
+		return r.mongoCollection.MessageSlave.Collection(CollectionUserHistory)
+	}
+	return r.mongoCollection.MessageMaster.Collection(CollectionUserHistory)
+}
+
+// Create insert history, ErrorDuplicateData when the given id exists
+func (r UserHistoryRepositoryImpl) Create(ctx context.Context, history model.UserHistory) error {
+	if history.ID == "" {
+		history.ID = ulid.GenerateUlidID()
+	}
+	_, err := r.collection(false).InsertOne(ctx, history)
+	if mongo.IsDuplicateKeyError(err) {
+		return utils.ErrorDuplicateData
+	}
+	return err
+}
+
+// ListByUserID list history of user, newest first
+func (r UserHistoryRepositoryImpl) ListByUserID(ctx context.Context, userID int64, page, size int) ([]model.UserHistory, int64, error) {
+	filter := bson.M{"user_id": userID}
+
+	total, err := r.collection(true).CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := r.collection(true).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	histories := []model.UserHistory{}
+	if err := cursor.All(ctx, &histories); err != nil {
+		return nil, 0, err
+	}
+
+	return histories, total, nil
+}
+
+// AnonymizeByUserID redact the before and after values of every change of user
+func (r UserHistoryRepositoryImpl) AnonymizeByUserID(ctx context.Context, userID int64) error {
+	_, err := r.collection(false).UpdateMany(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{
+			"changes.$[].before": model.REDACTED,
+			"changes.$[].after":  model.REDACTED,
+		}},
+	)
+	return err
+}
@@ -6,8 +6,12 @@ import (
 	"github.com/erwinwahyura/go-boilerplate/app/handler"
 	"github.com/erwinwahyura/go-boilerplate/app/middleware"
 	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils/ratelimit"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
@@ -16,10 +20,17 @@ func NewRoutes(
 	config model.Config,
 	healthHandler handler.HealthHandler,
 	userHandler handler.UserHandler,
+	privacyHandler handler.PrivacyHandler,
+	logHandler handler.LogHandler,
+	outboxHandler handler.OutboxHandler,
+	userRepo repository.UserRepository,
+	logHTTPRepo repository.LogHTTPRepository,
+	rateLimiter ratelimit.Store,
+	idempotencyRepo repository.IdempotencyRepository,
 	// another route here
 ) http.Handler {
 	// Middleware
-	mid := middleware.InitMiddleware(config)
+	mid := middleware.InitMiddleware(config, userRepo, logHTTPRepo, rateLimiter, idempotencyRepo)
 
 	// Router
 	r := chi.NewRouter()
@@ -36,9 +47,19 @@ func NewRoutes(
 		r.Get("/swagger/*", httpSwagger.WrapHandler)
 
 		// Health Check
-		r.Get("/healthcheck", healthHandler.Check)
+		r.Get("/livez", healthHandler.Live)
+		r.Get("/readyz", healthHandler.Ready)
+		// healthcheck kept for the existing probes, they are liveness probes: a dependency being
+		// down must not restart the pod
+		r.Get("/healthcheck", healthHandler.Live)
+
+		// Metrics scraped by prometheus, like the probes it is reached by the cluster without a token
+		r.Get("/metrics", promhttp.Handler().ServeHTTP)
 
 		r.Route("/api/v1/public", func(r chi.Router) {
+			r.Use(mid.RateLimit(middleware.RATE_LIMIT_PUBLIC, mid.RateLimitByIP))
+			r.Use(mid.RateLimit(middleware.RATE_LIMIT_API_KEY, mid.RateLimitByAPIKey))
+
 			// auth session
 			r.Route("/auth", func(r chi.Router) {
 				// for auth route here
@@ -47,21 +68,73 @@ func NewRoutes(
 		})
 
 		r.Route("/api/v1/user", func(r chi.Router) {
-			r.Get("/create_user", userHandler.CreateUser)
+			r.Use(mid.RateLimit(middleware.RATE_LIMIT_PUBLIC, mid.RateLimitByIP))
+			r.Use(mid.Idempotency)
+			r.Post("/create_user", userHandler.CreateUser)
 		})
 	})
 
 	// Auth Routes
 	r.Group(func(r chi.Router) {
 		// Set Middleware
+		r.Use(mid.RateLimit(middleware.RATE_LIMIT_AUTH, mid.RateLimitByIP))
 		r.Use(mid.Authenticate)
-		// r.Use(mid.SetRequestID)
+		r.Use(mid.RateLimit(middleware.RATE_LIMIT_USER, mid.RateLimitByUID))
+		r.Use(mid.Idempotency)
 		// r.Use(mid.MiddlewareLogger)
 		// r.Use(mid.ContextMandatoryRequest)
 		r.Route("/api/v1/", func(r chi.Router) {
 			r.Route("/users", func(r chi.Router) {
 
 			})
+
+			// profile of the logged in user
+			r.Route("/me", func(r chi.Router) {
+				r.Get("/", userHandler.GetProfile)
+				r.Patch("/", userHandler.UpdateProfile)
+				r.Delete("/", privacyHandler.RequestErasure)
+
+				// data subject requests
+				r.Get("/export", privacyHandler.RequestExport)
+				// kept for the clients already queueing the export with a POST
+				r.Post("/export", privacyHandler.RequestExport)
+				r.Get("/export/{job_id}/download", privacyHandler.DownloadExport)
+				r.Get("/jobs/{job_id}", privacyHandler.GetJob)
+			})
+		})
+	})
+
+	// Admin Routes
+	r.Group(func(r chi.Router) {
+		// Set Middleware
+		r.Use(mid.RateLimit(middleware.RATE_LIMIT_AUTH, mid.RateLimitByIP))
+		r.Use(mid.Authenticate)
+		r.Use(mid.RequireStaff)
+		r.Route("/admin", func(r chi.Router) {
+			r.Route("/users", func(r chi.Router) {
+				r.Post("/import", userHandler.ImportUsers)
+				r.Get("/export", userHandler.ExportUsers)
+				r.Post("/encryption/rotate", userHandler.RotateEncryptionKeys)
+				r.Get("/encryption/rotate", userHandler.GetEncryptionKeyRotation)
+				r.Get("/{id}", userHandler.GetUser)
+				r.Patch("/{id}", userHandler.UpdateUser)
+				r.Get("/{id}/history", userHandler.GetUserHistory)
+			})
+
+			// logs
+			r.Get("/logs/http", logHandler.SearchHTTPLogs)
+
+			// outbox
+			r.Route("/outbox", func(r chi.Router) {
+				r.Get("/dead", outboxHandler.ListDeadEvents)
+				r.Post("/{id}/requeue", outboxHandler.RequeueEvent)
+			})
+
+			// health
+			r.Get("/health/history", healthHandler.History)
+
+			// metrics
+			r.Get("/database/pools", healthHandler.PoolStats)
 		})
 	})
 
@@ -70,15 +143,21 @@ func NewRoutes(
 
 // setMiddlewareGlobal set middleware global
 func setMiddlewareGlobal(mid *middleware.GoMiddleware, r *chi.Mux) {
+	// Request ID, first so every log line of the request has it
+	r.Use(mid.SetRequestID)
+
 	// Logger
 	r.Use(mid.LogRequest)
 
+	// Compression, after the logger so it logs the size sent
+	r.Use(mid.Compress)
+
 	// Cors
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", constant.RequestID, constant.IdempotencyKey},
+		ExposedHeaders:   append([]string{"Link", "ETag", constant.RequestID, constant.IdempotentReplayed}, middleware.RateLimitHeaders...),
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
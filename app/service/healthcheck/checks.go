@@ -0,0 +1,151 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils/mongodb"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	CHECK_POSTGRES_MASTER   = "postgres master"
+	CHECK_POSTGRES_SLAVE    = "postgres slave"
+	CHECK_MONGODB_LOG       = "mongodb log master"
+	CHECK_MONGODB_LOG_SLAVE = "mongodb log slave"
+	CHECK_REDIS             = "redis"
+	CHECK_MEILISEARCH       = "meilisearch"
+	CHECK_PROMOSERVICE      = "promoservice"
+	CHECK_MYVALUE           = "myvalue"
+)
+
+// RegisterDatabaseChecks postgres master is critical, the slave and the log database only degrade
+// the service. The master checks keep the state of their collection.
+func RegisterDatabaseChecks(registry *Registry, mongoCollection database.MongoCollection, postgresCollection database.PostgresCollection) {
+	registry.Register(Check{
+		Name:        CHECK_POSTGRES_MASTER,
+		Criticality: model.HEALTH_CRITICAL,
+		Check:       postgresCollection.State.Track(postgresCollection.Master.PingContext),
+	})
+	if postgresCollection.Slave != nil {
+		registry.Register(Check{
+			Name:        CHECK_POSTGRES_SLAVE,
+			Criticality: model.HEALTH_DEGRADED,
+			Check:       postgresSlaveCheck(postgresCollection),
+		})
+	}
+	registry.Register(Check{
+		Name:        CHECK_MONGODB_LOG,
+		Criticality: model.HEALTH_DEGRADED,
+		Check: mongoCollection.State.Track(func(ctx context.Context) error {
+			return mongodb.Ping(ctx, mongoCollection.MessageMaster)
+		}),
+	})
+	registry.Register(Check{
+		Name:        CHECK_MONGODB_LOG_SLAVE,
+		Criticality: model.HEALTH_DEGRADED,
+		Check:       mongoSlaveCheck(mongoCollection.MessageSlave),
+	})
+}
+
+// RegisterOutboundChecks the configured outbound dependencies, they are degraded unless listed in
+// HEALTH_CRITICAL_DEPENDENCIES. redisClient is nil when redis is not configured.
+func RegisterOutboundChecks(registry *Registry, config model.Config, redisClient *redis.Client) {
+	critical := map[string]bool{}
+	for _, name := range strings.Split(config.Health.CriticalDependencies, ",") {
+		critical[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	criticality := func(name string) string {
+		if critical[name] {
+			return model.HEALTH_CRITICAL
+		}
+		return model.HEALTH_DEGRADED
+	}
+
+	if redisClient != nil {
+		registry.Register(Check{
+			Name:        CHECK_REDIS,
+			Criticality: criticality(CHECK_REDIS),
+			Check: func(ctx context.Context) error {
+				return redisClient.Ping(ctx).Err()
+			},
+		})
+	}
+	if config.Meilisearch.Host != "" {
+		registry.Register(Check{
+			Name:        CHECK_MEILISEARCH,
+			Criticality: criticality(CHECK_MEILISEARCH),
+			Check:       httpCheck(meilisearchURL(config.Meilisearch) + "/health"),
+		})
+	}
+	if config.PromoService.BaseURL != "" {
+		registry.Register(Check{
+			Name:        CHECK_PROMOSERVICE,
+			Criticality: criticality(CHECK_PROMOSERVICE),
+			Check:       httpCheck(config.PromoService.BaseURL),
+		})
+	}
+	if config.MyValue.BaseURL != "" {
+		registry.Register(Check{
+			Name:        CHECK_MYVALUE,
+			Criticality: criticality(CHECK_MYVALUE),
+			Check:       httpCheck(config.MyValue.BaseURL),
+		})
+	}
+}
+
+// postgresSlaveCheck the slave is down as well when it lags, the reads then go to the master
+func postgresSlaveCheck(postgresCollection database.PostgresCollection) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := postgresCollection.Slave.PingContext(ctx); err != nil {
+			return err
+		}
+		if !postgresCollection.ReplicaHealthy() {
+			return fmt.Errorf("lagging %s, reads go to the master", postgresCollection.ReplicaLag())
+		}
+		return nil
+	}
+}
+
+// mongoSlaveCheck the slave dsn may only reach secondaries
+func mongoSlaveCheck(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return db.Client().Ping(ctx, readpref.Nearest())
+	}
+}
+
+// httpCheck the service answers url, only a 5xx is down since the url may need authentication
+func httpCheck(url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("status %d", res.StatusCode)
+		}
+		return nil
+	}
+}
+
+func meilisearchURL(config model.Meilisearch) string {
+	host := config.Host
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	if config.Port == "" {
+		return host
+	}
+	return host + ":" + config.Port
+}
@@ -2,19 +2,25 @@ package healthcheck
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/erwinwahyura/go-boilerplate/app/database"
 	"github.com/erwinwahyura/go-boilerplate/app/model"
-	"github.com/hellofresh/health-go/v4"
+	"github.com/erwinwahyura/go-boilerplate/utils"
 	"github.com/opentracing/opentracing-go"
 )
 
 type (
 	// HealthService health service
 	HealthService interface {
-		Check(ctx context.Context) (model.HealthCheckResponse, error)
+		// Live the process is running, the dependencies are not checked
+		Live(ctx context.Context) (model.HealthCheckResponse, error)
+		// Ready last result of every dependency check, ErrorServiceUnavailable when a critical one is down
+		// or the service is shutting down
+		Ready(ctx context.Context) (model.HealthCheckResponse, error)
+		// History last results of every dependency check
+		History(ctx context.Context) ([]model.HealthCheckHistory, error)
+		PoolStats(ctx context.Context) (model.DatabasePoolStatsResponse, error)
 	}
 
 	// HealthServiceImpl implementation
@@ -22,6 +28,7 @@ type (
 		config             model.Config
 		mongoCollection    database.MongoCollection
 		postgresCollection database.PostgresCollection
+		registry           *Registry
 	}
 )
 
@@ -30,17 +37,27 @@ func NewService(
 	config model.Config,
 	mongoCollection database.MongoCollection,
 	postgresCollection database.PostgresCollection,
+	registry *Registry,
 ) HealthService {
 	return HealthServiceImpl{
 		config:             config,
 		mongoCollection:    mongoCollection,
 		postgresCollection: postgresCollection,
+		registry:           registry,
 	}
 }
 
-// Check health check
-func (uc HealthServiceImpl) Check(ctx context.Context) (model.HealthCheckResponse, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "HealthServiceImpl.Check")
+// Live liveness, up as long as the process can answer
+func (uc HealthServiceImpl) Live(ctx context.Context) (model.HealthCheckResponse, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "HealthServiceImpl.Live")
+	defer span.Finish()
+
+	return model.HealthCheckResponse{Status: model.HEALTH_STATUS_UP, CheckedAt: utils.TimeNow()}, nil
+}
+
+// Ready readiness from the results of the registry, the probes never wait on a dependency
+func (uc HealthServiceImpl) Ready(ctx context.Context) (model.HealthCheckResponse, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "HealthServiceImpl.Ready")
 	defer span.Finish()
 
 	var err error
@@ -51,27 +68,46 @@ func (uc HealthServiceImpl) Check(ctx context.Context) (model.HealthCheckRespons
 		}
 	}(time.Now(), err)
 
-	var response model.HealthCheckResponse
-	h, err := health.New()
-	if err != nil {
-		response.Errors = append(response.Errors, "failed to init healthcheck")
+	response := model.HealthCheckResponse{
+		Status:     model.HEALTH_STATUS_UP,
+		Components: uc.registry.Latest(),
+		CheckedAt:  utils.TimeNow(),
 	}
-	// custom health check example (fail)
-	healthTest := h.Register(health.Config{
-		Name:      "service-check",
-		Timeout:   time.Second * 5,
-		SkipOnErr: true,
-		Check: func(context.Context) error {
-			return errors.New("failed during health check")
-		},
-	})
-	if healthTest != nil {
-		response.Errors = append(response.Errors, "error ping service")
-		return response, err
+	if uc.registry.Draining() {
+		response.Status = model.HEALTH_STATUS_DOWN
+		response.Draining = true
+		err = utils.ErrorServiceUnavailable
+	}
+	for _, component := range response.Components {
+		if component.Status == model.HEALTH_STATUS_UP {
+			continue
+		}
+		if component.Criticality == model.HEALTH_CRITICAL {
+			response.Status = model.HEALTH_STATUS_DOWN
+			err = utils.ErrorServiceUnavailable
+		} else if response.Status == model.HEALTH_STATUS_UP {
+			response.Status = model.HEALTH_STATUS_DEGRADED
+		}
 	}
-	response.Success = append(response.Success, "success ping service")
-
-	// TODO: check db connection
 
 	return response, err
 }
+
+// History last results of every dependency check, newest first
+func (uc HealthServiceImpl) History(ctx context.Context) ([]model.HealthCheckHistory, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "HealthServiceImpl.History")
+	defer span.Finish()
+
+	return uc.registry.History(), nil
+}
+
+// PoolStats connection pools of every datasource
+func (uc HealthServiceImpl) PoolStats(ctx context.Context) (model.DatabasePoolStatsResponse, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "HealthServiceImpl.PoolStats")
+	defer span.Finish()
+
+	return model.DatabasePoolStatsResponse{
+		Postgres: uc.postgresCollection.PoolStats(),
+		MongoLog: uc.mongoCollection.PoolStats(),
+	}, nil
+}
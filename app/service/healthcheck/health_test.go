@@ -0,0 +1,76 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failed := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	newService := func(t *testing.T, checks ...Check) HealthService {
+		registry := NewRegistry(model.Config{})
+		for _, check := range checks {
+			registry.Register(check)
+		}
+		registry.Start()
+		t.Cleanup(func() { registry.Stop(context.Background()) })
+		return NewService(model.Config{}, database.MongoCollection{}, database.PostgresCollection{}, registry)
+	}
+
+	t.Run("up", func(t *testing.T) {
+		s := newService(t, Check{Name: "master", Criticality: model.HEALTH_CRITICAL, Check: ok})
+		res, err := s.Ready(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, model.HEALTH_STATUS_UP, res.Status)
+		require.Len(t, res.Components, 1)
+		assert.Equal(t, model.HEALTH_STATUS_UP, res.Components[0].Status)
+	})
+
+	t.Run("degraded dependency down", func(t *testing.T) {
+		s := newService(t,
+			Check{Name: "master", Criticality: model.HEALTH_CRITICAL, Check: ok},
+			Check{Name: "redis", Criticality: model.HEALTH_DEGRADED, Check: failed},
+		)
+		res, err := s.Ready(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, model.HEALTH_STATUS_DEGRADED, res.Status)
+		assert.Equal(t, "connection refused", res.Components[1].Error)
+	})
+
+	t.Run("critical dependency down", func(t *testing.T) {
+		s := newService(t,
+			Check{Name: "master", Criticality: model.HEALTH_CRITICAL, Check: failed},
+			Check{Name: "redis", Criticality: model.HEALTH_DEGRADED, Check: ok},
+		)
+		res, err := s.Ready(context.Background())
+		assert.Equal(t, utils.ErrorServiceUnavailable, err)
+		assert.Equal(t, model.HEALTH_STATUS_DOWN, res.Status)
+		assert.Equal(t, model.HEALTH_STATUS_UP, res.Components[1].Status)
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		registry := NewRegistry(model.Config{})
+		registry.Register(Check{Name: "master", Criticality: model.HEALTH_CRITICAL, Check: ok})
+		registry.Start()
+		defer registry.Stop(context.Background())
+		s := NewService(model.Config{}, database.MongoCollection{}, database.PostgresCollection{}, registry)
+
+		registry.Drain()
+		res, err := s.Ready(context.Background())
+		assert.Equal(t, utils.ErrorServiceUnavailable, err)
+		assert.Equal(t, model.HEALTH_STATUS_DOWN, res.Status)
+		assert.True(t, res.Draining)
+
+		_, err = s.Live(context.Background())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,264 @@
+package healthcheck
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// DEFAULT_CHECK_TIMEOUT used when HEALTH_CHECK_TIMEOUT is not set
+	DEFAULT_CHECK_TIMEOUT = 2 * time.Second
+	// DEFAULT_CHECK_INTERVAL used when HEALTH_CHECK_INTERVAL is not set
+	DEFAULT_CHECK_INTERVAL = 10 * time.Second
+	// DEFAULT_HISTORY_SIZE used when HEALTH_HISTORY_SIZE is not set
+	DEFAULT_HISTORY_SIZE = 20
+)
+
+type (
+	// Check of one dependency, a zero Interval or Timeout uses the default of the registry
+	Check struct {
+		Name string
+		// Criticality model.HEALTH_CRITICAL fails the readiness, model.HEALTH_DEGRADED only warns
+		Criticality string
+		Interval    time.Duration
+		Timeout     time.Duration
+		Check       func(ctx context.Context) error
+	}
+
+	// Registry run the registered checks in the background, every check on its own interval, and
+	// keep the last results of each so the probes never wait on a dependency
+	Registry struct {
+		interval    time.Duration
+		timeout     time.Duration
+		historySize int
+
+		// draining set on shutdown, the service is not ready anymore whatever the checks say
+		draining atomic.Bool
+
+		mu      sync.RWMutex
+		checks  []*registeredCheck
+		ctx     context.Context
+		stop    context.CancelFunc
+		running sync.WaitGroup
+	}
+
+	registeredCheck struct {
+		Check
+		// history oldest first, at most historySize results
+		history []model.HealthResult
+	}
+)
+
+// NewRegistry initialize health check registry
+func NewRegistry(config model.Config) *Registry {
+	interval := time.Duration(config.Health.CheckInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = DEFAULT_CHECK_INTERVAL
+	}
+	timeout := time.Duration(config.Health.CheckTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = DEFAULT_CHECK_TIMEOUT
+	}
+	historySize := config.Health.HistorySize
+	if historySize <= 0 {
+		historySize = DEFAULT_HISTORY_SIZE
+	}
+
+	return &Registry{interval: interval, timeout: timeout, historySize: historySize}
+}
+
+// Register add the check, a check registered after Start runs right away
+func (r *Registry) Register(check Check) {
+	if check.Interval <= 0 {
+		check.Interval = r.interval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = r.timeout
+	}
+	if check.Criticality == "" {
+		check.Criticality = model.HEALTH_DEGRADED
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	registered := &registeredCheck{Check: check}
+	r.checks = append(r.checks, registered)
+	if r.ctx != nil {
+		r.running.Add(1)
+		go r.loop(r.ctx, registered, true)
+	}
+}
+
+// Start run every check once, so the first probe already has results, then keep running them on
+// their interval until Stop
+func (r *Registry) Start() {
+	r.mu.Lock()
+	if r.ctx != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.ctx, r.stop = context.WithCancel(context.Background())
+	checks := append([]*registeredCheck{}, r.checks...)
+	r.mu.Unlock()
+
+	var first sync.WaitGroup
+	for _, check := range checks {
+		first.Add(1)
+		go func(check *registeredCheck) {
+			defer first.Done()
+			r.run(r.ctx, check)
+		}(check)
+	}
+	first.Wait()
+
+	for _, check := range checks {
+		r.running.Add(1)
+		go r.loop(r.ctx, check, false)
+	}
+}
+
+// Stop the checks and wait for the ones in flight until ctx is done
+func (r *Registry) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	stop := r.stop
+	r.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	stop()
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain fail the readiness from now on, called first on shutdown so the load balancers stop
+// routing to the instance while it still serves the requests in flight
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Draining Drain was called
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Latest result of every check in the order they were registered, a check that did not run yet is
+// model.HEALTH_STATUS_UNKNOWN
+func (r *Registry) Latest() []model.HealthComponent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	components := make([]model.HealthComponent, 0, len(r.checks))
+	for _, check := range r.checks {
+		result := model.HealthResult{Status: model.HEALTH_STATUS_UNKNOWN}
+		if len(check.history) > 0 {
+			result = check.history[len(check.history)-1]
+		}
+		components = append(components, model.HealthComponent{
+			Name:         check.Name,
+			Criticality:  check.Criticality,
+			HealthResult: result,
+		})
+	}
+	return components
+}
+
+// History last results of every check, newest first
+func (r *Registry) History() []model.HealthCheckHistory {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	histories := make([]model.HealthCheckHistory, 0, len(r.checks))
+	for _, check := range r.checks {
+		results := make([]model.HealthResult, 0, len(check.history))
+		for i := len(check.history) - 1; i >= 0; i-- {
+			results = append(results, check.history[i])
+		}
+		histories = append(histories, model.HealthCheckHistory{
+			Name:        check.Name,
+			Criticality: check.Criticality,
+			Interval:    check.Interval.Milliseconds(),
+			Results:     results,
+		})
+	}
+	return histories
+}
+
+func (r *Registry) loop(ctx context.Context, check *registeredCheck, runNow bool) {
+	defer r.running.Done()
+
+	if runNow {
+		r.run(ctx, check)
+	}
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.run(ctx, check)
+		}
+	}
+}
+
+// run the check until its timeout and record the result
+func (r *Registry) run(parent context.Context, check *registeredCheck) {
+	ctx, cancel := context.WithTimeout(parent, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check.Check(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	// stopped, the dependency is not down
+	if parent.Err() != nil {
+		return
+	}
+
+	result := model.HealthResult{
+		Status:    model.HEALTH_STATUS_UP,
+		Latency:   float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: utils.TimeNow(),
+	}
+	if err != nil {
+		result.Status = model.HEALTH_STATUS_DOWN
+		result.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := model.HEALTH_STATUS_UNKNOWN
+	if len(check.history) > 0 {
+		previous = check.history[len(check.history)-1].Status
+	}
+	check.history = append(check.history, result)
+	if len(check.history) > r.historySize {
+		check.history = check.history[len(check.history)-r.historySize:]
+	}
+
+	// log the transitions only, the checks run every few seconds
+	if result.Status != previous {
+		if err != nil {
+			log.Error().Msgf("health check %s (%s) is down, err: %v", check.Name, check.Criticality, err)
+		} else {
+			log.Info().Msgf("health check %s is up", check.Name)
+		}
+	}
+}
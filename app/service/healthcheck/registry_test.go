@@ -0,0 +1,70 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(model.Config{Health: model.Health{HistorySize: 3}})
+
+	var calls atomic.Int32
+	registry.Register(Check{
+		Name:     "redis",
+		Interval: 5 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			if calls.Add(1)%2 == 0 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	})
+	registry.Register(Check{
+		Name:        "postgres master",
+		Criticality: model.HEALTH_CRITICAL,
+		Timeout:     10 * time.Millisecond,
+		Interval:    time.Hour,
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	// the first round runs before Start returns
+	registry.Start()
+	latest := registry.Latest()
+	require.Len(t, latest, 2)
+	assert.Equal(t, model.HEALTH_STATUS_UP, latest[0].Status)
+	assert.Equal(t, model.HEALTH_DEGRADED, latest[0].Criticality)
+	assert.Equal(t, model.HEALTH_STATUS_DOWN, latest[1].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), latest[1].Error)
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 5 }, time.Second, time.Millisecond)
+	require.NoError(t, registry.Stop(context.Background()))
+
+	histories := registry.History()
+	require.Len(t, histories, 2)
+	assert.Len(t, histories[0].Results, 3)
+	assert.Equal(t, int64(5), histories[0].Interval)
+	assert.NotEqual(t, histories[0].Results[0].Status, histories[0].Results[1].Status)
+	assert.False(t, histories[0].Results[0].CheckedAt.Before(histories[0].Results[1].CheckedAt))
+	assert.Len(t, histories[1].Results, 1)
+}
+
+func TestRegistryRegisterAfterStart(t *testing.T) {
+	registry := NewRegistry(model.Config{})
+	registry.Start()
+	defer registry.Stop(context.Background())
+
+	registry.Register(Check{Name: "myvalue", Check: func(ctx context.Context) error { return nil }})
+	assert.Eventually(t, func() bool {
+		return registry.Latest()[0].Status == model.HEALTH_STATUS_UP
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,61 @@
+package logs
+
+import (
+	"context"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// LogService service
+	LogService interface {
+		SearchHTTPLogs(ctx context.Context, filter model.LogHTTPFilter, page, size int) ([]model.LogHTTP, model.PaginationMeta, error)
+	}
+
+	// LogServiceImpl implementation
+	LogServiceImpl struct {
+		logHTTPRepo repository.LogHTTPRepository
+	}
+)
+
+// NewService initialize log service
+func NewService(logHTTPRepo repository.LogHTTPRepository) LogService {
+	return LogServiceImpl{
+		logHTTPRepo: logHTTPRepo,
+	}
+}
+
+// SearchHTTPLogs list the http logs matching filter, newest first
+func (s LogServiceImpl) SearchHTTPLogs(ctx context.Context, filter model.LogHTTPFilter, page, size int) ([]model.LogHTTP, model.PaginationMeta, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LogServiceImpl.SearchHTTPLogs")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = model.DEFAULT_PAGINATION_SIZE
+	}
+	meta := model.PaginationMeta{Page: page, Size: size}
+
+	logs, total, err := s.logHTTPRepo.Search(ctx, filter, page, size)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when logHTTPRepo.Search(), err: %v", err)
+		return nil, meta, err
+	}
+	meta.Total = total
+
+	return logs, meta, nil
+}
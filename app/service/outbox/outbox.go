@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// OutboxService service
+	OutboxService interface {
+		ListDeadEvents(ctx context.Context, page, size int) ([]model.OutboxEvent, model.PaginationMeta, error)
+		RequeueEvent(ctx context.Context, id int64) error
+	}
+
+	// OutboxServiceImpl implementation
+	OutboxServiceImpl struct {
+		outboxRepo repository.OutboxRepository
+	}
+)
+
+// NewService initialize outbox service
+func NewService(outboxRepo repository.OutboxRepository) OutboxService {
+	return OutboxServiceImpl{
+		outboxRepo: outboxRepo,
+	}
+}
+
+// ListDeadEvents events the relay gave up on, newest first
+func (s OutboxServiceImpl) ListDeadEvents(ctx context.Context, page, size int) ([]model.OutboxEvent, model.PaginationMeta, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OutboxServiceImpl.ListDeadEvents")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = model.DEFAULT_PAGINATION_SIZE
+	}
+	meta := model.PaginationMeta{Page: page, Size: size}
+
+	events, total, err := s.outboxRepo.ListDead(ctx, page, size)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when outboxRepo.ListDead(), err: %v", err)
+		return nil, meta, err
+	}
+	meta.Total = total
+
+	return events, meta, nil
+}
+
+// RequeueEvent publish a dead event again, e.g. after the consumer is fixed
+func (s OutboxServiceImpl) RequeueEvent(ctx context.Context, id int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OutboxServiceImpl.RequeueEvent")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	if err = s.outboxRepo.Requeue(ctx, id); err != nil {
+		log.Ctx(ctx).Error().Msgf("error when outboxRepo.Requeue(), id: %d, err: %v", id, err)
+		return err
+	}
+
+	return nil
+}
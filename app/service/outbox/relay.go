@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/outbound"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/backoff"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// DEFAULT_POLL_INTERVAL used when OUTBOX_POLL_INTERVAL is not set
+	DEFAULT_POLL_INTERVAL = time.Second
+	// DEFAULT_BATCH_SIZE used when OUTBOX_BATCH_SIZE is not set
+	DEFAULT_BATCH_SIZE = 100
+	// DEFAULT_MAX_ATTEMPTS used when OUTBOX_MAX_ATTEMPTS is not set
+	DEFAULT_MAX_ATTEMPTS = 10
+	// DEFAULT_RETENTION used when OUTBOX_RETENTION is not set
+	DEFAULT_RETENTION = 7 * 24 * time.Hour
+
+	// PUBLISH_TIMEOUT of a single event
+	PUBLISH_TIMEOUT = 5 * time.Second
+	// LEASE_MARGIN added to the time the batch may take to publish before its lease runs out
+	LEASE_MARGIN = 30 * time.Second
+
+	// retryBackoff wait before a failed event is published again, 1s 2s 4s ... up to 10m
+	retryBackoff = backoff.Backoff{Initial: time.Second, Max: 10 * time.Minute, Multiplier: 2}
+)
+
+// Relay publish the pending events of the outbox. Every poll leases a batch in a short
+// transaction and publishes it outside of any transaction, so no connection is held while the
+// broker is slow. Many instances can run the relay, the events of a crashed relay are claimed
+// again once their lease runs out: delivery is at least once.
+type Relay struct {
+	outboxRepo   repository.OutboxRepository
+	publisher    outbound.Publisher
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	retention    time.Duration
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewRelay initialize outbox relay
+func NewRelay(config model.Config, outboxRepo repository.OutboxRepository, publisher outbound.Publisher) *Relay {
+	pollInterval := time.Duration(config.Outbox.PollInterval) * time.Millisecond
+	if pollInterval <= 0 {
+		pollInterval = DEFAULT_POLL_INTERVAL
+	}
+	batchSize := config.Outbox.BatchSize
+	if batchSize <= 0 {
+		batchSize = DEFAULT_BATCH_SIZE
+	}
+	maxAttempts := config.Outbox.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+	retention := time.Duration(config.Outbox.Retention) * time.Hour
+	if retention <= 0 {
+		retention = DEFAULT_RETENTION
+	}
+
+	return &Relay{
+		outboxRepo:   outboxRepo,
+		publisher:    publisher,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		retention:    retention,
+	}
+}
+
+// Start poll the outbox in the background until Stop
+func (r *Relay) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	r.done = make(chan struct{})
+	go r.run(ctx)
+}
+
+// Stop the polling and wait for the event in flight until ctx is done, the rest of the batch is
+// published again once its lease runs out
+func (r *Relay) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+
+	stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Relay) run(ctx context.Context) {
+	defer close(r.done)
+
+	for {
+		relayed, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Msgf("error when relay outbox, err: %v", err)
+		}
+
+		// a full batch means more events are waiting
+		if err == nil && relayed >= r.batchSize {
+			continue
+		}
+
+		timer := time.NewTimer(r.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// RelayBatch publish one batch of due events, returns the number of events claimed
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	lockedUntil := utils.TimeNow().Add(time.Duration(r.batchSize)*PUBLISH_TIMEOUT + LEASE_MARGIN)
+	events, err := r.outboxRepo.ClaimPending(ctx, r.batchSize, lockedUntil)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := r.publish(ctx, event); err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// PurgePublished remove the events published longer than the retention ago
+func (r *Relay) PurgePublished(ctx context.Context) (int64, error) {
+	return r.outboxRepo.DeletePublishedBefore(ctx, utils.TimeNow().Add(-r.retention))
+}
+
+// publish the event and record the outcome, only the bookkeeping error is returned. The outcome
+// is recorded even when the relay is stopping, the event was published already.
+func (r *Relay) publish(ctx context.Context, event model.OutboxEvent) error {
+	publishCtx, cancel := context.WithTimeout(ctx, PUBLISH_TIMEOUT)
+	err := r.publisher.Publish(publishCtx, event)
+	cancel()
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		return r.outboxRepo.MarkPublished(ctx, event.ID)
+	}
+
+	attempts := event.Attempts + 1
+	dead := attempts >= r.maxAttempts
+	if dead {
+		log.Error().Msgf("error when publish event %d %s, marked dead after %d attempts, err: %v", event.ID, event.EventType, attempts, err)
+	} else {
+		log.Warn().Msgf("error when publish event %d %s, attempt: %d, err: %v", event.ID, event.EventType, attempts, err)
+	}
+
+	return r.outboxRepo.MarkFailed(ctx, event.ID, err.Error(), utils.TimeNow().Add(retryBackoff.Wait(attempts)), dead)
+}
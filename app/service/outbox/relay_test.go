@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	stubOutboxRepo struct {
+		repository.OutboxRepository
+		pending   []model.OutboxEvent
+		published []int64
+		failed    map[int64]bool
+	}
+
+	stubPublisher struct {
+		err error
+	}
+)
+
+func (r *stubOutboxRepo) ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]model.OutboxEvent, error) {
+	return r.pending, nil
+}
+
+func (r *stubOutboxRepo) MarkPublished(ctx context.Context, id int64) error {
+	r.published = append(r.published, id)
+	return nil
+}
+
+func (r *stubOutboxRepo) MarkFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error {
+	r.failed[id] = dead
+	return nil
+}
+
+func (p stubPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	return p.err
+}
+
+func TestRelayBatch(t *testing.T) {
+	repo := &stubOutboxRepo{
+		pending: []model.OutboxEvent{{ID: 1}, {ID: 2}},
+		failed:  map[int64]bool{},
+	}
+	relay := NewRelay(model.Config{}, repo, stubPublisher{})
+
+	claimed, err := relay.RelayBatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	assert.Equal(t, []int64{1, 2}, repo.published)
+}
+
+func TestRelayBatchDeadLetter(t *testing.T) {
+	repo := &stubOutboxRepo{
+		pending: []model.OutboxEvent{{ID: 1, Attempts: 0}, {ID: 2, Attempts: 2}},
+		failed:  map[int64]bool{},
+	}
+	config := model.Config{Outbox: model.Outbox{MaxAttempts: 3}}
+	relay := NewRelay(config, repo, stubPublisher{err: errors.New("broker down")})
+
+	_, err := relay.RelayBatch(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, repo.published)
+	assert.Equal(t, map[int64]bool{1: false, 2: true}, repo.failed)
+}
+
+func TestRelayStartStop(t *testing.T) {
+	repo := &stubOutboxRepo{failed: map[int64]bool{}}
+	relay := NewRelay(model.Config{}, repo, stubPublisher{})
+
+	relay.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, relay.Stop(ctx))
+}
@@ -0,0 +1,374 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/outbound"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/backoff"
+	"github.com/erwinwahyura/go-boilerplate/utils/ulid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// JOB_TIMEOUT deadline of a single attempt of an export or erasure
+	JOB_TIMEOUT = 10 * time.Minute
+	// JOB_LEASE how long a claimed job belongs to its runner, then another instance resumes it
+	JOB_LEASE = JOB_TIMEOUT + time.Minute
+	// JOB_RETENTION how long a finished job and its archive can still be polled and downloaded
+	JOB_RETENTION = 24 * time.Hour
+	// MAX_JOB_ATTEMPTS a job is failed after this many attempts
+	MAX_JOB_ATTEMPTS = 5
+
+	// retryBackoff wait before a failed attempt is run again, 10s 20s 40s ... up to 10m
+	retryBackoff = backoff.Backoff{Initial: 10 * time.Second, Max: 10 * time.Minute, Multiplier: 2}
+
+	historyPageSize = 100
+)
+
+type (
+	// PrivacyService data subject requests of the logged in user
+	PrivacyService interface {
+		RequestExport(ctx context.Context) (model.PrivacyJob, error)
+		RequestErasure(ctx context.Context, ifMatch string) (model.PrivacyJob, error)
+		GetJob(ctx context.Context, jobID string) (model.PrivacyJob, error)
+		OpenExport(ctx context.Context, jobID string) (io.ReadCloser, error)
+		// RunPending claim and run the due jobs, returns how many were claimed. Called by the Runner.
+		RunPending(ctx context.Context, limit int) (int, error)
+		// PurgeFinished remove the jobs finished longer than JOB_RETENTION ago with their archive
+		PurgeFinished(ctx context.Context) (int64, error)
+	}
+
+	// PrivacyServiceImpl implementation, the jobs are stored in the database so any instance
+	// serves them and the Runner of any instance runs them
+	PrivacyServiceImpl struct {
+		config        model.Config
+		userRepo      repository.UserRepository
+		historyRepo   repository.UserHistoryRepository
+		jobRepo       repository.PrivacyJobRepository
+		txManager     database.TxManager
+		imageOutbound outbound.ImageOutbound
+	}
+)
+
+// NewService initialize privacy service
+func NewService(
+	config model.Config,
+	userRepository repository.UserRepository,
+	historyRepository repository.UserHistoryRepository,
+	jobRepository repository.PrivacyJobRepository,
+	txManager database.TxManager,
+	imageOutbound outbound.ImageOutbound,
+) PrivacyService {
+	return PrivacyServiceImpl{
+		config:        config,
+		userRepo:      userRepository,
+		historyRepo:   historyRepository,
+		jobRepo:       jobRepository,
+		txManager:     txManager,
+		imageOutbound: imageOutbound,
+	}
+}
+
+// RequestExport queue building the archive of the user's personal data, an export that is
+// still pending or running is returned instead of queueing another one
+func (s PrivacyServiceImpl) RequestExport(ctx context.Context) (model.PrivacyJob, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PrivacyServiceImpl.RequestExport")
+	defer span.Finish()
+
+	return s.createJob(ctx, model.PRIVACY_JOB_EXPORT)
+}
+
+// RequestErasure queue anonymising the user and removing the identity image, ifMatch must match
+// the ETag of the profile so an erasure is not based on a stale read
+func (s PrivacyServiceImpl) RequestErasure(ctx context.Context, ifMatch string) (model.PrivacyJob, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PrivacyServiceImpl.RequestErasure")
+	defer span.Finish()
+
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return model.PrivacyJob{}, err
+	}
+	user, err := s.userRepo.GetByID(database.WithReadYourWrites(ctx), userID)
+	if err != nil {
+		return model.PrivacyJob{}, err
+	}
+	if !utils.MatchETag(ifMatch, user.ETag()) {
+		return model.PrivacyJob{}, utils.ErrorConflict
+	}
+
+	return s.createJob(ctx, model.PRIVACY_JOB_ERASURE)
+}
+
+// GetJob status of a job owned by the logged in user
+func (s PrivacyServiceImpl) GetJob(ctx context.Context, jobID string) (model.PrivacyJob, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return model.PrivacyJob{}, err
+	}
+
+	job, err := s.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		return model.PrivacyJob{}, err
+	}
+	if job.UserID != userID {
+		return model.PrivacyJob{}, utils.ErrorNotFound
+	}
+
+	return job.WithDownloadURL(), nil
+}
+
+// OpenExport open the archive of a finished export, the caller must close it
+func (s PrivacyServiceImpl) OpenExport(ctx context.Context, jobID string) (io.ReadCloser, error) {
+	job, err := s.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != model.PRIVACY_JOB_EXPORT || job.Status != model.PRIVACY_JOB_DONE {
+		return nil, utils.ErrorNotFound
+	}
+
+	archive, err := s.jobRepo.GetArchive(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(archive)), nil
+}
+
+// RunPending run the claimed jobs one after the other, a job interrupted by a crash is claimed
+// again once its lease runs out and resumes after its last step
+func (s PrivacyServiceImpl) RunPending(ctx context.Context, limit int) (int, error) {
+	jobs, err := s.jobRepo.ClaimPending(ctx, limit, utils.TimeNow().Add(JOB_LEASE))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, job := range jobs {
+		s.runJob(ctx, job)
+	}
+	return len(jobs), nil
+}
+
+// PurgeFinished remove the expired jobs
+func (s PrivacyServiceImpl) PurgeFinished(ctx context.Context) (int64, error) {
+	return s.jobRepo.DeleteFinishedBefore(ctx, utils.TimeNow().Add(-JOB_RETENTION))
+}
+
+// createJob queue the job of the logged in user, the runner picks it up
+func (s PrivacyServiceImpl) createJob(ctx context.Context, jobType string) (model.PrivacyJob, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return model.PrivacyJob{}, err
+	}
+
+	job, err := s.jobRepo.Create(ctx, model.PrivacyJob{
+		ID:        ulid.GenerateUlidID(),
+		UserID:    userID,
+		Type:      jobType,
+		RequestID: model.RequestIDFromContext(ctx),
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when jobRepo.Create(), err: %v", err)
+		return model.PrivacyJob{}, err
+	}
+
+	return job.WithDownloadURL(), nil
+}
+
+// runJob run one attempt with the app context of the request that queued the job, the outcome is
+// stored even when ctx is cancelled by the runner stopping
+func (s PrivacyServiceImpl) runJob(ctx context.Context, job model.PrivacyJob) {
+	appContext := model.AppContext{
+		Context:          log.With().Str("request_id", job.RequestID).Logger().WithContext(ctx),
+		MandatoryRequest: model.MandatoryRequest{RequestID: job.RequestID},
+		UID:              strconv.FormatInt(job.UserID, 10),
+	}
+	jobCtx, cancel := context.WithTimeout(model.NewAppContext(appContext), JOB_TIMEOUT)
+	defer cancel()
+
+	var archive []byte
+	var err error
+	switch job.Type {
+	case model.PRIVACY_JOB_EXPORT:
+		archive, err = s.runExport(jobCtx, job)
+	case model.PRIVACY_JOB_ERASURE:
+		err = s.runErasure(jobCtx, job)
+	default:
+		err = fmt.Errorf("unknown privacy job type %s", job.Type)
+	}
+
+	ctx = context.WithoutCancel(jobCtx)
+	if err == nil {
+		if err := s.jobRepo.Finish(ctx, job.ID, "", archive); err != nil {
+			log.Ctx(ctx).Error().Msgf("error when jobRepo.Finish(), job: %s, err: %v", job.ID, err)
+		}
+		return
+	}
+
+	if job.Attempts >= MAX_JOB_ATTEMPTS {
+		log.Ctx(ctx).Error().Msgf("error when run privacy job %s %s, user: %d, failed after %d attempts, err: %v", job.Type, job.ID, job.UserID, job.Attempts, err)
+		err = s.jobRepo.Finish(ctx, job.ID, err.Error(), nil)
+	} else {
+		log.Ctx(ctx).Warn().Msgf("error when run privacy job %s %s, user: %d, attempt: %d, err: %v", job.Type, job.ID, job.UserID, job.Attempts, err)
+		err = s.jobRepo.Release(ctx, job.ID, err.Error(), utils.TimeNow().Add(retryBackoff.Wait(job.Attempts)))
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when record privacy job %s attempt, err: %v", job.ID, err)
+	}
+}
+
+// runExport zip profile.json, history.json and the uploaded files, the archive is stored with the
+// job so any instance can serve the download
+func (s PrivacyServiceImpl) runExport(ctx context.Context, job model.PrivacyJob) ([]byte, error) {
+	user, err := s.userRepo.GetByID(database.WithReadYourWrites(ctx), job.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	if err := writeJSON(archive, "profile.json", user.ToPersonalDataExport()); err != nil {
+		return nil, err
+	}
+
+	histories := []model.UserHistory{}
+	for page := 1; ; page++ {
+		list, _, err := s.historyRepo.ListByUserID(ctx, job.UserID, page, historyPageSize)
+		if err != nil {
+			return nil, err
+		}
+		histories = append(histories, list...)
+		if len(list) < historyPageSize {
+			break
+		}
+	}
+	if err := writeJSON(archive, "history.json", histories); err != nil {
+		return nil, err
+	}
+
+	if user.IdentityImage != nil && *user.IdentityImage != "" {
+		if err := s.writeImage(ctx, archive, "files/identity_image"+path.Ext(*user.IdentityImage), *user.IdentityImage); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// runErasure resume after the last step done, every step is idempotent so one interrupted before
+// its step was recorded is safely run again. The identity image goes first so a failure there
+// leaves the user untouched.
+func (s PrivacyServiceImpl) runErasure(ctx context.Context, job model.PrivacyJob) error {
+	ctx = database.WithReadYourWrites(ctx)
+	if job.Step == "" {
+		user, err := s.userRepo.GetByID(ctx, job.UserID)
+		if err != nil {
+			return err
+		}
+		if user.IdentityImage != nil && *user.IdentityImage != "" {
+			if err := s.imageOutbound.Delete(ctx, *user.IdentityImage); err != nil && err != utils.ErrorNotFound {
+				return err
+			}
+		}
+		if err := s.jobRepo.SetStep(ctx, job.ID, model.PRIVACY_STEP_IMAGE_DELETED); err != nil {
+			return err
+		}
+		job.Step = model.PRIVACY_STEP_IMAGE_DELETED
+	}
+
+	if job.Step == model.PRIVACY_STEP_IMAGE_DELETED {
+		// the user and the step are committed together
+		err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+			if err := s.userRepo.Anonymize(ctx, job.UserID); err != nil {
+				return err
+			}
+			return s.jobRepo.SetStep(ctx, job.ID, model.PRIVACY_STEP_USER_ANONYMIZED)
+		})
+		if err != nil {
+			return err
+		}
+		job.Step = model.PRIVACY_STEP_USER_ANONYMIZED
+	}
+
+	// the history lives in the log database, both writes are idempotent and simply run again
+	if err := s.historyRepo.AnonymizeByUserID(ctx, job.UserID); err != nil {
+		return err
+	}
+
+	changes := []model.FieldChange{}
+	for _, column := range model.ErasedUserColumns {
+		changes = append(changes, model.FieldChange{Field: column, Before: model.REDACTED, After: model.REDACTED})
+	}
+	history := model.UserHistory{
+		// the id of the job, a retry does not record the erasure twice
+		ID:        job.ID,
+		UserID:    job.UserID,
+		Action:    model.USER_HISTORY_ERASE,
+		Changes:   changes,
+		CreatedAt: utils.TimeNow(),
+	}
+	if appContext, ok := model.AppContextFromContext(ctx); ok {
+		history.ActorUID = appContext.UID
+		history.RequestID = appContext.RequestID
+	}
+	if err := s.historyRepo.Create(ctx, history); err != nil && err != utils.ErrorDuplicateData {
+		return err
+	}
+
+	return nil
+}
+
+func (s PrivacyServiceImpl) writeImage(ctx context.Context, archive *zip.Writer, name, imagePath string) error {
+	image, err := s.imageOutbound.Download(ctx, imagePath)
+	if err == utils.ErrorNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer image.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, image)
+	return err
+}
+
+func writeJSON(archive *zip.Writer, name string, data interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(data)
+}
+
+func userIDFromContext(ctx context.Context) (int64, error) {
+	appContext, ok := model.AppContextFromContext(ctx)
+	if !ok {
+		return 0, utils.ErrorUnauthorized
+	}
+	id, err := appContext.UserID()
+	if err != nil {
+		return 0, utils.ErrorUnauthorized
+	}
+	return id, nil
+}
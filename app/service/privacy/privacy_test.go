@@ -0,0 +1,149 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository/memory"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeImageOutbound image storage failing the first failDeletes deletes
+type fakeImageOutbound struct {
+	failDeletes int
+	deleted     []string
+}
+
+func (f *fakeImageOutbound) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("image")), nil
+}
+
+func (f *fakeImageOutbound) Delete(ctx context.Context, path string) error {
+	if f.failDeletes > 0 {
+		f.failDeletes--
+		return errors.New("image storage is down")
+	}
+	f.deleted = append(f.deleted, path)
+	return nil
+}
+
+type testService struct {
+	PrivacyServiceImpl
+	userRepo    *memory.UserRepository
+	historyRepo *memory.UserHistoryRepository
+	jobRepo     *memory.PrivacyJobRepository
+	images      *fakeImageOutbound
+}
+
+// newTestService privacy service on the in-memory repositories, a failed job is due again at once
+func newTestService(t *testing.T) testService {
+	retry := retryBackoff
+	retryBackoff = backoff.Backoff{}
+	t.Cleanup(func() { retryBackoff = retry })
+
+	s := testService{
+		userRepo:    memory.NewUserRepository(),
+		historyRepo: memory.NewUserHistoryRepository(),
+		jobRepo:     memory.NewPrivacyJobRepository(),
+		images:      &fakeImageOutbound{},
+	}
+	s.PrivacyServiceImpl = NewService(model.Config{}, s.userRepo, s.historyRepo, s.jobRepo, memory.NewTxManager(s.userRepo, s.jobRepo), s.images).(PrivacyServiceImpl)
+	return s
+}
+
+// loggedInAs create user and return ctx authenticated as them
+func (s testService) loggedInAs(t *testing.T, user model.User) (context.Context, *model.User) {
+	created, err := s.userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+	ctx := model.NewAppContext(model.AppContext{Context: context.Background(), UID: strconv.FormatInt(created.ID, 10)})
+	return ctx, created
+}
+
+func TestErasureResumes(t *testing.T) {
+	s := newTestService(t)
+	ctx, user := s.loggedInAs(t, model.User{Email: "jane.doe@example.com", IsActive: true,
+		IdentityImage: utils.ValueToPtr("identity/jane.png")})
+	s.images.failDeletes = 1
+
+	_, err := s.RequestErasure(ctx, `"stale"`)
+	assert.Equal(t, utils.ErrorConflict, err)
+	job, err := s.RequestErasure(ctx, user.ETag())
+	require.NoError(t, err)
+
+	// the image storage fails, the user is left untouched
+	claimed, err := s.RunPending(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	stored, err := s.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PRIVACY_JOB_PENDING, stored.Status)
+	assert.Empty(t, stored.Step)
+	assert.NotEmpty(t, stored.Error)
+	untouched, err := s.userRepo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, untouched.Email)
+
+	_, err = s.RunPending(context.Background(), 1)
+	require.NoError(t, err)
+	stored, err = s.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PRIVACY_JOB_DONE, stored.Status)
+	assert.Equal(t, model.PRIVACY_STEP_USER_ANONYMIZED, stored.Step)
+	assert.Equal(t, []string{"identity/jane.png"}, s.images.deleted)
+
+	// run again after a crash before the job was finished, the erasure is recorded once
+	require.NoError(t, s.runErasure(ctx, stored))
+	histories, _, err := s.historyRepo.ListByUserID(ctx, user.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, histories, 1)
+	assert.Equal(t, model.USER_HISTORY_ERASE, histories[0].Action)
+	assert.Len(t, s.images.deleted, 1)
+}
+
+func TestExport(t *testing.T) {
+	s := newTestService(t)
+	ctx, _ := s.loggedInAs(t, model.User{Email: "jane.doe@example.com", IsActive: true})
+	otherCtx, _ := s.loggedInAs(t, model.User{Email: "john.doe@example.com", IsActive: true})
+
+	job, err := s.RequestExport(ctx)
+	require.NoError(t, err)
+	again, err := s.RequestExport(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, again.ID)
+
+	_, err = s.OpenExport(ctx, job.ID)
+	assert.Equal(t, utils.ErrorNotFound, err)
+
+	_, err = s.RunPending(context.Background(), 1)
+	require.NoError(t, err)
+	done, err := s.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PRIVACY_JOB_DONE, done.Status)
+	assert.NotEmpty(t, done.DownloadURL)
+
+	_, err = s.GetJob(otherCtx, job.ID)
+	assert.Equal(t, utils.ErrorNotFound, err)
+
+	file, err := s.OpenExport(ctx, job.ID)
+	require.NoError(t, err)
+	defer file.Close()
+	body, err := io.ReadAll(file)
+	require.NoError(t, err)
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	names := []string{}
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	assert.ElementsMatch(t, []string{"profile.json", "history.json"}, names)
+}
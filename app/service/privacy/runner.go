@@ -0,0 +1,88 @@
+package privacy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// RUNNER_POLL_INTERVAL wait between two polls of the privacy jobs when none is due
+	RUNNER_POLL_INTERVAL = 5 * time.Second
+	// RUNNER_BATCH_SIZE jobs claimed by one poll, they run one after the other
+	RUNNER_BATCH_SIZE = 1
+)
+
+// Runner run the queued privacy jobs. A job is claimed with a lease, so many instances can run
+// the runner and a job of a crashed instance is resumed by another one once its lease runs out.
+type Runner struct {
+	service PrivacyService
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewRunner initialize privacy job runner
+func NewRunner(service PrivacyService) *Runner {
+	return &Runner{service: service}
+}
+
+// Start poll the privacy jobs in the background until Stop
+func (r *Runner) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	r.done = make(chan struct{})
+	go r.run(ctx)
+}
+
+// Stop the polling and wait for the job in flight until ctx is done, an interrupted job resumes
+// after its last step once its lease runs out
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+
+	stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) run(ctx context.Context) {
+	defer close(r.done)
+
+	for {
+		claimed, err := r.service.RunPending(ctx, RUNNER_BATCH_SIZE)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Msgf("error when run privacy jobs, err: %v", err)
+		}
+
+		// a full batch means more jobs are waiting
+		if err == nil && claimed >= RUNNER_BATCH_SIZE && ctx.Err() == nil {
+			continue
+		}
+
+		timer := time.NewTimer(RUNNER_POLL_INTERVAL)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
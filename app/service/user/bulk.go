@@ -0,0 +1,457 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/erwinwahyura/go-boilerplate/utils/validator"
+	govalidator "github.com/go-playground/validator/v10"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// MAX_IMPORT_ROWS keep a single import request bounded in time
+	MAX_IMPORT_ROWS = 10000
+
+	maxNDJSONLineSize = 1024 * 1024
+
+	// csvFormulaPrefix escape of the cells a spreadsheet would run as a formula
+	csvFormulaPrefix = "'"
+)
+
+// exportUserRow ndjson row of the export, the empty password shadows the one of the request so
+// the key is left out
+type exportUserRow struct {
+	model.UserRequest
+	Password string `json:"password,omitempty"`
+}
+
+// userRowReader read the next row of a bulk file, io.EOF is returned after the last row
+type userRowReader interface {
+	Next() (model.UserRequest, error)
+}
+
+// rowError the current row is invalid but the next row can still be read
+type rowError struct {
+	err error
+}
+
+func (e rowError) Error() string {
+	return e.err.Error()
+}
+
+// ImportUsers validate and import users row by row, the body is streamed so the file is never fully loaded.
+// Password is never imported, imported users log in through MyValue. is_superuser and is_staff are only
+// imported by a superuser, the rows of a staff import are created without privileges.
+func (s UserServiceImpl) ImportUsers(ctx context.Context, body io.Reader, opts model.ImportUserOptions) (model.ImportUserReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.ImportUsers")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	report := model.ImportUserReport{DryRun: opts.DryRun, Rows: []model.ImportUserResult{}}
+
+	caller, err := s.getLoggedInUser(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	reader, err := newUserRowReader(ctx, body, opts.Format)
+	if err != nil {
+		return report, err
+	}
+
+	validate := validator.GetValidatorController()
+	seen := map[string]int{}
+	for row := 1; ; row++ {
+		req, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		result := model.ImportUserResult{Row: row, Email: req.Email}
+		if row > MAX_IMPORT_ROWS {
+			result.Status = model.IMPORT_STATUS_INVALID
+			result.Errors = []string{fmt.Sprintf("row limit of %d exceeded, the remaining rows are not imported", MAX_IMPORT_ROWS)}
+			report.Add(result)
+			break
+		}
+
+		var rowErr rowError
+		if errors.As(err, &rowErr) {
+			result.Status = model.IMPORT_STATUS_INVALID
+			result.Errors = []string{rowErr.Error()}
+			report.Add(result)
+			continue
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Msgf("error when read import row %d, err: %v", row, err)
+			return report, utils.ErrorBadRequest
+		}
+
+		if err := validate.Struct(req); err != nil {
+			result.Status = model.IMPORT_STATUS_INVALID
+			result.Errors = validationErrors(err)
+			report.Add(result)
+			continue
+		}
+
+		email := strings.ToLower(req.Email)
+		if previous, ok := seen[email]; ok {
+			result.Status = model.IMPORT_STATUS_SKIPPED
+			result.Errors = []string{fmt.Sprintf("duplicate of row %d", previous)}
+			report.Add(result)
+			continue
+		}
+		seen[email] = row
+
+		if !caller.IsSuperUser {
+			req.IsSuperUser = false
+			req.IsStaff = false
+		}
+		result.Status, err = s.importUser(ctx, req, opts, caller.IsSuperUser)
+		if err != nil {
+			log.Ctx(ctx).Error().Msgf("error when import row %d, err: %v", row, err)
+			result.Status = model.IMPORT_STATUS_INVALID
+			result.Errors = []string{err.Error()}
+		}
+		report.Add(result)
+	}
+
+	return report, nil
+}
+
+// importUser create or update a single valid row and return its status, only a privileged caller
+// updates the privileges or a superuser
+func (s UserServiceImpl) importUser(ctx context.Context, req model.UserRequest, opts model.ImportUserOptions, privileged bool) (string, error) {
+	// rows of the same file may depend on each other
+	ctx = database.WithReadYourWrites(ctx)
+	existing, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil && err != utils.ErrorNotFound {
+		return "", err
+	}
+
+	if existing != nil {
+		if !opts.Upsert {
+			return model.IMPORT_STATUS_SKIPPED, nil
+		}
+		if !privileged && existing.IsSuperUser {
+			return "", utils.ErrorForbidden
+		}
+		if req.Username != "" && !strings.EqualFold(req.Username, utils.PtrToValue(existing.Username)) {
+			if err := s.usernameService.Validate(req.Username); err != nil {
+				return "", err
+			}
+		}
+		before := *existing
+		applyImportRow(existing, req, privileged)
+		if req.PhoneNumber != "" {
+			if err := s.normalizePhoneNumber(ctx, existing); err != nil {
+				return "", err
+			}
+		}
+		if opts.DryRun {
+			return model.IMPORT_STATUS_UPDATED, nil
+		}
+		if err := s.userRepo.Update(ctx, existing); err != nil {
+			return "", err
+		}
+		s.recordHistory(ctx, model.USER_HISTORY_UPDATE, before, *existing)
+		return model.IMPORT_STATUS_UPDATED, nil
+	}
+
+	user := req.ToUser()
+	if opts.DryRun {
+		if err := s.normalizePhoneNumbers(ctx, &user); err != nil {
+			return "", err
+		}
+		return model.IMPORT_STATUS_CREATED, nil
+	}
+	if _, err := s.createUser(ctx, user); err != nil {
+		return "", err
+	}
+
+	return model.IMPORT_STATUS_CREATED, nil
+}
+
+// applyImportRow overwrite the importable fields of an existing user
+func applyImportRow(user *model.User, req model.UserRequest, privileged bool) {
+	user.FirstName = utils.ValueToPtr(req.FirstName)
+	user.LastName = utils.ValueToPtr(req.LastName)
+	user.PhoneNumber = utils.ValueToPtr(fieldcrypt.EncryptedString(req.PhoneNumber))
+	if req.Username != "" {
+		user.Username = &req.Username
+	}
+	if privileged {
+		user.IsSuperUser = req.IsSuperUser
+		user.IsStaff = req.IsStaff
+	}
+	user.IsActive = req.IsActive
+	user.IsVerified = req.IsVerified
+	user.Properties = req.Properties
+	user.CorporateAccountID = req.CorporateAccountID
+	user.AuthorID = req.AuthorID
+}
+
+// ExportUsers stream the users matching the filter into w, passwords are never exported and the
+// csv cells are escaped so a spreadsheet does not run them as formulas
+func (s UserServiceImpl) ExportUsers(ctx context.Context, w io.Writer, format string, filter repository.UserRepositoryFilter) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.ExportUsers")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	switch format {
+	case model.BULK_FORMAT_CSV:
+		writer := csv.NewWriter(w)
+		if err = writer.Write(model.UserBulkColumns); err != nil {
+			return err
+		}
+		err = s.userRepo.Iterate(ctx, filter, func(user model.User) error {
+			return writer.Write(userRequestToCSV(user.ToUserRequest()))
+		})
+		writer.Flush()
+		if err != nil {
+			return err
+		}
+		return writer.Error()
+
+	case model.BULK_FORMAT_NDJSON:
+		buffered := bufio.NewWriter(w)
+		encoder := json.NewEncoder(buffered)
+		err = s.userRepo.Iterate(ctx, filter, func(user model.User) error {
+			return encoder.Encode(exportUserRow{UserRequest: user.ToUserRequest()})
+		})
+		if err != nil {
+			return err
+		}
+		return buffered.Flush()
+
+	default:
+		return utils.ErrorBadRequest
+	}
+}
+
+func newUserRowReader(ctx context.Context, body io.Reader, format string) (userRowReader, error) {
+	switch format {
+	case model.BULK_FORMAT_CSV:
+		reader := csv.NewReader(body)
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err != nil {
+			log.Ctx(ctx).Error().Msgf("error when read csv header, err: %v", err)
+			return nil, utils.ErrorBadRequest
+		}
+		columns := make([]string, len(header))
+		for i, column := range header {
+			columns[i] = strings.ToLower(strings.TrimSpace(column))
+		}
+		if !utils.EqualAny("email", columns...) {
+			return nil, utils.ErrorBadRequest
+		}
+		return &csvUserRowReader{reader: reader, columns: columns}, nil
+
+	case model.BULK_FORMAT_NDJSON:
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+		return &ndjsonUserRowReader{scanner: scanner}, nil
+
+	default:
+		return nil, utils.ErrorBadRequest
+	}
+}
+
+type csvUserRowReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func (c *csvUserRowReader) Next() (model.UserRequest, error) {
+	var req model.UserRequest
+	record, err := c.reader.Read()
+	if err == io.EOF {
+		return req, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return req, rowError{err: parseErr}
+		}
+		return req, err
+	}
+
+	var errs []string
+	for i, column := range c.columns {
+		if err := setUserRequestField(&req, column, strings.TrimSpace(record[i])); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return req, rowError{err: errors.New(strings.Join(errs, "; "))}
+	}
+
+	return req, nil
+}
+
+type ndjsonUserRowReader struct {
+	scanner *bufio.Scanner
+}
+
+func (n *ndjsonUserRowReader) Next() (model.UserRequest, error) {
+	var req model.UserRequest
+	for n.scanner.Scan() {
+		line := strings.TrimSpace(n.scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			return req, rowError{err: err}
+		}
+		req.Password = ""
+		return req, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return req, err
+	}
+
+	return req, io.EOF
+}
+
+// setUserRequestField set a csv column into the request, unknown columns are ignored
+func setUserRequestField(req *model.UserRequest, column, value string) error {
+	var err error
+	switch column {
+	case "email":
+		req.Email = csvUnescape(value)
+	case "first_name":
+		req.FirstName = csvUnescape(value)
+	case "last_name":
+		req.LastName = csvUnescape(value)
+	case "phone_number":
+		req.PhoneNumber = csvUnescape(value)
+	case "username":
+		req.Username = csvUnescape(value)
+	case "properties":
+		req.Properties = csvUnescape(value)
+	case "is_superuser":
+		req.IsSuperUser, err = parseBool(value)
+	case "is_staff":
+		req.IsStaff, err = parseBool(value)
+	case "is_active":
+		req.IsActive, err = parseBool(value)
+	case "verified":
+		req.IsVerified, err = parseBool(value)
+	case "corporate_account_id":
+		req.CorporateAccountID, err = parseInt64(value)
+	case "author_id":
+		req.AuthorID, err = parseInt64(value)
+	case "last_login":
+		if value != "" {
+			req.LastLogin, err = time.Parse(time.RFC3339, value)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: invalid value %q", column, value)
+	}
+
+	return nil
+}
+
+// userRequestToCSV map request into a record following model.UserBulkColumns
+func userRequestToCSV(req model.UserRequest) []string {
+	lastLogin := ""
+	if !req.LastLogin.IsZero() {
+		lastLogin = req.LastLogin.Format(time.RFC3339)
+	}
+
+	return []string{
+		csvEscape(req.Email),
+		csvEscape(req.FirstName),
+		csvEscape(req.LastName),
+		csvEscape(req.PhoneNumber),
+		csvEscape(req.Username),
+		lastLogin,
+		strconv.FormatBool(req.IsSuperUser),
+		strconv.FormatBool(req.IsStaff),
+		strconv.FormatBool(req.IsActive),
+		strconv.FormatBool(req.IsVerified),
+		csvEscape(req.Properties),
+		strconv.FormatInt(req.CorporateAccountID, 10),
+		strconv.FormatInt(req.AuthorID, 10),
+	}
+}
+
+// csvEscape prefix the cell a spreadsheet would run as a formula, e.g. =HYPERLINK(...) or the + of a
+// phone number
+func csvEscape(value string) string {
+	if isCSVFormula(value) {
+		return csvFormulaPrefix + value
+	}
+	return value
+}
+
+// csvUnescape remove the prefix of csvEscape so an export can be imported back
+func csvUnescape(value string) string {
+	if unescaped := strings.TrimPrefix(value, csvFormulaPrefix); unescaped != value && isCSVFormula(unescaped) {
+		return unescaped
+	}
+	return value
+}
+
+func isCSVFormula(value string) bool {
+	return value != "" && strings.ContainsRune("=+-@", rune(value[0]))
+}
+
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func parseInt64(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// validationErrors flatten the validator errors into one message per field
+func validationErrors(err error) []string {
+	var fieldErrs govalidator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return []string{err.Error()}
+	}
+
+	errs := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		errs = append(errs, fmt.Sprintf("%s: failed on %s", fieldErr.Field(), fieldErr.Tag()))
+	}
+	return errs
+}
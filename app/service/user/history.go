@@ -0,0 +1,66 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+// GetUserHistory list the changes of a user, newest first
+func (s UserServiceImpl) GetUserHistory(ctx context.Context, userID int64, page, size int) ([]model.UserHistory, model.PaginationMeta, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.GetUserHistory")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = model.DEFAULT_PAGINATION_SIZE
+	}
+	meta := model.PaginationMeta{Page: page, Size: size}
+
+	histories, total, err := s.historyRepo.ListByUserID(ctx, userID, page, size)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when historyRepo.ListByUserID(), err: %v", err)
+		return nil, meta, err
+	}
+	meta.Total = total
+
+	return histories, meta, nil
+}
+
+// recordHistory store the diff between before and after with the actor from the app context.
+// The history lives in the log database, a failure is logged and never fails the change itself.
+func (s UserServiceImpl) recordHistory(ctx context.Context, action string, before, after model.User) {
+	changes := model.DiffUser(before, after)
+	if len(changes) == 0 {
+		return
+	}
+
+	history := model.UserHistory{
+		UserID:    after.ID,
+		Action:    action,
+		Changes:   changes,
+		CreatedAt: utils.TimeNow(),
+	}
+	if appContext, ok := model.AppContextFromContext(ctx); ok {
+		history.ActorUID = appContext.UID
+		history.RequestID = appContext.RequestID
+	}
+
+	if err := s.historyRepo.Create(ctx, history); err != nil {
+		log.Ctx(ctx).Error().Msgf("error when historyRepo.Create(), user: %d, err: %v", after.ID, err)
+	}
+}
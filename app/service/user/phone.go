@@ -0,0 +1,70 @@
+package user
+
+import (
+	"context"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/erwinwahyura/go-boilerplate/utils/phone"
+)
+
+// normalizePhoneNumbers normalize both phone numbers of a new user
+func (s UserServiceImpl) normalizePhoneNumbers(ctx context.Context, user *model.User) error {
+	if err := s.normalizePhoneNumber(ctx, user); err != nil {
+		return err
+	}
+	return normalizeHomePhone(user)
+}
+
+// normalizePhoneNumber store the mobile phone number in E.164 so the blind index matches every
+// input format, it must not belong to another user. An update only calls it when the request sets
+// the number, a legacy number that does not parse must not block editing the other fields.
+func (s UserServiceImpl) normalizePhoneNumber(ctx context.Context, user *model.User) error {
+	if user.PhoneNumber == nil {
+		return nil
+	}
+
+	e164, err := phone.Normalize(user.PhoneNumber.String())
+	if err != nil {
+		return model.INVALID_PHONENUMBER
+	}
+	user.PhoneNumber = utils.ValueToPtr(fieldcrypt.EncryptedString(e164))
+
+	owner, err := s.userRepo.GetByPhoneNumber(ctx, e164)
+	if err != nil && err != utils.ErrorNotFound {
+		return err
+	}
+	if owner != nil && owner.ID != user.ID {
+		return utils.ErrorDuplicatePhoneNumber
+	}
+	return nil
+}
+
+// normalizeHomePhone store the home phone number in E.164, like normalizePhoneNumber it is only
+// called by an update setting the number
+func normalizeHomePhone(user *model.User) error {
+	if user.HomePhone == nil {
+		return nil
+	}
+
+	e164, err := phone.Normalize(user.HomePhone.String())
+	if err != nil {
+		return model.INVALID_PHONENUMBER
+	}
+	user.HomePhone = utils.ValueToPtr(fieldcrypt.EncryptedString(e164))
+	return nil
+}
+
+// normalizeUpdatedPhoneNumbers normalize the phone numbers set by the update request
+func (s UserServiceImpl) normalizeUpdatedPhoneNumbers(ctx context.Context, user *model.User, req model.UpdateProfileRequest) error {
+	if req.PhoneNumber != nil {
+		if err := s.normalizePhoneNumber(ctx, user); err != nil {
+			return err
+		}
+	}
+	if req.HomePhone != nil {
+		return normalizeHomePhone(user)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/validator"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+// GetProfile get profile of the logged in user
+func (s UserServiceImpl) GetProfile(ctx context.Context) (model.ProfileResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.GetProfile")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	// the ETag is the If-Match of the next update, a lagging slave would return a stale one
+	user, err := s.getLoggedInUser(database.WithReadYourWrites(ctx))
+	if err != nil {
+		return model.ProfileResponse{}, err
+	}
+
+	return s.mapProfileResponse(ctx, *user), nil
+}
+
+// UpdateProfile self-service edit of the logged in user's profile, ifMatch must match the ETag of
+// the profile otherwise it was changed since it was read and ErrorConflict is returned
+func (s UserServiceImpl) UpdateProfile(ctx context.Context, req model.UpdateProfileRequest, ifMatch string) (model.ProfileResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.UpdateProfile")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	if err = validator.GetValidatorController().Struct(req); err != nil {
+		log.Ctx(ctx).Error().Msgf("error when validate update profile request, err: %v", err)
+		return model.ProfileResponse{}, utils.ErrorBadRequest
+	}
+
+	// read-modify-write, the user is read from the transaction instead of a lagging slave
+	var before model.User
+	var user *model.User
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.getLoggedInUser(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !utils.MatchETag(ifMatch, user.ETag()) {
+			return utils.ErrorConflict
+		}
+
+		before = *user
+		req.ApplyTo(user)
+		if err := s.normalizeUpdatedPhoneNumbers(ctx, user, req); err != nil {
+			return err
+		}
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			log.Ctx(ctx).Error().Msgf("error when userRepo.Update(), err: %v", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return model.ProfileResponse{}, err
+	}
+	s.recordHistory(ctx, model.USER_HISTORY_UPDATE, before, *user)
+
+	return s.mapProfileResponse(ctx, *user), nil
+}
+
+// getLoggedInUser get user from the uid of app context
+func (s UserServiceImpl) getLoggedInUser(ctx context.Context) (*model.User, error) {
+	appContext, ok := model.AppContextFromContext(ctx)
+	if !ok {
+		return nil, utils.ErrorUnauthorized
+	}
+	id, err := appContext.UserID()
+	if err != nil {
+		return nil, utils.ErrorUnauthorized
+	}
+
+	return s.userRepo.GetByID(ctx, id)
+}
+
+// mapProfileResponse the points balance is best effort, profile is still returned when MyValue is down
+func (s UserServiceImpl) mapProfileResponse(ctx context.Context, user model.User) model.ProfileResponse {
+	var point *int
+	balance, err := s.myValueOutbound.GetPointBalance(ctx, user.Email)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when myValueOutbound.GetPointBalance(), err: %v", err)
+	} else {
+		point = &balance
+	}
+
+	fullname := strings.TrimSpace(utils.PtrToValue(user.FirstName) + " " + utils.PtrToValue(user.LastName))
+	return model.ProfileResponse{
+		Email:        user.Email,
+		Fullname:     fullname,
+		MyValuePoint: point,
+		// there is no avatar column yet
+		Avatar: "",
+		ETag:   user.ETag(),
+	}
+}
+
+// GetUser user by id for staff
+func (s UserServiceImpl) GetUser(ctx context.Context, id int64) (model.UserResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.GetUser")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	// read from the master like GetProfile, the ETag must be the current one
+	user, err := s.userRepo.GetByID(database.WithReadYourWrites(ctx), id)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when userRepo.GetByID(), id: %d, err: %v", id, err)
+		return model.UserResponse{}, err
+	}
+
+	return user.ToUserResponse(), nil
+}
+
+// UpdateUser staff edit of a user, like UpdateProfile ifMatch must match the ETag the staff read
+// so two staff editing the same user do not overwrite each other. Only a superuser edits a
+// superuser or changes is_staff, otherwise ErrorForbidden is returned.
+func (s UserServiceImpl) UpdateUser(ctx context.Context, id int64, req model.UpdateUserRequest, ifMatch string) (model.UserResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.UpdateUser")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	if err = validator.GetValidatorController().Struct(req); err != nil {
+		log.Ctx(ctx).Error().Msgf("error when validate update user request, err: %v", err)
+		return model.UserResponse{}, utils.ErrorBadRequest
+	}
+
+	var before model.User
+	var user *model.User
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		caller, err := s.getLoggedInUser(ctx)
+		if err != nil {
+			return err
+		}
+		user, err = s.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !caller.IsSuperUser && (user.IsSuperUser || req.IsStaff != nil) {
+			return utils.ErrorForbidden
+		}
+		if !utils.MatchETag(ifMatch, user.ETag()) {
+			return utils.ErrorConflict
+		}
+
+		before = *user
+		req.ApplyTo(user)
+		if err := s.normalizeUpdatedPhoneNumbers(ctx, user, req.UpdateProfileRequest); err != nil {
+			return err
+		}
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			log.Ctx(ctx).Error().Msgf("error when userRepo.Update(), id: %d, err: %v", id, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return model.UserResponse{}, err
+	}
+	s.recordHistory(ctx, model.USER_HISTORY_UPDATE, before, *user)
+
+	return user.ToUserResponse(), nil
+}
@@ -0,0 +1,138 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ROTATE_BATCH_SIZE users rewrapped per transaction
+	ROTATE_BATCH_SIZE = 500
+	// ROTATE_BATCH_PAUSE wait between two batches so the rotation does not starve the requests
+	ROTATE_BATCH_PAUSE = 100 * time.Millisecond
+)
+
+// keyRotation the rotation running in the background of this instance. Every batch is committed
+// on its own and a rewrap is idempotent, an interrupted rotation is simply started again.
+type keyRotation struct {
+	mu     sync.Mutex
+	state  model.EncryptionKeyRotation
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// RotateEncryptionKeys start rewrapping the PII columns with the current master key in the
+// background, the running rotation is returned instead of starting another one
+func (s UserServiceImpl) RotateEncryptionKeys(ctx context.Context) (model.EncryptionKeyRotation, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.RotateEncryptionKeys")
+	defer span.Finish()
+
+	if fieldcrypt.Default() == nil {
+		return model.EncryptionKeyRotation{}, fieldcrypt.ErrKeyringNotConfigured
+	}
+
+	r := s.keyRotation
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Status == model.KEY_ROTATION_RUNNING {
+		return r.state, nil
+	}
+
+	// keep the logger of the request but not its cancellation
+	rotationCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	startedAt := utils.TimeNow()
+	r.state = model.EncryptionKeyRotation{Status: model.KEY_ROTATION_RUNNING, StartedAt: &startedAt}
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go s.rotateEncryptionKeys(rotationCtx, r.done)
+
+	return r.state, nil
+}
+
+// GetEncryptionKeyRotation progress of the last rotation started on this instance
+func (s UserServiceImpl) GetEncryptionKeyRotation(ctx context.Context) model.EncryptionKeyRotation {
+	r := s.keyRotation
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Status == "" {
+		return model.EncryptionKeyRotation{Status: model.KEY_ROTATION_IDLE}
+	}
+	return r.state
+}
+
+// StopEncryptionKeyRotation cancel the running rotation and wait for its batch until ctx is done
+func (s UserServiceImpl) StopEncryptionKeyRotation(ctx context.Context) error {
+	r := s.keyRotation
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s UserServiceImpl) rotateEncryptionKeys(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	var afterID int64
+	for {
+		var rotated int
+		var lastID int64
+		err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+			batchRotated, batchLastID, err := s.userRepo.RotateEncryptionKeys(ctx, afterID, ROTATE_BATCH_SIZE)
+			rotated, lastID = batchRotated, batchLastID
+			return err
+		})
+		if err != nil || lastID == 0 {
+			s.finishKeyRotation(ctx, err)
+			return
+		}
+
+		afterID = lastID
+		s.keyRotation.mu.Lock()
+		s.keyRotation.state.Rotated += rotated
+		s.keyRotation.mu.Unlock()
+
+		timer := time.NewTimer(ROTATE_BATCH_PAUSE)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.finishKeyRotation(ctx, ctx.Err())
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s UserServiceImpl) finishKeyRotation(ctx context.Context, err error) {
+	r := s.keyRotation
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finishedAt := utils.TimeNow()
+	r.state.FinishedAt = &finishedAt
+	r.state.Status = model.KEY_ROTATION_DONE
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error when userRepo.RotateEncryptionKeys(), rotated: %d, err: %v", r.state.Rotated, err)
+		r.state.Status = model.KEY_ROTATION_FAILED
+		r.state.Error = err.Error()
+	}
+	r.cancel()
+	r.cancel = nil
+	r.done = nil
+}
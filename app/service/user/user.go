@@ -2,20 +2,39 @@ package user
 
 import (
 	"context"
-	"fmt"
+	"io"
+	"strconv"
 	"time"
 
 	"github.com/erwinwahyura/go-boilerplate/app/database"
 	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/outbound"
 
 	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/app/service/username"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/validator"
 	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
 )
 
+// MAX_CREATE_ATTEMPTS attempts to insert a user with a generated username
+const MAX_CREATE_ATTEMPTS = 3
+
 type (
 	// UserService service
 	UserService interface {
-		CreateUser(ctx context.Context, userReq model.UserRequest) (int64, error)
+		CreateUser(ctx context.Context, userReq model.RegisterUserRequest) (int64, error)
+		GetProfile(ctx context.Context) (model.ProfileResponse, error)
+		UpdateProfile(ctx context.Context, req model.UpdateProfileRequest, ifMatch string) (model.ProfileResponse, error)
+		GetUser(ctx context.Context, id int64) (model.UserResponse, error)
+		UpdateUser(ctx context.Context, id int64, req model.UpdateUserRequest, ifMatch string) (model.UserResponse, error)
+		ImportUsers(ctx context.Context, body io.Reader, opts model.ImportUserOptions) (model.ImportUserReport, error)
+		ExportUsers(ctx context.Context, w io.Writer, format string, filter repository.UserRepositoryFilter) error
+		RotateEncryptionKeys(ctx context.Context) (model.EncryptionKeyRotation, error)
+		GetEncryptionKeyRotation(ctx context.Context) model.EncryptionKeyRotation
+		StopEncryptionKeyRotation(ctx context.Context) error
+		GetUserHistory(ctx context.Context, userID int64, page, size int) ([]model.UserHistory, model.PaginationMeta, error)
 	}
 
 	// UserServiceImpl implementation
@@ -23,6 +42,12 @@ type (
 		config          model.Config
 		mongoCollection database.MongoCollection
 		userRepo        repository.UserRepository
+		historyRepo     repository.UserHistoryRepository
+		outboxRepo      repository.OutboxRepository
+		myValueOutbound outbound.MyValueOutbound
+		usernameService username.UsernameService
+		txManager       database.TxManager
+		keyRotation     *keyRotation
 	}
 )
 
@@ -31,17 +56,28 @@ func NewService(
 	config model.Config,
 	mongoCollection database.MongoCollection,
 	userRepository repository.UserRepository,
+	historyRepository repository.UserHistoryRepository,
+	outboxRepository repository.OutboxRepository,
+	myValueOutbound outbound.MyValueOutbound,
+	usernameService username.UsernameService,
+	txManager database.TxManager,
 ) UserService {
 	return UserServiceImpl{
 		config:          config,
 		mongoCollection: mongoCollection,
 		userRepo:        userRepository,
+		historyRepo:     historyRepository,
+		outboxRepo:      outboxRepository,
+		myValueOutbound: myValueOutbound,
+		usernameService: usernameService,
+		txManager:       txManager,
+		keyRotation:     &keyRotation{},
 	}
 }
 
-// Create user
-func (s UserServiceImpl) CreateUser(ctx context.Context, userReq model.UserRequest) (int64, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.CreateUser")
+// Create user from the self-registration, the request cannot grant privileges
+func (s UserServiceImpl) CreateUser(ctx context.Context, userReq model.RegisterUserRequest) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserServiceImpl.CreateUser")
 	defer span.Finish()
 
 	var err error
@@ -53,22 +89,92 @@ func (s UserServiceImpl) CreateUser(ctx context.Context, userReq model.UserReque
 	}(time.Now(), err)
 
 	var response int64
-	// bussiness logic
-	// user, err := s.HandleCreateUser(userReq)
-	// if err != nil {
-	// 	return 0, err
-	// }
+	if err = validator.GetValidatorController().Struct(userReq); err != nil {
+		log.Ctx(ctx).Error().Msgf("error when validate user request, err: %v", err)
+		return 0, utils.ErrorBadRequest
+	}
 
 	// call save user repository
-	res, err := s.userRepo.Create(ctx, "user admin")
+	res, err := s.createUser(ctx, userReq.ToUser())
 	if err != nil {
-		fmt.Println("error on creating user", err)
+		log.Ctx(ctx).Error().Msgf("error when createUser(), err: %v", err)
 		return 0, err
 	}
 
 	if res != nil {
-		response = res.AuthorID
+		response = res.ID
 	}
 
 	return response, nil
 }
+
+// createUser insert user in a transaction, the uniqueness checks read from the transaction so they
+// see the users created just before. user.created is written to the outbox in the same transaction.
+func (s UserServiceImpl) createUser(ctx context.Context, user model.User) (*model.User, error) {
+	var res *model.User
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = s.insertUser(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		event, err := model.NewOutboxEvent(model.EVENT_USER_CREATED, model.AGGREGATE_USER, strconv.FormatInt(res.ID, 10), model.UserCreatedEvent{
+			ID:         res.ID,
+			IsGuest:    res.IsGuest,
+			DateJoined: res.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = s.outboxRepo.Insert(ctx, event)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordHistory(ctx, model.USER_HISTORY_CREATE, model.User{}, *res)
+	return res, nil
+}
+
+// insertUser when the username is empty one is generated from the email. A generated username that
+// is taken by a concurrent registration is generated again instead of failing, every attempt runs
+// in its own savepoint so the failed insert does not abort the transaction.
+func (s UserServiceImpl) insertUser(ctx context.Context, user model.User) (*model.User, error) {
+	if err := s.normalizePhoneNumbers(ctx, &user); err != nil {
+		return nil, err
+	}
+
+	if user.Username != nil {
+		if err := s.usernameService.Validate(*user.Username); err != nil {
+			return nil, err
+		}
+		res, err := s.userRepo.Create(ctx, user)
+		if err == utils.ErrorDuplicateUsername {
+			return nil, utils.ErrorDuplicateData
+		}
+		return res, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		username, err := s.usernameService.Generate(ctx, user.Email)
+		if err != nil {
+			return nil, err
+		}
+		user.Username = &username
+
+		var res *model.User
+		err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+			// own err, the outer one is the result of the attempt
+			var err error
+			res, err = s.userRepo.Create(ctx, user)
+			return err
+		})
+		if err == utils.ErrorDuplicateUsername && attempt < MAX_CREATE_ATTEMPTS {
+			log.Ctx(ctx).Warn().Msgf("generated username %s is taken, retrying, attempt: %d", username, attempt)
+			continue
+		}
+		return res, err
+	}
+}
@@ -0,0 +1,237 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/app/repository/memory"
+	"github.com/erwinwahyura/go-boilerplate/app/repository/repositorytest"
+	"github.com/erwinwahyura/go-boilerplate/app/service/username"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testService struct {
+	UserService
+	userRepo    *memory.UserRepository
+	historyRepo *memory.UserHistoryRepository
+	outboxRepo  *memory.OutboxRepository
+}
+
+// fakeMyValueOutbound MyValue without points
+type fakeMyValueOutbound struct{}
+
+func (fakeMyValueOutbound) GetPointBalance(ctx context.Context, email string) (int, error) {
+	return 0, nil
+}
+
+// newTestService user service on the in-memory repositories
+func newTestService() testService {
+	userRepo := memory.NewUserRepository()
+	historyRepo := memory.NewUserHistoryRepository()
+	outboxRepo := memory.NewOutboxRepository()
+	service := NewService(model.Config{}, database.MongoCollection{}, userRepo, historyRepo, outboxRepo, fakeMyValueOutbound{},
+		username.NewService(userRepo), memory.NewTxManager(userRepo, outboxRepo))
+
+	return testService{UserService: service, userRepo: userRepo, historyRepo: historyRepo, outboxRepo: outboxRepo}
+}
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService()
+
+	id, err := s.CreateUser(ctx, model.RegisterUserRequest{Email: "jane.doe@example.com"})
+	require.NoError(t, err)
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	require.NoError(t, err)
+	assert.NotEmpty(t, utils.PtrToValue(user.Username))
+
+	events, err := s.outboxRepo.ClaimPending(ctx, 10, utils.TimeNow().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, model.EVENT_USER_CREATED, events[0].EventType)
+	assert.NotContains(t, events[0].Payload.String(), "jane.doe")
+
+	histories, _, err := s.historyRepo.ListByUserID(ctx, id, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, histories, 1)
+	assert.Equal(t, model.USER_HISTORY_CREATE, histories[0].Action)
+
+	_, err = s.CreateUser(ctx, model.RegisterUserRequest{Email: "JANE.DOE@example.com"})
+	assert.Equal(t, utils.ErrorDuplicateData, err)
+}
+
+func TestCreateUserWithoutPrivileges(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService()
+
+	var req model.RegisterUserRequest
+	body := `{"email":"jane.doe@example.com","is_superuser":true,"is_staff":true,"is_active":false,"verified":true}`
+	require.NoError(t, json.Unmarshal([]byte(body), &req))
+	id, err := s.CreateUser(ctx, req)
+	require.NoError(t, err)
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, user.IsSuperUser)
+	assert.False(t, user.IsStaff)
+	assert.False(t, user.IsVerified)
+	assert.True(t, user.IsActive)
+}
+
+// loggedInAs create user and return ctx authenticated as them
+func (s testService) loggedInAs(t *testing.T, ctx context.Context, user model.User) context.Context {
+	created, err := s.userRepo.Create(ctx, user)
+	require.NoError(t, err)
+	return model.NewAppContext(model.AppContext{Context: ctx, UID: strconv.FormatInt(created.ID, 10)})
+}
+
+func TestUpdateUserIfMatch(t *testing.T) {
+	s := newTestService()
+	ctx := s.loggedInAs(t, context.Background(), model.User{Email: "root@example.com", IsSuperUser: true, IsActive: true})
+
+	id, err := s.CreateUser(ctx, model.RegisterUserRequest{Email: "jane.doe@example.com"})
+	require.NoError(t, err)
+	read, err := s.GetUser(ctx, id)
+	require.NoError(t, err)
+
+	req := model.UpdateUserRequest{IsStaff: utils.ValueToPtr(true)}
+	updated, err := s.UpdateUser(ctx, id, req, read.ETag)
+	require.NoError(t, err)
+	assert.True(t, updated.IsStaff)
+	assert.NotEqual(t, read.ETag, updated.ETag)
+
+	// the second staff still holds the ETag read before the update
+	_, err = s.UpdateUser(ctx, id, model.UpdateUserRequest{IsStaff: utils.ValueToPtr(false)}, read.ETag)
+	assert.Equal(t, utils.ErrorConflict, err)
+
+	// weak validators never match If-Match
+	_, err = s.UpdateUser(ctx, id, model.UpdateUserRequest{IsStaff: utils.ValueToPtr(false)}, "W/"+updated.ETag)
+	assert.Equal(t, utils.ErrorConflict, err)
+
+	_, err = s.UpdateUser(ctx, id+1, req, "*")
+	assert.Equal(t, utils.ErrorNotFound, err)
+}
+
+func TestUpdateUserByStaff(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	root, err := s.userRepo.Create(ctx, model.User{Email: "root@example.com", IsSuperUser: true, IsActive: true})
+	require.NoError(t, err)
+	ctx = s.loggedInAs(t, ctx, model.User{Email: "staff@example.com", IsStaff: true, IsActive: true})
+
+	id, err := s.CreateUser(ctx, model.RegisterUserRequest{Email: "jane.doe@example.com"})
+	require.NoError(t, err)
+
+	updated, err := s.UpdateUser(ctx, id, model.UpdateUserRequest{IsVerified: utils.ValueToPtr(true)}, "*")
+	require.NoError(t, err)
+	assert.True(t, updated.IsVerified)
+
+	_, err = s.UpdateUser(ctx, id, model.UpdateUserRequest{IsStaff: utils.ValueToPtr(true)}, "*")
+	assert.Equal(t, utils.ErrorForbidden, err)
+
+	_, err = s.UpdateUser(ctx, root.ID, model.UpdateUserRequest{IsActive: utils.ValueToPtr(false)}, "*")
+	assert.Equal(t, utils.ErrorForbidden, err)
+}
+
+func TestUpdateProfilePhoneNumber(t *testing.T) {
+	repositorytest.SetupKeyring(t)
+	s := newTestService()
+	ctx := s.loggedInAs(t, context.Background(), model.User{Email: "jane.doe@example.com", IsActive: true,
+		PhoneNumber: utils.ValueToPtr(fieldcrypt.EncryptedString("12345"))})
+
+	// the legacy number is only checked when the request sets it
+	_, err := s.UpdateProfile(ctx, model.UpdateProfileRequest{FirstName: utils.ValueToPtr("Jane")}, "*")
+	require.NoError(t, err)
+
+	_, err = s.UpdateProfile(ctx, model.UpdateProfileRequest{PhoneNumber: utils.ValueToPtr("12345")}, "*")
+	assert.Equal(t, model.INVALID_PHONENUMBER, err)
+
+	_, err = s.UpdateProfile(ctx, model.UpdateProfileRequest{PhoneNumber: utils.ValueToPtr("0812-3456-7890")}, "*")
+	require.NoError(t, err)
+	appContext, _ := model.AppContextFromContext(ctx)
+	id, _ := appContext.UserID()
+	user, err := s.userRepo.GetByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "+6281234567890", user.PhoneNumber.String())
+
+	otherCtx := s.loggedInAs(t, context.Background(), model.User{Email: "john.doe@example.com", IsActive: true})
+	_, err = s.UpdateProfile(otherCtx, model.UpdateProfileRequest{PhoneNumber: utils.ValueToPtr("+62 812 3456 7890")}, "*")
+	assert.Equal(t, utils.ErrorDuplicatePhoneNumber, err)
+}
+
+func TestRotateEncryptionKeys(t *testing.T) {
+	repositorytest.SetupKeyring(t)
+	s := newTestService()
+	ctx := context.Background()
+	assert.Equal(t, model.KEY_ROTATION_IDLE, s.GetEncryptionKeyRotation(ctx).Status)
+
+	started, err := s.RotateEncryptionKeys(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, started.StartedAt)
+
+	assert.Eventually(t, func() bool {
+		return s.GetEncryptionKeyRotation(ctx).Status == model.KEY_ROTATION_DONE
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, s.StopEncryptionKeyRotation(ctx))
+}
+
+func TestImportUsersByStaff(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	root, err := s.userRepo.Create(ctx, model.User{Email: "root@example.com", IsSuperUser: true, IsActive: true})
+	require.NoError(t, err)
+	ctx = s.loggedInAs(t, ctx, model.User{Email: "staff@example.com", IsStaff: true, IsActive: true})
+
+	body := "email,first_name,is_superuser,is_staff,is_active\n" +
+		"jane.doe@example.com,Jane,true,true,true\n" +
+		"root@example.com,Root,true,true,true\n"
+	report, err := s.ImportUsers(ctx, strings.NewReader(body), model.ImportUserOptions{Format: model.BULK_FORMAT_CSV, Upsert: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Invalid)
+	assert.False(t, report.Truncated)
+
+	jane, err := s.userRepo.GetByEmail(ctx, "jane.doe@example.com")
+	require.NoError(t, err)
+	assert.False(t, jane.IsSuperUser)
+	assert.False(t, jane.IsStaff)
+	untouched, err := s.userRepo.GetByID(ctx, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, root.ETag(), untouched.ETag())
+}
+
+func TestExportUsers(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	_, err := s.userRepo.Create(ctx, model.User{Email: "jane.doe@example.com", FirstName: utils.ValueToPtr("=HYPERLINK(\"http://evil\")"),
+		Password: utils.ValueToPtr("secret"), IsActive: true})
+	require.NoError(t, err)
+
+	var ndjson bytes.Buffer
+	require.NoError(t, s.ExportUsers(ctx, &ndjson, model.BULK_FORMAT_NDJSON, repository.UserRepositoryFilter{}))
+	assert.NotContains(t, ndjson.String(), "password")
+
+	var csv bytes.Buffer
+	require.NoError(t, s.ExportUsers(ctx, &csv, model.BULK_FORMAT_CSV, repository.UserRepositoryFilter{}))
+	assert.Contains(t, csv.String(), `'=HYPERLINK(""http://evil"")`)
+
+	// the export imports back unescaped
+	ctx = s.loggedInAs(t, ctx, model.User{Email: "root@example.com", IsSuperUser: true, IsActive: true})
+	_, err = s.ImportUsers(ctx, &csv, model.ImportUserOptions{Format: model.BULK_FORMAT_CSV, Upsert: true})
+	require.NoError(t, err)
+	jane, err := s.userRepo.GetByEmail(ctx, "jane.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, `=HYPERLINK("http://evil")`, utils.PtrToValue(jane.FirstName))
+}
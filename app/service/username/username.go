@@ -0,0 +1,175 @@
+package username
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/repository"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// MAX_ATTEMPTS suffix digits grow with every attempt, 1 digit on the 2nd attempt up to 9 digits
+	MAX_ATTEMPTS = 10
+	// MAX_BASE_LENGTH leave room for the suffix within the username column
+	MAX_BASE_LENGTH = 30
+
+	defaultBase = "user"
+)
+
+// reservedUsernames can never be registered, they collide with routes or look official
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"api":           true,
+	"auth":          true,
+	"help":          true,
+	"login":         true,
+	"logout":        true,
+	"me":            true,
+	"myvalue":       true,
+	"null":          true,
+	"register":      true,
+	"root":          true,
+	"security":      true,
+	"staff":         true,
+	"static":        true,
+	"superuser":     true,
+	"support":       true,
+	"swagger":       true,
+	"system":        true,
+	"undefined":     true,
+	"user":          true,
+	"users":         true,
+	"www":           true,
+}
+
+type (
+	// UsernameService generate and validate usernames
+	UsernameService interface {
+		Generate(ctx context.Context, email string) (string, error)
+		Validate(username string) error
+	}
+
+	// UsernameServiceImpl implementation
+	UsernameServiceImpl struct {
+		userRepo repository.UserRepository
+	}
+)
+
+// NewService initialize username service
+func NewService(userRepository repository.UserRepository) UsernameService {
+	return UsernameServiceImpl{
+		userRepo: userRepository,
+	}
+}
+
+// Generate a username from the email's local part that is not taken yet. The check is not atomic,
+// the caller must still retry when the insert hits utils.ErrorDuplicateUsername.
+func (s UsernameServiceImpl) Generate(ctx context.Context, email string) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UsernameServiceImpl.Generate")
+	defer span.Finish()
+
+	var err error
+	defer func(start time.Time, err error) {
+		if err != nil {
+			span.SetTag("Error", true)
+			span.LogKV("ErrorMsg", err.Error())
+		}
+	}(time.Now(), err)
+
+	base := Sanitize(email)
+	for attempt := 0; attempt < MAX_ATTEMPTS; attempt++ {
+		candidate := base
+		if attempt > 0 {
+			candidate = base + randomSuffix(attempt)
+		}
+		if IsReserved(candidate) {
+			continue
+		}
+
+		exists, err := s.userRepo.ExistsByUsername(ctx, candidate)
+		if err != nil {
+			log.Ctx(ctx).Error().Msgf("error when userRepo.ExistsByUsername(), err: %v", err)
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+
+	return "", utils.ErrorDuplicateUsername
+}
+
+// Validate username chosen by the user
+func (s UsernameServiceImpl) Validate(username string) error {
+	if len(username) > MAX_BASE_LENGTH || !utils.IsValidSlug(username) || strings.Contains(username, "/") || IsReserved(username) {
+		return model.INVALID_USERNAME
+	}
+	return nil
+}
+
+// Sanitise the email's local part into a username base following utils.IsValidSlug without slashes.
+// The plus tag is dropped and every run of other characters becomes a single dash.
+func Sanitize(email string) string {
+	local := strings.ToLower(strings.TrimSpace(email))
+	if at := strings.LastIndex(local, "@"); at >= 0 {
+		local = local[:at]
+	}
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+
+	var b strings.Builder
+	lastDash := false
+	for _, r := range local {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+			lastDash = false
+		default:
+			if !lastDash {
+				b.WriteRune('-')
+				lastDash = true
+			}
+		}
+	}
+
+	base := strings.Trim(b.String(), "-_")
+	if len(base) > MAX_BASE_LENGTH {
+		base = strings.TrimRight(base[:MAX_BASE_LENGTH], "-_")
+	}
+	if base == "" {
+		base = defaultBase
+	}
+
+	return base
+}
+
+// IsReserved check if username is blocked
+func IsReserved(username string) bool {
+	return reservedUsernames[strings.ToLower(username)]
+}
+
+// randomSuffix random number with the given number of digits
+func randomSuffix(digits int) string {
+	if digits > 9 {
+		digits = 9
+	}
+	low := int64(1)
+	for i := 1; i < digits; i++ {
+		low *= 10
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(low*9))
+	if err != nil {
+		return fmt.Sprint(low)
+	}
+	return fmt.Sprint(low + n.Int64())
+}
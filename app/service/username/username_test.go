@@ -0,0 +1,39 @@
+package username
+
+import (
+	"testing"
+
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	cases := map[string]string{
+		"John.Doe@example.com":                          "john-doe",
+		"john+newsletter@example.com":                   "john",
+		"__a..b--c__@example.com":                       "a-b-c",
+		"...@example.com":                               "user",
+		"José@example.com":                              "jos",
+		"averyveryveryveryverylongname1234@example.com": "averyveryveryveryverylongname1",
+	}
+	for email, expected := range cases {
+		base := Sanitize(email)
+		assert.Equal(t, expected, base, email)
+		assert.True(t, utils.IsValidSlug(base), email)
+	}
+}
+
+func TestRandomSuffix(t *testing.T) {
+	for digits := 1; digits <= 9; digits++ {
+		assert.Len(t, randomSuffix(digits), digits)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := UsernameServiceImpl{}
+	assert.NoError(t, s.Validate("john_doe"))
+	assert.Equal(t, model.INVALID_USERNAME, s.Validate("Admin"))
+	assert.Equal(t, model.INVALID_USERNAME, s.Validate("john/doe"))
+	assert.Equal(t, model.INVALID_USERNAME, s.Validate("john doe"))
+}
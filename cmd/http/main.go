@@ -1,27 +1,66 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 
 	c "github.com/erwinwahyura/go-boilerplate/app/config"
 	"github.com/erwinwahyura/go-boilerplate/app/database"
 	"github.com/erwinwahyura/go-boilerplate/app/handler"
 	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/app/model/constant"
+	"github.com/erwinwahyura/go-boilerplate/app/outbound"
 	"github.com/erwinwahyura/go-boilerplate/app/repository"
 	"github.com/erwinwahyura/go-boilerplate/app/route"
 	"github.com/erwinwahyura/go-boilerplate/app/service/healthcheck"
+	"github.com/erwinwahyura/go-boilerplate/app/service/logs"
+	"github.com/erwinwahyura/go-boilerplate/app/service/outbox"
+	"github.com/erwinwahyura/go-boilerplate/app/service/privacy"
 	"github.com/erwinwahyura/go-boilerplate/app/service/user"
+	"github.com/erwinwahyura/go-boilerplate/app/service/username"
 	"github.com/erwinwahyura/go-boilerplate/docs"
+	"github.com/erwinwahyura/go-boilerplate/utils/fieldcrypt"
+	"github.com/erwinwahyura/go-boilerplate/utils/lifecycle"
+	"github.com/erwinwahyura/go-boilerplate/utils/ratelimit"
 	"github.com/labstack/gommon/color"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
+const (
+	// DEFAULT_STARTUP_TIMEOUT used when DB_STARTUP_TIMEOUT is not set
+	DEFAULT_STARTUP_TIMEOUT = 60 * time.Second
+	// DEFAULT_LOG_STARTUP_TIMEOUT used when DB_LOG_STARTUP_TIMEOUT is not set
+	DEFAULT_LOG_STARTUP_TIMEOUT = 5 * time.Second
+
+	// RATE_LIMIT_BACKEND_REDIS share the rate limits of every instance, the other backends count
+	// per instance in memory
+	RATE_LIMIT_BACKEND_REDIS = "redis"
+
+	// IDEMPOTENCY_PURGE_INTERVAL between the removals of the expired idempotency keys
+	IDEMPOTENCY_PURGE_INTERVAL = time.Hour
+	// OUTBOX_PURGE_INTERVAL between the removals of the published outbox events
+	OUTBOX_PURGE_INTERVAL = time.Hour
+	// PRIVACY_JOB_PURGE_INTERVAL between the removals of the expired privacy jobs and their archive
+	PRIVACY_JOB_PURGE_INTERVAL = time.Hour
+)
+
+// the PII keys once shipped in sample.env, they are public and refused at startup
+var (
+	sampleMasterKey     = bytes.Repeat([]byte{1}, 32)
+	sampleBlindIndexKey = "changeme"
+)
+
 // Init initialize config to viper
 func LoadConfig(path string) (config model.Config, err error) {
 	viper.AutomaticEnv()
@@ -43,6 +82,66 @@ func LoadConfig(path string) (config model.Config, err error) {
 	return config, err
 }
 
+// loadKeyring set the default keyring of the encrypted PII columns
+func loadKeyring(config model.Config) error {
+	masterKeys, err := fieldcrypt.ParseMasterKeys(config.Encryption.MasterKeys)
+	if err != nil {
+		return err
+	}
+	for version, key := range masterKeys {
+		if bytes.Equal(key, sampleMasterKey) {
+			return fmt.Errorf("PII_MASTER_KEYS version %d is the published sample key, generate one with: openssl rand -base64 32", version)
+		}
+	}
+	if config.Encryption.BlindIndexKey == sampleBlindIndexKey {
+		return errors.New("PII_BLIND_INDEX_KEY is the published sample key, generate one with: openssl rand -base64 32")
+	}
+	keyring, err := fieldcrypt.NewKeyring(masterKeys, config.Encryption.MasterKeyVersion, []byte(config.Encryption.BlindIndexKey))
+	if err != nil {
+		return err
+	}
+	fieldcrypt.SetDefault(keyring)
+	return nil
+}
+
+// startupTimeout how long postgres is retried at startup
+func startupTimeout(config model.Config) time.Duration {
+	if config.Database.StartupTimeout <= 0 {
+		return DEFAULT_STARTUP_TIMEOUT
+	}
+	return time.Duration(config.Database.StartupTimeout) * time.Second
+}
+
+// logStartupTimeout how long the log database is waited for at startup
+func logStartupTimeout(config model.Config) time.Duration {
+	if config.Database.LogStartupTimeout <= 0 {
+		return DEFAULT_LOG_STARTUP_TIMEOUT
+	}
+	return time.Duration(config.Database.LogStartupTimeout) * time.Second
+}
+
+// newRateLimiter store of the rate limits, the redis backend shares the client of the health check
+func newRateLimiter(config model.Config, redisClient *redis.Client) ratelimit.Store {
+	if config.RateLimit.Backend != RATE_LIMIT_BACKEND_REDIS {
+		return ratelimit.NewMemoryStore()
+	}
+	if redisClient == nil {
+		log.Fatal("RATE_LIMIT_BACKEND is redis but REDIS_HOST is not set")
+	}
+	return ratelimit.NewRedisStore(redisClient, "ratelimit:")
+}
+
+// registerMetrics export the connection pools and the dropped http logs on /metrics
+func registerMetrics(postgresCollection database.PostgresCollection, mongoCollection database.MongoCollection, logHTTPRepo repository.LogHTTPRepository) {
+	prometheus.MustRegister(database.NewPoolCollectors(postgresCollection, mongoCollection)...)
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "log_http_dropped_total",
+		Help: "HTTP logs lost because the buffer was full or the insert failed.",
+	}, func() float64 {
+		return float64(logHTTPRepo.Dropped())
+	}))
+}
+
 // SetSwaggerInfo swagger
 func setSwaggerInfo(config model.Config) {
 	docs.SwaggerInfo.Title = "Api"
@@ -54,6 +153,9 @@ func setSwaggerInfo(config model.Config) {
 
 func main() {
 
+	// the zerolog lines of a context without the request logger go to the global logger
+	zerolog.DefaultContextLogger = &zlog.Logger
+
 	// Config
 	log.Println("[INFO] Loading environment")
 	cfg, err := LoadConfig(".")
@@ -63,33 +165,98 @@ func main() {
 	// reload secret
 	c.Reload()
 
+	// compact JSON responses in production, ?pretty=1 still indents them
+	model.CompactJSON = model.CompactJSON || cfg.Env == constant.ENV_PROD
+
+	// Migration
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatal("migrate: ", err)
+		}
+		return
+	}
+
+	// PII encryption
+	if err := loadKeyring(cfg); err != nil {
+		log.Fatal("cannot load pii encryption keys: ", err)
+	}
+
 	// DB
 	log.Println("[INFO] Loading database")
-	mongoCollection := database.NewMongoCollection(cfg)
-	postgresCollection := database.NewPostgresCollection(cfg)
+	startupCtx, cancelStartup := context.WithTimeout(context.Background(), startupTimeout(cfg))
+	postgresCollection, err := database.NewPostgresCollection(startupCtx, cfg)
+	cancelStartup()
+	if err != nil {
+		log.Fatal("cannot connect postgres: ", err)
+	}
+	logStartupCtx, cancelLogStartup := context.WithTimeout(context.Background(), logStartupTimeout(cfg))
+	mongoCollection, err := database.NewMongoCollection(logStartupCtx, cfg)
+	cancelLogStartup()
+	if err != nil {
+		log.Fatal("cannot connect mongodb log: ", err)
+	}
+	if cfg.Database.DB.MigrateOnStart {
+		log.Println("[INFO] Applying migration")
+		if err := migrateOnStart(postgresCollection); err != nil {
+			log.Fatal("cannot apply migration: ", err)
+		}
+	}
 
 	// Repository
 	log.Println("[INFO] Loading repository")
+	txManager := database.NewTxManager(postgresCollection)
 	userRepo := repository.NewUserRepository(postgresCollection)
+	userHistoryRepo := repository.NewUserHistoryRepository(mongoCollection)
+	outboxRepo := repository.NewOutboxRepository(postgresCollection)
+	idempotencyRepo := repository.NewIdempotencyRepository(postgresCollection)
+	privacyJobRepo := repository.NewPrivacyJobRepository(postgresCollection)
+	logHTTPRepo := repository.NewLogHTTPRepository(mongoCollection, cfg.Database.LogDB.HTTPBufferSize, time.Duration(cfg.Database.LogDB.HTTPTTL)*24*time.Hour)
 
 	// Outbound
 	log.Println("[INFO] Loading outbound")
+	myValueOutbound := outbound.NewMyValueOutbound(cfg)
+	imageOutbound := outbound.NewImageOutbound(cfg)
 
 	// NSQ Producer
 	log.Println("[INFO] Loading nsq producer")
+	publisher := outbound.NewPublisher(cfg)
 
 	// Shared Service
 	log.Println("[INFO] Loading Shared Service")
+	redisClient := database.NewRedisClient(cfg)
+	rateLimiter := newRateLimiter(cfg, redisClient)
+
+	// Health Check
+	log.Println("[INFO] Loading health checks")
+	healthRegistry := healthcheck.NewRegistry(cfg)
+	healthcheck.RegisterDatabaseChecks(healthRegistry, mongoCollection, postgresCollection)
+	healthcheck.RegisterOutboundChecks(healthRegistry, cfg, redisClient)
 
 	// Service
 	log.Println("[INFO] Loading service")
-	healthService := healthcheck.NewService(cfg, mongoCollection, postgresCollection)
-	userService := user.NewService(cfg, mongoCollection, userRepo)
+	healthService := healthcheck.NewService(cfg, mongoCollection, postgresCollection, healthRegistry)
+	registerMetrics(postgresCollection, mongoCollection, logHTTPRepo)
+	usernameService := username.NewService(userRepo)
+	userService := user.NewService(cfg, mongoCollection, userRepo, userHistoryRepo, outboxRepo, myValueOutbound, usernameService, txManager)
+	privacyService := privacy.NewService(cfg, userRepo, userHistoryRepo, privacyJobRepo, txManager, imageOutbound)
+	logService := logs.NewService(logHTTPRepo)
+	outboxService := outbox.NewService(outboxRepo)
+
+	// Outbox Relay
+	log.Println("[INFO] Loading outbox relay")
+	relay := outbox.NewRelay(cfg, outboxRepo, publisher)
+
+	// Privacy Job Runner
+	log.Println("[INFO] Loading privacy job runner")
+	privacyRunner := privacy.NewRunner(privacyService)
 
 	// Handler
 	log.Println("[INFO] Loading handler")
 	healthHandler := handler.NewHealthHandler(healthService)
 	userHandler := handler.NewUserHandler(userService)
+	privacyHandler := handler.NewPrivacyHandler(privacyService)
+	logHandler := handler.NewLogHandler(logService)
+	outboxHandler := handler.NewOutboxHandler(outboxService)
 
 	// NSQ Consumer
 	log.Println("[INFO] Loading nsq consumer")
@@ -99,20 +266,83 @@ func main() {
 
 	// Server & Router
 	log.Println("[INFO] Loading router")
-	router := route.NewRoutes(cfg, healthHandler, userHandler)
+	router := route.NewRoutes(cfg, healthHandler, userHandler, privacyHandler, logHandler, outboxHandler, userRepo, logHTTPRepo, rateLimiter, idempotencyRepo)
+
+	// Lifecycle, the components stop in reverse order: the server first, the databases last
+	log.Println("[INFO] Loading lifecycle")
+	manager := lifecycle.New(time.Duration(cfg.Host.DrainDelay)*time.Second, time.Duration(cfg.Host.ShutdownTimeout)*time.Second)
+	manager.OnShutdown(healthRegistry.Drain)
+	manager.Append(lifecycle.Hook{
+		Name: "postgres",
+		Stop: func(ctx context.Context) error { return postgresCollection.Close() },
+	})
+	manager.Append(lifecycle.Hook{
+		Name: "mongodb log",
+		Stop: mongoCollection.Close,
+	})
+	if redisClient != nil {
+		manager.Append(lifecycle.Hook{
+			Name: "redis",
+			Stop: func(ctx context.Context) error { return redisClient.Close() },
+		})
+	}
+	// the buffered http logs are inserted before the log database is closed
+	manager.Append(lifecycle.Hook{
+		Name: "http log",
+		Stop: logHTTPRepo.Close,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:  "health checks",
+		Start: func(ctx context.Context) error { healthRegistry.Start(); return nil },
+		Stop:  healthRegistry.Stop,
+	})
+	// the events of an interrupted batch are published again once their lease runs out
+	manager.Append(lifecycle.Hook{
+		Name:  "outbox relay",
+		Start: func(ctx context.Context) error { relay.Start(); return nil },
+		Stop:  relay.Stop,
+	})
+	// an interrupted job resumes after its last step once its lease runs out
+	// every batch of the rotation is committed on its own, it is started again after the restart
+	manager.Append(lifecycle.Hook{
+		Name: "encryption key rotation",
+		Stop: userService.StopEncryptionKeyRotation,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:  "privacy job runner",
+		Start: func(ctx context.Context) error { privacyRunner.Start(); return nil },
+		Stop:  privacyRunner.Stop,
+	})
+	manager.Append(lifecycle.Every("privacy job purge", PRIVACY_JOB_PURGE_INTERVAL, func(ctx context.Context) {
+		if _, err := privacyService.PurgeFinished(ctx); err != nil && ctx.Err() == nil {
+			zlog.Error().Msgf("error when privacyService.PurgeFinished(), err: %v", err)
+		}
+	}))
+	manager.Append(lifecycle.Every("outbox purge", OUTBOX_PURGE_INTERVAL, func(ctx context.Context) {
+		if _, err := relay.PurgePublished(ctx); err != nil && ctx.Err() == nil {
+			zlog.Error().Msgf("error when relay.PurgePublished(), err: %v", err)
+		}
+	}))
+	manager.Append(lifecycle.Every("idempotency purge", IDEMPOTENCY_PURGE_INTERVAL, func(ctx context.Context) {
+		if _, err := idempotencyRepo.DeleteExpired(ctx); err != nil && ctx.Err() == nil {
+			zlog.Error().Msgf("error when idempotencyRepo.DeleteExpired(), err: %v", err)
+		}
+	}))
+	manager.Append(serverHook(cfg, router, manager))
 
-	// Server Runner
-	log.Println("[INFO] Loading server")
-	serverRunner(cfg, router)
+	// NSQ Consumer
+
+	if err := manager.Run(context.Background()); err != nil {
+		log.Fatal("shutdown with error: ", err)
+	}
+	log.Println("Shutdown gracefully completed")
 }
 
 // var tracer trace.Tracer
 
-// ServerRunner run server
-func serverRunner(
-	cfg model.Config,
-	handler http.Handler,
-) {
+// serverHook listen when started so a taken port fails the startup, a server failing later shuts
+// the app down
+func serverHook(cfg model.Config, handler http.Handler, manager *lifecycle.Manager) lifecycle.Hook {
 	// Tracer
 	// tracer, closer := jaegerutil.NewTracerJaeger("api-starter", cfg.Jaeger.URL, cfg.Jaeger.Disable)
 	// // Set the singleton opentracing.Tracer with the Jaeger tracer.
@@ -128,54 +358,25 @@ func serverRunner(
 		Handler:      handler,
 	}
 
-	// Run Server
-	go func() {
-		color.Printf("⇨ http server started on %s\n", color.Green(server.Addr))
-		server.ListenAndServe()
-	}()
-
-	// NSQ Consumer
-
-	// Wait to shooting down
-	quit := make(chan os.Signal, 1)
-
-	signal.Notify(quit, os.Interrupt)
-	sig := <-quit
-	log.Println("received shutdown signal. Trying to shutdown gracefully", sig)
-
-	// Context
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Stop Server
-	color.Println(color.Red("Stopping HTTP Server"))
-	server.SetKeepAlivesEnabled(false)
-	err := server.Shutdown(ctx)
-	if err != nil {
-		log.Fatal("Failure while shutting down gracefully, errApp: ", err)
+	return lifecycle.Hook{
+		Name: "http server",
+		Start: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				color.Printf("⇨ http server started on %s\n", color.Green(server.Addr))
+				if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					manager.Fail(err)
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			color.Println(color.Red("Stopping HTTP Server"))
+			server.SetKeepAlivesEnabled(false)
+			return server.Shutdown(ctx)
+		},
 	}
-
-	// Stop NSQ Consumer
-
-	log.Println("Shutdown gracefully completed")
 }
-
-// func newTraceProvider(exp sdktrace.SpanExporter) *sdktrace.TracerProvider {
-// 	// Ensure default SDK resources and the required service name are set.
-// 	r, err := resource.Merge(
-// 		resource.Default(),
-// 		resource.NewWithAttributes(
-// 			semconv.SchemaURL,
-// 			semconv.ServiceName("APIStarter"),
-// 		),
-// 	)
-
-// 	if err != nil {
-// 		panic(err)
-// 	}
-
-// 	return sdktrace.NewTracerProvider(
-// 		sdktrace.WithBatcher(exp),
-// 		sdktrace.WithResource(r),
-// 	)
-// }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/erwinwahyura/go-boilerplate/app/database"
+	"github.com/erwinwahyura/go-boilerplate/app/database/migration"
+	"github.com/erwinwahyura/go-boilerplate/app/model"
+	"github.com/erwinwahyura/go-boilerplate/utils/sqlxdb"
+)
+
+// MIGRATE_TIMEOUT a migration command gives up after this long, including waiting for the lock
+const MIGRATE_TIMEOUT = 10 * time.Minute
+
+const migrateUsage = `usage: http migrate [-dir source directory] <command>
+
+commands:
+  up              apply every pending migration
+  down [steps]    revert the last steps migrations, default 1
+  status          list the migrations and when they were applied
+  create <name>   add an empty migration into ` + migration.SOURCE_DIR
+
+// runMigrate run the migrate sub command, args are the arguments after "migrate"
+func runMigrate(cfg model.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dir := flags.String("dir", migration.SOURCE_DIR, "source directory of create")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	// create only writes files, it does not need the database
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return fmt.Errorf("migrate create: name is required\n\n%s", migrateUsage)
+		}
+		up, down, err := migration.Create(*dir, args[1])
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] created %s and %s, rebuild the binary to embed them", up, down)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), MIGRATE_TIMEOUT)
+	defer cancel()
+
+	connectCtx, cancelConnect := context.WithTimeout(ctx, startupTimeout(cfg))
+	defer cancelConnect()
+	db, err := sqlxdb.ConnectSqlxDsn(connectCtx, database.DRIVER_POSTGRES, cfg.Database.DB.Master, sqlxdb.PoolConfig{MaxOpenConns: 2})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("[INFO] applied %06d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("[INFO] no pending migration")
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("migrate down: invalid steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("[INFO] reverted %06d_%s", m.Version, m.Name)
+		}
+		if err == migration.ErrNoChange {
+			log.Println("[INFO] no applied migration")
+			return nil
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%06d  %-40s  %s\n", s.Version, s.Name, appliedAt)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("migrate: unknown command %q\n\n%s", args[0], migrateUsage)
+	}
+}
+
+// migrateOnStart apply the pending migrations before the server starts listening
+func migrateOnStart(postgresCollection database.PostgresCollection) error {
+	migrator, err := migration.NewMigrator(postgresCollection.Master)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), MIGRATE_TIMEOUT)
+	defer cancel()
+
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		log.Printf("[INFO] applied migration %06d_%s", m.Version, m.Name)
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+type (
+	// Backoff exponential backoff with full jitter
+	Backoff struct {
+		// Initial upper bound of the first wait
+		Initial time.Duration
+		// Max upper bound of any wait
+		Max time.Duration
+		// Multiplier growth of the upper bound per attempt
+		Multiplier float64
+	}
+)
+
+// Default backoff of connection retries, 0.5s 1s 2s 4s ... up to 30s
+var Default = Backoff{
+	Initial:    500 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+}
+
+// Wait random wait before the next attempt, attempt starts at 1. Full jitter keeps many pods
+// restarting together from hitting the dependency at the same moment.
+func (b Backoff) Wait(attempt int) time.Duration {
+	upper := float64(b.Initial)
+	for i := 1; i < attempt && upper < float64(b.Max); i++ {
+		upper *= b.Multiplier
+	}
+	if upper > float64(b.Max) {
+		upper = float64(b.Max)
+	}
+	if upper <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(upper)) + 1)
+}
+
+// Retry call fn until it succeeds or ctx is done, the last error of fn is returned when ctx is done.
+// onRetry is called before every wait and may be nil.
+func (b Backoff) Retry(ctx context.Context, fn func(ctx context.Context) error, onRetry func(attempt int, wait time.Duration, err error)) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		wait := b.Wait(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt, wait, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWait(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	for i := 0; i < 100; i++ {
+		assert.LessOrEqual(t, b.Wait(1), 100*time.Millisecond)
+		assert.LessOrEqual(t, b.Wait(3), 400*time.Millisecond)
+		assert.LessOrEqual(t, b.Wait(50), time.Second)
+		assert.Greater(t, b.Wait(50), time.Duration(0))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	b := Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2}
+
+	calls := 0
+	err := b.Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// the last error is returned once the deadline is reached
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = b.Retry(ctx, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}, nil)
+	assert.EqualError(t, err, "connection refused")
+}
@@ -0,0 +1,271 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Ciphertext format, every value has its own data key wrapped by a versioned master key
+// enc:v1:<master key version>:<base64 wrapped data key>:<base64 nonce + sealed value>
+const (
+	prefix         = "enc"
+	formatVersion  = "v1"
+	dataKeySize    = 32
+	wrapAssociated = "fieldcrypt-data-key"
+)
+
+var (
+	ErrKeyringNotConfigured = errors.New("fieldcrypt: keyring is not configured")
+	ErrInvalidCiphertext    = errors.New("fieldcrypt: invalid ciphertext")
+	ErrUnknownKeyVersion    = errors.New("fieldcrypt: unknown master key version")
+	ErrInvalidMasterKey     = errors.New("fieldcrypt: master key must be 32 bytes")
+
+	defaultKeyring atomic.Pointer[Keyring]
+)
+
+// Keyring master keys by version, new values are always wrapped by the current version
+type Keyring struct {
+	current  uint32
+	masters  map[uint32]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyring initialize keyring, every master key must be 32 bytes (AES-256)
+func NewKeyring(masterKeys map[uint32][]byte, currentVersion uint32, indexKey []byte) (*Keyring, error) {
+	if _, ok := masterKeys[currentVersion]; !ok {
+		return nil, fmt.Errorf("%w: current version %d", ErrUnknownKeyVersion, currentVersion)
+	}
+	if len(indexKey) == 0 {
+		return nil, errors.New("fieldcrypt: blind index key is empty")
+	}
+
+	masters := make(map[uint32]cipher.AEAD, len(masterKeys))
+	for version, key := range masterKeys {
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("%w: version %d", ErrInvalidMasterKey, version)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		masters[version] = aead
+	}
+
+	return &Keyring{
+		current:  currentVersion,
+		masters:  masters,
+		indexKey: indexKey,
+	}, nil
+}
+
+// ParseMasterKeys parse "1:<base64 key>,2:<base64 key>" into master keys by version
+func ParseMasterKeys(value string) (map[uint32][]byte, error) {
+	keys := map[uint32][]byte{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		version, encoded, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("fieldcrypt: invalid master key %q, expected <version>:<base64 key>", version)
+		}
+		v, err := strconv.ParseUint(version, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: invalid master key version %q", version)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: invalid master key version %d: %w", v, err)
+		}
+		keys[uint32(v)] = key
+	}
+
+	return keys, nil
+}
+
+// SetDefault set the keyring used by the sql Scanner and Valuer types
+func SetDefault(k *Keyring) {
+	defaultKeyring.Store(k)
+}
+
+// Default get the keyring used by the sql Scanner and Valuer types
+func Default() *Keyring {
+	return defaultKeyring.Load()
+}
+
+// CurrentVersion version of the master key used for new values
+func (k *Keyring) CurrentVersion() uint32 {
+	return k.current
+}
+
+// Encrypt seal plaintext with a new data key wrapped by the current master key
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(k.masters[k.current], dataKey, []byte(wrapAssociated))
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataAEAD, plaintext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		prefix,
+		formatVersion,
+		strconv.FormatUint(uint64(k.current), 10),
+		base64.RawStdEncoding.EncodeToString(wrapped),
+		base64.RawStdEncoding.EncodeToString(sealed),
+	}, ":"), nil
+}
+
+// Decrypt open a value sealed by Encrypt with any known master key version
+func (k *Keyring) Decrypt(ciphertext string) ([]byte, error) {
+	parts, err := splitCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := k.unwrap(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(parts.sealed)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dataAEAD, sealed, nil)
+}
+
+// Rotate rewrap the data key of ciphertext with the current master key, the value itself is not
+// decrypted. rotated is false when the ciphertext already uses the current version.
+func (k *Keyring) Rotate(ciphertext string) (result string, rotated bool, err error) {
+	parts, err := splitCiphertext(ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+	if parts.version == k.current {
+		return ciphertext, false, nil
+	}
+
+	dataKey, err := k.unwrap(parts)
+	if err != nil {
+		return "", false, err
+	}
+	wrapped, err := seal(k.masters[k.current], dataKey, []byte(wrapAssociated))
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.Join([]string{
+		prefix,
+		formatVersion,
+		strconv.FormatUint(uint64(k.current), 10),
+		base64.RawStdEncoding.EncodeToString(wrapped),
+		parts.sealed,
+	}, ":"), true, nil
+}
+
+// BlindIndex keyed hash of value for exact-match lookups, the caller must normalise value first
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted check whether value was produced by Encrypt, legacy plaintext rows are not
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix+":")
+}
+
+type ciphertextParts struct {
+	version uint32
+	wrapped string
+	sealed  string
+}
+
+func splitCiphertext(ciphertext string) (ciphertextParts, error) {
+	parts := strings.Split(ciphertext, ":")
+	if len(parts) != 5 || parts[0] != prefix || parts[1] != formatVersion {
+		return ciphertextParts{}, ErrInvalidCiphertext
+	}
+	version, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return ciphertextParts{}, ErrInvalidCiphertext
+	}
+
+	return ciphertextParts{
+		version: uint32(version),
+		wrapped: parts[3],
+		sealed:  parts[4],
+	}, nil
+}
+
+func (k *Keyring) unwrap(parts ciphertextParts) ([]byte, error) {
+	master, ok := k.masters[parts.version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, parts.version)
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts.wrapped)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	return open(master, wrapped, []byte(wrapAssociated))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce + sealed plaintext
+func seal(aead cipher.AEAD, plaintext, associated []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, associated), nil
+}
+
+func open(aead cipher.AEAD, sealed, associated []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, body := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, body, associated)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,121 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyring(t *testing.T, current uint32) *Keyring {
+	keys := map[uint32][]byte{
+		1: bytes.Repeat([]byte{1}, 32),
+		2: bytes.Repeat([]byte{2}, 32),
+	}
+	k, err := NewKeyring(keys, current, []byte("index-key"))
+	assert.NoError(t, err)
+	return k
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := newTestKeyring(t, 1)
+
+	ciphertext, err := k.Encrypt([]byte("3171234567890001"))
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(ciphertext))
+	assert.NotContains(t, ciphertext, "3171234567890001")
+
+	plaintext, err := k.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "3171234567890001", string(plaintext))
+
+	// every value has its own data key
+	other, err := k.Encrypt([]byte("3171234567890001"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, ciphertext, other)
+}
+
+func TestDecryptTampered(t *testing.T) {
+	k := newTestKeyring(t, 1)
+
+	ciphertext, err := k.Encrypt([]byte("secret"))
+	assert.NoError(t, err)
+
+	tampered := ciphertext[:len(ciphertext)-2] + "AA"
+	_, err = k.Decrypt(tampered)
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = k.Decrypt("not encrypted")
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
+
+func TestRotate(t *testing.T) {
+	old := newTestKeyring(t, 1)
+	ciphertext, err := old.Encrypt([]byte("081234567890"))
+	assert.NoError(t, err)
+
+	k := newTestKeyring(t, 2)
+	rotated, ok, err := k.Rotate(ciphertext)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotEqual(t, ciphertext, rotated)
+
+	plaintext, err := k.Decrypt(rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, "081234567890", string(plaintext))
+
+	_, ok, err = k.Rotate(rotated)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// version 1 is retired
+	retired, err := NewKeyring(map[uint32][]byte{2: bytes.Repeat([]byte{2}, 32)}, 2, []byte("index-key"))
+	assert.NoError(t, err)
+	_, err = retired.Decrypt(ciphertext)
+	assert.ErrorIs(t, err, ErrUnknownKeyVersion)
+}
+
+func TestBlindIndex(t *testing.T) {
+	k := newTestKeyring(t, 1)
+	assert.Equal(t, k.BlindIndex("+6281234567890"), k.BlindIndex("+6281234567890"))
+	assert.NotEqual(t, k.BlindIndex("+6281234567890"), k.BlindIndex("+6281234567891"))
+
+	other, err := NewKeyring(map[uint32][]byte{1: bytes.Repeat([]byte{1}, 32)}, 1, []byte("other-key"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, k.BlindIndex("+6281234567890"), other.BlindIndex("+6281234567890"))
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	keys, err := ParseMasterKeys("1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=, 2:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=")
+	assert.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{1}, 32), keys[1])
+	assert.Equal(t, bytes.Repeat([]byte{2}, 32), keys[2])
+
+	_, err = ParseMasterKeys("invalid")
+	assert.Error(t, err)
+}
+
+func TestScannerValuer(t *testing.T) {
+	SetDefault(newTestKeyring(t, 1))
+	defer SetDefault(nil)
+
+	value, err := EncryptedString("3171234567890001").Value()
+	assert.NoError(t, err)
+
+	var s EncryptedString
+	assert.NoError(t, s.Scan(value))
+	assert.Equal(t, "3171234567890001", s.String())
+
+	// legacy plaintext
+	assert.NoError(t, s.Scan([]byte("plain")))
+	assert.Equal(t, "plain", s.String())
+
+	birthDate := time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)
+	value, err = EncryptedTime{Time: birthDate}.Value()
+	assert.NoError(t, err)
+
+	var bt EncryptedTime
+	assert.NoError(t, bt.Scan(value))
+	assert.True(t, birthDate.Equal(bt.Time))
+}
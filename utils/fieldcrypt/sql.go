@@ -0,0 +1,99 @@
+package fieldcrypt
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type (
+	// EncryptedString string stored encrypted, repositories read and write the plaintext
+	EncryptedString string
+
+	// EncryptedTime time stored encrypted, repositories read and write the plaintext
+	EncryptedTime struct {
+		time.Time
+	}
+)
+
+// Value encrypt with the default keyring
+func (s EncryptedString) Value() (driver.Value, error) {
+	return encryptValue(string(s))
+}
+
+// Scan decrypt with the default keyring, legacy plaintext values are returned as is
+func (s *EncryptedString) Scan(src interface{}) error {
+	plaintext, err := decryptValue(src)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// String plaintext value
+func (s EncryptedString) String() string {
+	return string(s)
+}
+
+// Value encrypt with the default keyring
+func (t EncryptedTime) Value() (driver.Value, error) {
+	return encryptValue(t.Time.Format(time.RFC3339Nano))
+}
+
+// Scan decrypt with the default keyring, legacy date columns are returned as is
+func (t *EncryptedTime) Scan(src interface{}) error {
+	if value, ok := src.(time.Time); ok {
+		t.Time = value
+		return nil
+	}
+
+	plaintext, err := decryptValue(src)
+	if err != nil {
+		return err
+	}
+	value, err := time.Parse(time.RFC3339Nano, plaintext)
+	if err != nil {
+		// legacy date column stored as text
+		value, err = time.Parse("2006-01-02", plaintext)
+		if err != nil {
+			return fmt.Errorf("fieldcrypt: invalid time %q", plaintext)
+		}
+	}
+	t.Time = value
+	return nil
+}
+
+func encryptValue(plaintext string) (driver.Value, error) {
+	keyring := Default()
+	if keyring == nil {
+		return nil, ErrKeyringNotConfigured
+	}
+	return keyring.Encrypt([]byte(plaintext))
+}
+
+func decryptValue(src interface{}) (string, error) {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return "", fmt.Errorf("fieldcrypt: cannot scan %T", src)
+	}
+
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	keyring := Default()
+	if keyring == nil {
+		return "", ErrKeyringNotConfigured
+	}
+	plaintext, err := keyring.Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
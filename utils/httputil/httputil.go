@@ -95,3 +95,22 @@ func RequestBodyToStruct(w http.ResponseWriter, body io.ReadCloser, destination
 
 // 	return restyClient
 // }
+
+// FlushWriter flush every write to the client, used to stream a response body
+type FlushWriter struct {
+	w http.ResponseWriter
+}
+
+// NewFlushWriter wrap the response writer into FlushWriter
+func NewFlushWriter(w http.ResponseWriter) *FlushWriter {
+	return &FlushWriter{w: w}
+}
+
+// Write write p then flush it to the client
+func (fw *FlushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if flusher, ok := fw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,47 @@
+package lifecycle
+
+import (
+	"context"
+	"time"
+)
+
+// Every hook running fn each interval in the background, the first run is one interval after the
+// start. Stop waits for the run in flight, its ctx is canceled on stop.
+func Every(name string, interval time.Duration, fn func(ctx context.Context)) Hook {
+	var (
+		cancel context.CancelFunc
+		done   chan struct{}
+	)
+
+	return Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						fn(ctx)
+					}
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
@@ -0,0 +1,190 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// DEFAULT_DRAIN_DELAY used when the drain delay is not set
+	DEFAULT_DRAIN_DELAY = 5 * time.Second
+	// DEFAULT_STOP_TIMEOUT used when the stop timeout is not set, with the drain delay it stays
+	// within the default 30s grace period of Kubernetes
+	DEFAULT_STOP_TIMEOUT = 20 * time.Second
+)
+
+type (
+	// Hook start and stop of a component, either may be nil. Start must not block, a long running
+	// component runs in its own goroutine and reports its failure with Manager.Fail.
+	Hook struct {
+		Name  string
+		Start func(ctx context.Context) error
+		Stop  func(ctx context.Context) error
+		// StopTimeout caps the time of this component within the stop timeout of the manager, 0
+		// leaves it whatever is left of the stop timeout
+		StopTimeout time.Duration
+	}
+
+	// Manager start the components in the order they were appended and stop them in reverse, so a
+	// component is appended after the ones it depends on. On shutdown the OnShutdown callbacks run
+	// first, e.g. to fail the readiness, then the drain delay lets the load balancers stop routing
+	// before the components are stopped.
+	Manager struct {
+		drainDelay  time.Duration
+		stopTimeout time.Duration
+
+		mu         sync.Mutex
+		hooks      []Hook
+		started    []Hook
+		onShutdown []func()
+		failed     chan error
+	}
+)
+
+// New lifecycle manager, a zero drainDelay or stopTimeout uses the default
+func New(drainDelay, stopTimeout time.Duration) *Manager {
+	if drainDelay <= 0 {
+		drainDelay = DEFAULT_DRAIN_DELAY
+	}
+	if stopTimeout <= 0 {
+		stopTimeout = DEFAULT_STOP_TIMEOUT
+	}
+
+	return &Manager{
+		drainDelay:  drainDelay,
+		stopTimeout: stopTimeout,
+		failed:      make(chan error, 1),
+	}
+}
+
+// Append add the hook of a component
+func (m *Manager) Append(hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook)
+}
+
+// OnShutdown fn is called as soon as the shutdown begins, before the drain delay
+func (m *Manager) OnShutdown(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onShutdown = append(m.onShutdown, fn)
+}
+
+// Fail shut down because a component can not keep running, only the first failure is kept
+func (m *Manager) Fail(err error) {
+	select {
+	case m.failed <- err:
+	default:
+	}
+}
+
+// Start the components in order, when one fails the started ones are stopped
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := append([]Hook{}, m.hooks...)
+	m.mu.Unlock()
+
+	for _, hook := range hooks {
+		if hook.Start != nil {
+			log.Info().Msgf("starting %s", hook.Name)
+			if err := hook.Start(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", hook.Name, err)
+				return errors.Join(err, m.stop())
+			}
+		}
+		m.mu.Lock()
+		m.started = append(m.started, hook)
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Run start the components and wait for SIGINT, SIGTERM, a failed component or ctx to be done,
+// then shut down. A second signal kills the process without waiting.
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	var failure error
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("received shutdown signal, shutting down gracefully")
+	case failure = <-m.failed:
+		log.Error().Msgf("component failed, shutting down, err: %v", failure)
+	}
+	stop()
+
+	return errors.Join(failure, m.Shutdown())
+}
+
+// Shutdown call the OnShutdown callbacks, wait the drain delay and stop the started components in
+// reverse order, all of them under one deadline of stop timeout
+func (m *Manager) Shutdown() error {
+	m.mu.Lock()
+	onShutdown := append([]func(){}, m.onShutdown...)
+	m.mu.Unlock()
+
+	for _, fn := range onShutdown {
+		fn()
+	}
+	log.Info().Msgf("draining for %s", m.drainDelay)
+	time.Sleep(m.drainDelay)
+
+	return m.stop()
+}
+
+// stop the started components in reverse order, a slow component leaves less time to the ones
+// stopped after it but the shutdown as a whole never exceeds the stop timeout
+func (m *Manager) stop() error {
+	m.mu.Lock()
+	started := m.started
+	m.started = nil
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.stopTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		hook := started[i]
+		if hook.Stop == nil {
+			continue
+		}
+		if err := m.stopHook(ctx, hook); err != nil {
+			log.Error().Msgf("error when stop %s, err: %v", hook.Name, err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", hook.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopHook a Stop ignoring its context is abandoned at the deadline so it can not hang the shutdown,
+// once the deadline is spent the remaining components are still called with the expired ctx
+func (m *Manager) stopHook(ctx context.Context, hook Hook) error {
+	if hook.StopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.StopTimeout)
+		defer cancel()
+	}
+
+	log.Info().Msgf("stopping %s", hook.Name)
+	done := make(chan error, 1)
+	go func() { done <- hook.Stop(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,139 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder hooks appending their events in order
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) hook(name string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			r.record("start " + name)
+			return startErr
+		},
+		Stop: func(ctx context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestShutdownOrder(t *testing.T) {
+	r := &recorder{}
+	m := New(20*time.Millisecond, time.Second)
+	m.Append(r.hook("database", nil))
+	m.Append(r.hook("server", nil))
+	var drainedAt time.Time
+	m.OnShutdown(func() {
+		drainedAt = time.Now()
+		r.record("drain")
+	})
+
+	require.NoError(t, m.Start(context.Background()))
+	require.NoError(t, m.Shutdown())
+
+	assert.Equal(t, []string{"start database", "start server", "drain", "stop server", "stop database"}, r.events)
+	assert.GreaterOrEqual(t, time.Since(drainedAt), 20*time.Millisecond)
+}
+
+func TestStartFailureStopsStarted(t *testing.T) {
+	r := &recorder{}
+	m := New(time.Millisecond, time.Second)
+	m.Append(r.hook("database", nil))
+	m.Append(r.hook("server", errors.New("address already in use")))
+	m.Append(r.hook("consumer", nil))
+
+	err := m.Start(context.Background())
+	assert.ErrorContains(t, err, "start server: address already in use")
+	assert.Equal(t, []string{"start database", "start server", "stop database"}, r.events)
+}
+
+func TestStopDeadline(t *testing.T) {
+	r := &recorder{}
+	m := New(time.Millisecond, 20*time.Millisecond)
+	m.Append(r.hook("database", nil))
+	m.Append(Hook{
+		Name: "consumer",
+		// ignore the deadline
+		Stop: func(ctx context.Context) error {
+			time.Sleep(time.Hour)
+			return nil
+		},
+	})
+
+	require.NoError(t, m.Start(context.Background()))
+	start := time.Now()
+	err := m.Shutdown()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// the deadline is spent, database is still told to stop
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.events) == 2 && r.events[1] == "stop database"
+	}, time.Second, time.Millisecond)
+}
+
+func TestStopDeadlineIsShared(t *testing.T) {
+	m := New(time.Millisecond, 50*time.Millisecond)
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	m.Append(Hook{Name: "database", Stop: slow})
+	m.Append(Hook{Name: "consumer", Stop: slow})
+	m.Append(Hook{Name: "server", Stop: slow})
+
+	require.NoError(t, m.Start(context.Background()))
+	start := time.Now()
+	err := m.Shutdown()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestRunFail(t *testing.T) {
+	r := &recorder{}
+	m := New(time.Millisecond, time.Second)
+	m.Append(r.hook("server", nil))
+
+	go m.Fail(errors.New("listener closed"))
+	err := m.Run(context.Background())
+	assert.ErrorContains(t, err, "listener closed")
+	assert.Equal(t, []string{"start server", "stop server"}, r.events)
+}
+
+func TestEvery(t *testing.T) {
+	runs := make(chan struct{}, 10)
+	hook := Every("purge", 5*time.Millisecond, func(ctx context.Context) { runs <- struct{}{} })
+
+	require.NoError(t, hook.Start(context.Background()))
+	<-runs
+	<-runs
+	require.NoError(t, hook.Stop(context.Background()))
+
+	// no run after the stop
+	for len(runs) > 0 {
+		<-runs
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, runs)
+}
@@ -2,35 +2,116 @@ package mongodb
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
-	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+// SERVER_SELECTION_TIMEOUT how long an operation waits for a server, short so requests fail fast
+// while the database is down instead of hanging for the driver default of 30s
+const SERVER_SELECTION_TIMEOUT = 5 * time.Second
+
 type (
 	// MongoConfig mongo config
 	MongoConfig struct {
 		Driver, Host, Port, Username, Password, DatabaseName string
 	}
+
+	// PoolConfig connection pool, zero values use the driver defaults
+	PoolConfig struct {
+		MaxPoolSize     uint64
+		MinPoolSize     uint64
+		MaxConnIdleTime time.Duration
+		// Timeout of a single operation when its context has no deadline
+		Timeout time.Duration
+		// Stats counted from the pool events when not nil
+		Stats *PoolStats
+	}
+
+	// PoolStats connection pool counters
+	PoolStats struct {
+		created, closed, checkedOut, checkedIn, checkOutFailed, cleared atomic.Int64
+	}
+
+	// PoolStatsSnapshot counters at a point in time
+	PoolStatsSnapshot struct {
+		Open           int64 `json:"open"`
+		InUse          int64 `json:"in_use"`
+		Created        int64 `json:"created"`
+		Closed         int64 `json:"closed"`
+		CheckedOut     int64 `json:"checked_out"`
+		CheckOutFailed int64 `json:"check_out_failed"`
+		Cleared        int64 `json:"cleared"`
+	}
 )
 
-// NewMongoDB mongo db
-func NewMongoDsn(dsn, dbname string) *mongo.Database {
-	clientOptions := options.Client().ApplyURI(dsn)
+// NewMongoDB mongo db, the client connects in the background and reconnects by itself, use Ping to
+// check that the server is reachable
+func NewMongoDsn(dsn, dbname string, pool PoolConfig) (*mongo.Database, error) {
+	clientOptions := options.Client().
+		ApplyURI(dsn).
+		SetServerSelectionTimeout(SERVER_SELECTION_TIMEOUT)
+	if pool.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(pool.MaxPoolSize)
+	}
+	if pool.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(pool.MinPoolSize)
+	}
+	if pool.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(pool.MaxConnIdleTime)
+	}
+	if pool.Timeout > 0 {
+		clientOptions.SetTimeout(pool.Timeout)
+	}
+	if pool.Stats != nil {
+		clientOptions.SetPoolMonitor(&event.PoolMonitor{Event: pool.Stats.observe})
+	}
 
 	client, err := mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
-		log.Fatal().Msgf("error when mongo.Connect(ctx, clientOptions), request: %v, error: %v", dsn, err.Error())
+		return nil, err
 	}
 
-	mongoDB := client.Database(dbname)
+	return client.Database(dbname), nil
+}
 
-	log.Info().Msgf("ping mongodb %s", dbname)
-	if err := mongoDB.Client().Ping(context.Background(), readpref.Primary()); err != nil {
-		log.Fatal().Msgf("error when ping mongodb %s, error: %v", dbname, err.Error())
+// Ping check the primary of db is reachable
+func Ping(ctx context.Context, db *mongo.Database) error {
+	return db.Client().Ping(ctx, readpref.Primary())
+}
+
+// Snapshot current counters
+func (s *PoolStats) Snapshot() PoolStatsSnapshot {
+	snapshot := PoolStatsSnapshot{
+		Created:        s.created.Load(),
+		Closed:         s.closed.Load(),
+		CheckedOut:     s.checkedOut.Load(),
+		CheckOutFailed: s.checkOutFailed.Load(),
+		Cleared:        s.cleared.Load(),
 	}
+	snapshot.Open = snapshot.Created - snapshot.Closed
+	snapshot.InUse = snapshot.CheckedOut - s.checkedIn.Load()
+
+	return snapshot
+}
 
-	return mongoDB
+func (s *PoolStats) observe(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		s.created.Add(1)
+	case event.ConnectionClosed:
+		s.closed.Add(1)
+	case event.GetSucceeded:
+		s.checkedOut.Add(1)
+	case event.ConnectionReturned:
+		s.checkedIn.Add(1)
+	case event.GetFailed:
+		s.checkOutFailed.Add(1)
+	case event.PoolCleared:
+		s.cleared.Add(1)
+	}
 }
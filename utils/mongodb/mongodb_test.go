@@ -2,8 +2,35 @@ package mongodb
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
 )
 
 func TestNewMessageDB(t *testing.T) {
-	NewMongoDsn("mongodb://localhost:27017", "test")
+	db, err := NewMongoDsn("mongodb://localhost:27017", "test", PoolConfig{MaxPoolSize: 10, Stats: &PoolStats{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "test", db.Name())
+
+	_, err = NewMongoDsn("invalid://localhost", "test", PoolConfig{})
+	assert.Error(t, err)
+}
+
+func TestPoolStats(t *testing.T) {
+	stats := &PoolStats{}
+	for _, eventType := range []string{
+		event.ConnectionCreated, event.ConnectionCreated, event.GetSucceeded, event.GetSucceeded,
+		event.ConnectionReturned, event.ConnectionClosed, event.GetFailed,
+	} {
+		stats.observe(&event.PoolEvent{Type: eventType})
+	}
+
+	assert.Equal(t, PoolStatsSnapshot{
+		Open:           1,
+		InUse:          1,
+		Created:        2,
+		Closed:         1,
+		CheckedOut:     2,
+		CheckOutFailed: 1,
+	}, stats.Snapshot())
 }
@@ -0,0 +1,159 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	COUNTRY_CODE = "62"
+
+	TYPE_MOBILE   Type = "mobile"
+	TYPE_LANDLINE Type = "landline"
+
+	// Carrier
+	CARRIER_TELKOMSEL = "telkomsel"
+	CARRIER_INDOSAT   = "indosat"
+	CARRIER_XL        = "xl"
+	CARRIER_AXIS      = "axis"
+	CARRIER_TRI       = "tri"
+	CARRIER_SMARTFREN = "smartfren"
+)
+
+var (
+	// ErrInvalid will throw if the number is not an Indonesian mobile or landline number
+	ErrInvalid = errors.New("phone is invalid")
+
+	// mobilePrefixes carrier by the national prefix without the leading zero, e.g. 812 for 0812
+	mobilePrefixes = map[string]string{
+		"811": CARRIER_TELKOMSEL, "812": CARRIER_TELKOMSEL, "813": CARRIER_TELKOMSEL,
+		"821": CARRIER_TELKOMSEL, "822": CARRIER_TELKOMSEL, "823": CARRIER_TELKOMSEL,
+		"851": CARRIER_TELKOMSEL, "852": CARRIER_TELKOMSEL, "853": CARRIER_TELKOMSEL,
+		"814": CARRIER_INDOSAT, "815": CARRIER_INDOSAT, "816": CARRIER_INDOSAT,
+		"855": CARRIER_INDOSAT, "856": CARRIER_INDOSAT, "857": CARRIER_INDOSAT, "858": CARRIER_INDOSAT,
+		"817": CARRIER_XL, "818": CARRIER_XL, "819": CARRIER_XL,
+		"859": CARRIER_XL, "877": CARRIER_XL, "878": CARRIER_XL,
+		"831": CARRIER_AXIS, "832": CARRIER_AXIS, "833": CARRIER_AXIS, "838": CARRIER_AXIS,
+		"895": CARRIER_TRI, "896": CARRIER_TRI, "897": CARRIER_TRI, "898": CARRIER_TRI, "899": CARRIER_TRI,
+		"881": CARRIER_SMARTFREN, "882": CARRIER_SMARTFREN, "883": CARRIER_SMARTFREN,
+		"884": CARRIER_SMARTFREN, "885": CARRIER_SMARTFREN, "886": CARRIER_SMARTFREN,
+		"887": CARRIER_SMARTFREN, "888": CARRIER_SMARTFREN, "889": CARRIER_SMARTFREN,
+	}
+
+	// twoDigitAreaCodes the big cities, every other area code has 3 digits
+	twoDigitAreaCodes = map[string]bool{
+		"21": true, // Jakarta
+		"22": true, // Bandung
+		"24": true, // Semarang
+		"31": true, // Surabaya
+		"61": true, // Medan
+	}
+)
+
+type (
+	// Type mobile or landline
+	Type string
+
+	// Number parsed Indonesian phone number
+	Number struct {
+		// E164 e.g. +6281234567890, the format stored in the database
+		E164 string `json:"e164"`
+		// National e.g. 081234567890
+		National string `json:"national"`
+		Type     Type   `json:"type"`
+		// Carrier only for mobile
+		Carrier string `json:"carrier,omitempty"`
+		// AreaCode only for landline, without the leading zero e.g. 21 for Jakarta
+		AreaCode string `json:"area_code,omitempty"`
+	}
+)
+
+// Parse Indonesian formats such as 0812-3456-7890, +62 812 3456 7890, 6281234567890 and (021) 1234567
+func Parse(raw string) (Number, error) {
+	digits, err := stripFormatting(raw)
+	if err != nil {
+		return Number{}, err
+	}
+
+	// national significant number, without the country code or trunk prefix
+	var nsn string
+	switch {
+	case strings.HasPrefix(digits, "+"+COUNTRY_CODE):
+		nsn = digits[len(COUNTRY_CODE)+1:]
+	case strings.HasPrefix(digits, "00"+COUNTRY_CODE):
+		nsn = digits[len(COUNTRY_CODE)+2:]
+	case strings.HasPrefix(digits, COUNTRY_CODE):
+		nsn = digits[len(COUNTRY_CODE):]
+	case strings.HasPrefix(digits, "0"):
+		nsn = digits[1:]
+	default:
+		return Number{}, ErrInvalid
+	}
+
+	if nsn == "" || strings.HasPrefix(nsn, "0") || strings.HasPrefix(nsn, "+") {
+		return Number{}, ErrInvalid
+	}
+
+	number := Number{
+		E164:     "+" + COUNTRY_CODE + nsn,
+		National: "0" + nsn,
+	}
+
+	if nsn[0] == '8' {
+		// 08xx-xxxx-xxx up to 08xx-xxxx-xxxxx
+		if len(nsn) < 9 || len(nsn) > 12 {
+			return Number{}, ErrInvalid
+		}
+		carrier, ok := mobilePrefixes[nsn[:3]]
+		if !ok {
+			return Number{}, ErrInvalid
+		}
+		number.Type = TYPE_MOBILE
+		number.Carrier = carrier
+		return number, nil
+	}
+
+	// 0xx-xxxxx, the shortest area code and subscriber number
+	if nsn[0] == '1' || len(nsn) < 7 {
+		return Number{}, ErrInvalid
+	}
+	areaCode := nsn[:3]
+	if twoDigitAreaCodes[nsn[:2]] {
+		areaCode = nsn[:2]
+	}
+	subscriber := nsn[len(areaCode):]
+	if len(subscriber) < 5 || len(subscriber) > 8 {
+		return Number{}, ErrInvalid
+	}
+	number.Type = TYPE_LANDLINE
+	number.AreaCode = areaCode
+
+	return number, nil
+}
+
+// Normalize parse raw and return its E.164 format
+func Normalize(raw string) (string, error) {
+	number, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return number.E164, nil
+}
+
+// stripFormatting remove the separators, only a leading + and digits are kept
+func stripFormatting(raw string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			// separator
+		default:
+			return "", ErrInvalid
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,78 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMobile(t *testing.T) {
+	cases := map[string]string{
+		"081234567890":      "+6281234567890",
+		"0812-3456-7890":    "+6281234567890",
+		"+62 812 3456 7890": "+6281234567890",
+		"6281234567890":     "+6281234567890",
+		"006281234567890":   "+6281234567890",
+		"0877 1234 567":     "+628771234567",
+	}
+
+	for raw, expected := range cases {
+		number, err := Parse(raw)
+		assert.NoError(t, err, raw)
+		assert.Equal(t, expected, number.E164, raw)
+		assert.Equal(t, TYPE_MOBILE, number.Type, raw)
+	}
+}
+
+func TestParseCarrier(t *testing.T) {
+	cases := map[string]string{
+		"081234567890":   CARRIER_TELKOMSEL,
+		"085712345678":   CARRIER_INDOSAT,
+		"081812345678":   CARRIER_XL,
+		"083812345678":   CARRIER_AXIS,
+		"089612345678":   CARRIER_TRI,
+		"088112345678":   CARRIER_SMARTFREN,
+		"+6282112345678": CARRIER_TELKOMSEL,
+	}
+	for raw, carrier := range cases {
+		number, err := Parse(raw)
+		assert.NoError(t, err, raw)
+		assert.Equal(t, carrier, number.Carrier, raw)
+	}
+}
+
+func TestParseLandline(t *testing.T) {
+	number, err := Parse("(021) 1234567")
+	assert.NoError(t, err)
+	assert.Equal(t, "+62211234567", number.E164)
+	assert.Equal(t, "0211234567", number.National)
+	assert.Equal(t, TYPE_LANDLINE, number.Type)
+	assert.Equal(t, "21", number.AreaCode)
+	assert.Empty(t, number.Carrier)
+
+	number, err = Parse("0274-512345")
+	assert.NoError(t, err)
+	assert.Equal(t, "+62274512345", number.E164)
+	assert.Equal(t, "274", number.AreaCode)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"12345",
+		"0812345",          // too short
+		"08123456789012",   // too long
+		"081034567890",     // unknown prefix
+		"+6581234567",      // singapore
+		"0812-3456-789a",   // letter
+		"00812345678",      // double trunk prefix
+		"021 12",           // subscriber too short
+		"0811234567890123", // too long
+		"021",              // area code only
+		"02",               // partial area code
+		"+622",             // partial area code
+	} {
+		_, err := Parse(raw)
+		assert.ErrorIs(t, err, ErrInvalid, raw)
+	}
+}
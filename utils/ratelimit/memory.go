@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval between the removals of the idle keys
+const sweepInterval = time.Minute
+
+type (
+	// MemoryStore in-memory Store of a single instance
+	MemoryStore struct {
+		mu        sync.Mutex
+		buckets   map[string]*memoryBucket
+		windows   map[string]*memoryWindow
+		lastSweep time.Time
+
+		// now replaced by the tests
+		now func() time.Time
+	}
+
+	memoryBucket struct {
+		tokens    float64
+		updatedAt time.Time
+		expiresAt time.Time
+	}
+
+	memoryWindow struct {
+		index             int64
+		previous, current int
+		expiresAt         time.Time
+	}
+)
+
+// NewMemoryStore empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*memoryBucket{},
+		windows: map[string]*memoryWindow{},
+		now:     time.Now,
+	}
+}
+
+// Allow take a request of key
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	if limit.Algorithm == ALGORITHM_SLIDING_WINDOW {
+		return s.slidingWindow(now, key, limit), nil
+	}
+	return s.tokenBucket(now, key, limit), nil
+}
+
+func (s *MemoryStore) tokenBucket(now time.Time, key string, limit Limit) Result {
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: limit.capacity(), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt)
+	bucket.tokens = math.Min(limit.capacity(), bucket.tokens+float64(max(elapsed, 0))*limit.rate())
+	bucket.updatedAt = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	// a full bucket is the same as no bucket
+	bucket.expiresAt = now.Add(time.Duration((limit.capacity() - bucket.tokens) / limit.rate()))
+	return tokenBucketResult(limit, allowed, bucket.tokens)
+}
+
+func (s *MemoryStore) slidingWindow(now time.Time, key string, limit Limit) Result {
+	index := now.UnixNano() / int64(limit.Window)
+	elapsed := time.Duration(now.UnixNano() - index*int64(limit.Window))
+
+	window, ok := s.windows[key]
+	if !ok {
+		window = &memoryWindow{index: index}
+		s.windows[key] = window
+	}
+	switch {
+	case window.index == index-1:
+		window.previous, window.current = window.current, 0
+	case window.index < index-1:
+		window.previous, window.current = 0, 0
+	}
+	window.index = index
+	window.expiresAt = now.Add(2 * limit.Window)
+
+	allowed := slidingWindowEstimate(limit, window.previous, window.current, elapsed)+1 <= float64(limit.Requests)
+	if allowed {
+		window.current++
+	}
+	return slidingWindowResult(limit, allowed, window.previous, window.current, elapsed)
+}
+
+// sweep remove the keys that would start over anyway
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		if now.After(bucket.expiresAt) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, window := range s.windows {
+		if now.After(window.expiresAt) {
+			delete(s.windows, key)
+		}
+	}
+}
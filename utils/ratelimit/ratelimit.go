@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+const (
+	// ALGORITHM_TOKEN_BUCKET refill the tokens evenly over the window, short bursts up to the burst
+	// are allowed
+	ALGORITHM_TOKEN_BUCKET = "token_bucket"
+	// ALGORITHM_SLIDING_WINDOW count the requests of the last window, weighting the previous window
+	// by how much of it is still in the last window
+	ALGORITHM_SLIDING_WINDOW = "sliding_window"
+)
+
+type (
+	// Limit Requests per Window
+	Limit struct {
+		Algorithm string
+		Requests  int
+		Window    time.Duration
+		// Burst capacity of the token bucket, 0 uses Requests
+		Burst int
+	}
+
+	// Result of a request against its limit
+	Result struct {
+		Allowed   bool
+		Limit     int
+		Remaining int
+		// Reset until the quota is fully available again
+		Reset time.Duration
+		// RetryAfter until the next request is allowed, 0 when allowed
+		RetryAfter time.Duration
+	}
+
+	// Store count the requests per key, the in-memory store serves a single instance and the redis
+	// store is shared by every instance
+	Store interface {
+		Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	}
+)
+
+// capacity of the token bucket
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// rate tokens refilled per nanosecond
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / float64(l.Window)
+}
+
+// tokenBucketResult result once the request took a token or was denied, tokens are the ones left
+func tokenBucketResult(limit Limit, allowed bool, tokens float64) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     int(limit.capacity()),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((limit.capacity() - tokens) / limit.rate()),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) / limit.rate()))
+	}
+	return result
+}
+
+// slidingWindowResult result once the request was counted or denied, previous and current are the
+// counts of the windows and elapsed is how far into the current window the request is
+func slidingWindowResult(limit Limit, allowed bool, previous, current int, elapsed time.Duration) Result {
+	left := limit.Window - elapsed
+	estimated := slidingWindowEstimate(limit, previous, current, elapsed)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: max(0, limit.Requests-int(math.Ceil(estimated))),
+		Reset:     left,
+	}
+	if current > 0 {
+		// the current count weighs until the end of the next window
+		result.Reset = left + limit.Window
+	}
+	if allowed {
+		return result
+	}
+
+	// still in the current window once the previous one weighs little enough
+	room := float64(limit.Requests - current - 1)
+	if previous > 0 && room >= 0 {
+		wait := time.Duration(math.Ceil(float64(limit.Window)*(1-room/float64(previous)))) - elapsed
+		if wait < left {
+			result.RetryAfter = max(wait, time.Millisecond)
+			return result
+		}
+	}
+	// in the next window the current count becomes the previous one
+	wait := left
+	if current > 0 {
+		wait += time.Duration(math.Max(0, math.Ceil(float64(limit.Window)*(1-float64(limit.Requests-1)/float64(current)))))
+	}
+	result.RetryAfter = max(wait, time.Millisecond)
+	return result
+}
+
+// slidingWindowEstimate requests of the last window
+func slidingWindowEstimate(limit Limit, previous, current int, elapsed time.Duration) float64 {
+	weight := float64(limit.Window-elapsed) / float64(limit.Window)
+	return float64(previous)*weight + float64(current)
+}
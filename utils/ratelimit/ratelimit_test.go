@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envTestRedisAddr the redis store is tested only when it is set, e.g. localhost:6379
+const envTestRedisAddr = "TEST_REDIS_ADDR"
+
+func TestMemoryTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Algorithm: ALGORITHM_TOKEN_BUCKET, Requests: 60, Window: time.Minute, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res, err := s.Allow(ctx, "ip:1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, 3, res.Limit)
+	}
+	res, _ := s.Allow(ctx, "ip:1", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.Reset)
+
+	// another key has its own bucket
+	res, _ = s.Allow(ctx, "ip:2", limit)
+	assert.True(t, res.Allowed)
+
+	// one token refilled per second
+	now = now.Add(time.Second)
+	res, _ = s.Allow(ctx, "ip:1", limit)
+	assert.True(t, res.Allowed)
+	res, _ = s.Allow(ctx, "ip:1", limit)
+	assert.False(t, res.Allowed)
+}
+
+func TestMemorySlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000020, 0) // 40s into a minute window
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Algorithm: ALGORITHM_SLIDING_WINDOW, Requests: 10, Window: time.Minute}
+
+	for i := 0; i < 10; i++ {
+		res, err := s.Allow(ctx, "uid:1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 9-i, res.Remaining)
+	}
+	res, _ := s.Allow(ctx, "uid:1", limit)
+	assert.False(t, res.Allowed)
+	// 6s into the next window the 10 requests count as 9
+	assert.Equal(t, 26*time.Second, res.RetryAfter)
+
+	// 30s into the next window the previous 10 count as 5
+	now = now.Add(50 * time.Second)
+	for i := 0; i < 5; i++ {
+		res, _ = s.Allow(ctx, "uid:1", limit)
+		assert.True(t, res.Allowed)
+	}
+	res, _ = s.Allow(ctx, "uid:1", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 6*time.Second, res.RetryAfter)
+
+	// two windows later everything is forgotten
+	now = now.Add(2 * time.Minute)
+	res, _ = s.Allow(ctx, "uid:1", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 9, res.Remaining)
+}
+
+func TestRedisStore(t *testing.T) {
+	addr := os.Getenv(envTestRedisAddr)
+	if addr == "" {
+		t.Skipf("%s is not set", envTestRedisAddr)
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	s := NewRedisStore(client, "ratelimit_test:"+time.Now().Format(time.RFC3339Nano)+":")
+
+	for _, algorithm := range []string{ALGORITHM_TOKEN_BUCKET, ALGORITHM_SLIDING_WINDOW} {
+		t.Run(algorithm, func(t *testing.T) {
+			limit := Limit{Algorithm: algorithm, Requests: 3, Window: time.Hour}
+			for i := 2; i >= 0; i-- {
+				res, err := s.Allow(ctx, algorithm, limit)
+				require.NoError(t, err)
+				assert.True(t, res.Allowed)
+				assert.Equal(t, i, res.Remaining)
+			}
+			res, err := s.Allow(ctx, algorithm, limit)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Positive(t, res.RetryAfter)
+		})
+	}
+}
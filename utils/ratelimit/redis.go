@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// tokenBucketScript refill and take a token atomically, the clock of redis is used so every
+	// instance agrees on the time
+	tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated_at')
+local tokens = tonumber(state[1]) or capacity
+local updated_at = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated_at) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated_at', string.format('%.0f', now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate / 1000) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+	// slidingWindowScript count the request in the current window when the estimate allows it, the
+	// windows are keyed by their index next to KEYS[1] so they share its hash slot
+	slidingWindowScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local requests = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local index = math.floor(now / window)
+local elapsed = now - index * window
+local current_key = KEYS[1] .. ':' .. index
+local current = tonumber(redis.call('GET', current_key)) or 0
+local previous = tonumber(redis.call('GET', KEYS[1] .. ':' .. (index - 1))) or 0
+local allowed = 0
+if previous * (window - elapsed) / window + current + 1 <= requests then
+	current = redis.call('INCR', current_key)
+	redis.call('PEXPIRE', current_key, math.ceil(2 * window / 1000))
+	allowed = 1
+end
+return {allowed, previous, current, elapsed}
+`)
+)
+
+// RedisStore Store shared by every instance through redis
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore store keeping the counters under prefix
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Allow take a request of key
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	// the hash tag keeps the keys of the sliding window in one slot of a cluster
+	key = fmt.Sprintf("%s{%s}", s.prefix, key)
+
+	if limit.Algorithm == ALGORITHM_SLIDING_WINDOW {
+		values, err := slidingWindowScript.Run(ctx, s.client, []string{key}, limit.Window.Microseconds(), limit.Requests).Int64Slice()
+		if err != nil {
+			return Result{}, err
+		}
+		elapsed := time.Duration(values[3]) * time.Microsecond
+		return slidingWindowResult(limit, values[0] == 1, int(values[1]), int(values[2]), elapsed), nil
+	}
+
+	rate := limit.rate() * float64(time.Microsecond)
+	values, err := tokenBucketScript.Run(ctx, s.client, []string{key}, rate, limit.capacity()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected reply %v", values)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return tokenBucketResult(limit, allowed == 1, tokens), nil
+}
@@ -0,0 +1,152 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// REDACTED replacement of the redacted values
+const REDACTED = "[redacted]"
+
+// wordBoundary lower case letter or digit followed by an upper case one, the boundary of camelCase words
+var wordBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// jsonMember "key": value of a JSON object, the value may be cut off at the end of a truncated body
+var jsonMember = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:\s*("(?:[^"\\]|\\.)*"?|[^\s,}\]]+)`)
+
+type (
+	// Rules what is redacted, header names match exactly and field names match when their words
+	// contain the words of a rule, so "password" covers "new_password" and "newPassword" but "pin"
+	// does not cover "shipping". Both are case insensitive.
+	Rules struct {
+		headers map[string]bool
+		fields  []string
+	}
+)
+
+// New rules redacting headers and the fields of JSON and form bodies and of query strings
+func New(headers, fields []string) Rules {
+	rules := Rules{headers: map[string]bool{}}
+	for _, header := range headers {
+		if header = strings.TrimSpace(header); header != "" {
+			rules.headers[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+	for _, field := range fields {
+		if field = words(strings.TrimSpace(field)); field != "__" {
+			rules.fields = append(rules.fields, field)
+		}
+	}
+	return rules
+}
+
+// words the words of name separated and surrounded by underscores, "newPassword" is "_new_password_"
+func words(name string) string {
+	name = strings.ToLower(wordBoundary.ReplaceAllString(name, "${1}_${2}"))
+	return "_" + strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name) + "_"
+}
+
+// Header copy of header with the values of the redacted headers replaced
+func (r Rules) Header(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key := range redacted {
+		if r.headers[http.CanonicalHeaderKey(key)] {
+			redacted[key] = []string{REDACTED}
+		}
+	}
+	return redacted
+}
+
+// Field the field must be redacted
+func (r Rules) Field(name string) bool {
+	name = words(name)
+	for _, field := range r.fields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// URI uri with the redacted query parameters replaced
+func (r Rules) URI(uri string) string {
+	path, rawQuery, found := strings.Cut(uri, "?")
+	if !found {
+		return uri
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return path + "?" + REDACTED
+	}
+	return path + "?" + r.values(query).Encode()
+}
+
+// Body redact a JSON or form body by its content type. A truncated JSON body can not be parsed so
+// its members are redacted one by one. Other bodies, e.g. NDJSON, CSV, multipart or text, can not
+// be redacted field by field so only their length is kept.
+func (r Rules) Body(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return r.JSON(body)
+	case mediaType == "application/x-www-form-urlencoded":
+		query, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []byte(REDACTED)
+		}
+		return []byte(r.values(query).Encode())
+	}
+	return []byte(fmt.Sprintf("%s %d bytes", REDACTED, len(body)))
+}
+
+// JSON redact the members of body, at any depth
+func (r Rules) JSON(body []byte) []byte {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err == nil {
+		redacted, err := json.Marshal(r.value(value))
+		if err == nil {
+			return redacted
+		}
+	}
+
+	// invalid or truncated
+	return jsonMember.ReplaceAllFunc(body, func(member []byte) []byte {
+		match := jsonMember.FindSubmatch(member)
+		if !r.Field(string(match[1])) {
+			return member
+		}
+		return []byte(`"` + string(match[1]) + `":"` + REDACTED + `"`)
+	})
+}
+
+func (r Rules) value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, member := range v {
+			if r.Field(key) {
+				v[key] = REDACTED
+			} else {
+				v[key] = r.value(member)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = r.value(v[i])
+		}
+	}
+	return value
+}
+
+func (r Rules) values(values url.Values) url.Values {
+	for key := range values {
+		if r.Field(key) {
+			values[key] = []string{REDACTED}
+		}
+	}
+	return values
+}
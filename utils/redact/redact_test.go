@@ -0,0 +1,38 @@
+package redact
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	rules := New([]string{"authorization", "Cookie"}, []string{"password", "token", "identity_number"})
+
+	header := http.Header{"Authorization": {"Bearer abc"}, "Accept": {"application/json"}}
+	assert.Equal(t, http.Header{"Authorization": {REDACTED}, "Accept": {"application/json"}}, rules.Header(header))
+	assert.Equal(t, "Bearer abc", header.Get("Authorization"))
+
+	assert.JSONEq(t,
+		`{"email":"jane@example.com","new_password":"[redacted]","profile":{"identity_number":"[redacted]"},"devices":[{"push_token":"[redacted]"}]}`,
+		string(rules.Body("application/json; charset=utf-8", []byte(`{"email":"jane@example.com","new_password":"s3cret","profile":{"identity_number":"3171"},"devices":[{"push_token":"x"}]}`))))
+
+	// the capture limit cut the body
+	assert.Equal(t,
+		`{"email":"jane@example.com","password":"[redacted]","token":"[redacted]"`,
+		string(rules.Body("application/json", []byte(`{"email":"jane@example.com","password":"s3cr\"et","token":"abc`))))
+
+	assert.Equal(t, "email=jane%40example.com&password=%5Bredacted%5D", string(rules.Body("application/x-www-form-urlencoded", []byte("email=jane%40example.com&password=s3cret"))))
+	assert.Equal(t, "/reset?email=jane%40example.com&token=%5Bredacted%5D", rules.URI("/reset?token=abc&email=jane%40example.com"))
+	assert.Equal(t, "/users", rules.URI("/users"))
+	assert.Equal(t, "[redacted] 14 bytes", string(rules.Body("text/plain", []byte("plain password"))))
+	assert.Equal(t, "[redacted] 22 bytes", string(rules.Body("application/x-ndjson", []byte(`{"password":"s3cret"}`+"\n"))))
+	assert.Equal(t, "[redacted] 6 bytes", string(rules.Body("", []byte("secret"))))
+
+	// whole words of the key
+	assert.True(t, rules.Field("accessToken"))
+	assert.True(t, rules.Field("X-Identity-Number"))
+	assert.False(t, rules.Field("tokens_left"))
+	assert.False(t, New(nil, []string{"pin"}).Field("shipping"))
+}
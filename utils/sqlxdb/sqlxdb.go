@@ -1,9 +1,13 @@
 package sqlxdb
 
 import (
-	"log"
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/erwinwahyura/go-boilerplate/utils/backoff"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	zlog "github.com/rs/zerolog/log"
@@ -14,25 +18,97 @@ type (
 	SqlxConfig struct {
 		Driver, Host, Port, Username, Password, DatabaseName string
 	}
+
+	// PoolConfig connection pool, zero values use the defaults
+	PoolConfig struct {
+		MaxOpenConns    int
+		MaxIdleConns    int
+		ConnMaxLifetime time.Duration
+		// ConnMaxIdleTime 0 keeps idle connections until ConnMaxLifetime
+		ConnMaxIdleTime time.Duration
+		// StatementTimeout 0 lets statements run without a server side limit
+		StatementTimeout time.Duration
+	}
 )
 
-// NewSqlxDB sql db
-func NewSqlxDsn(driver, dsn string) *sqlx.DB {
+// DefaultPool pool used for the zero values of PoolConfig
+var DefaultPool = PoolConfig{
+	MaxOpenConns:    25,
+	MaxIdleConns:    25,
+	ConnMaxLifetime: 5 * time.Minute,
+}
+
+func (p PoolConfig) withDefaults() PoolConfig {
+	if p.MaxOpenConns <= 0 {
+		p.MaxOpenConns = DefaultPool.MaxOpenConns
+	}
+	if p.MaxIdleConns <= 0 {
+		p.MaxIdleConns = DefaultPool.MaxIdleConns
+	}
+	if p.MaxIdleConns > p.MaxOpenConns {
+		p.MaxIdleConns = p.MaxOpenConns
+	}
+	if p.ConnMaxLifetime <= 0 {
+		p.ConnMaxLifetime = DefaultPool.ConnMaxLifetime
+	}
+	return p
+}
+
+// ConnectSqlxDsn sql db, the ping is retried with backoff until it succeeds or ctx is done so a
+// database that is briefly unavailable during a deploy does not crash the process
+func ConnectSqlxDsn(ctx context.Context, driver, dsn string, pool PoolConfig) (*sqlx.DB, error) {
+	db, err := OpenSqlxDsn(driver, dsn, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	zlog.Info().Msgf("ping %s", driver)
+	err = backoff.Default.Retry(ctx, db.PingContext, func(attempt int, wait time.Duration, err error) {
+		zlog.Warn().Msgf("error when ping %s, retrying in %s, attempt: %d, error: %v", driver, wait, attempt, err)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenSqlxDsn sql db without ping, the connection is established on first use
+func OpenSqlxDsn(driver, dsn string, pool PoolConfig) (*sqlx.DB, error) {
+	if pool.StatementTimeout > 0 {
+		dsn = withStatementTimeout(dsn, pool.StatementTimeout)
+	}
 
 	db, err := sqlx.Open(driver, dsn)
 	if err != nil {
-		zlog.Fatal().Msgf("error when sqlx.Connect, error: %v", err.Error())
+		return nil, err
 	}
 
 	// Setup Connection
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	pool = pool.withDefaults()
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	return db, nil
+}
 
-	log.Printf("ping %s", driver)
-	if err := db.Ping(); err != nil {
-		zlog.Fatal().Msgf("error when ping %s, error: %v", driver, err.Error())
+// withStatementTimeout set statement_timeout of every connection, lib/pq sends unknown dsn
+// parameters to the server as run-time parameters
+func withStatementTimeout(dsn string, timeout time.Duration) string {
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		query := u.Query()
+		query.Set("statement_timeout", ms)
+		u.RawQuery = query.Encode()
+		return u.String()
 	}
 
-	return db
+	return strings.TrimSpace(dsn + " statement_timeout=" + ms)
 }
@@ -26,12 +26,30 @@ var (
 	ErrorNotFound = errors.New("your requested item is not found")
 	// ErrorDuplicateData will throw if the current action already exists
 	ErrorDuplicateData = errors.New("your item already exist")
+	// ErrorDuplicateUsername will throw if the username is already taken
+	ErrorDuplicateUsername = errors.New("username already exist")
+	// ErrorDuplicatePhoneNumber will throw if the phone number is used by another user
+	ErrorDuplicatePhoneNumber = errors.New("phone number already exist")
+	// ErrorConflict will throw if the item was changed since the version the client read
+	ErrorConflict = errors.New("your item was changed by another request, reload it and retry")
+	// ErrorPreconditionRequired will throw if a conditional request is sent without If-Match
+	ErrorPreconditionRequired = errors.New("If-Match header is required")
 	// ErrorBadRequest will throw if the given request-body or params is not valid
 	ErrorBadRequest = errors.New("given param is not valid")
 	// ErrorUnauthorized will throw if not authorized
 	ErrorUnauthorized = errors.New("unauthorized")
+	// ErrorForbidden will throw if authenticated but not allowed to access the resource
+	ErrorForbidden = errors.New("forbidden")
 	// ErrorInternalServerThirdParty will throw if any Internal Server Error from third party
 	ErrorInternalServerThirdParty = errors.New("third party internal server error")
+	// ErrorServiceUnavailable will throw if a required dependency is down
+	ErrorServiceUnavailable = errors.New("service unavailable")
+	// ErrorTooManyRequests will throw if the client went over its rate limit
+	ErrorTooManyRequests = errors.New("too many requests, retry later")
+	// ErrorIdempotencyKeyInUse will throw if the first request with the Idempotency-Key is still running
+	ErrorIdempotencyKeyInUse = errors.New("a request with this Idempotency-Key is in progress, retry later")
+	// ErrorIdempotencyKeyMismatch will throw if the Idempotency-Key was used with another request
+	ErrorIdempotencyKeyMismatch = errors.New("Idempotency-Key was already used with another request")
 	// ErrorResultNotFound will throw if endpoint returns an empty list
 	ErrorResultNotFound = errors.New("Result Not Found")
 
@@ -56,12 +74,27 @@ const (
 	DUPLICATE_DATA        = "duplicate_data"
 	BAD_REQUEST           = "bad_request"
 	UNAUTHORIZE           = "unauthorized"
+	FORBIDDEN             = "forbidden"
 	REFRESH_TOKEN_REVOKED = "refresh_token_revoked"
 	NO_CONTENT            = "no_content"
 	ACCESS_TOKEN_EXPIRED  = "access_token_expired"
 	REFRESH_TOKEN_EXPIRED = "refresh_token_expired"
+	SERVICE_UNAVAILABLE   = "service_unavailable"
+	CONFLICT              = "conflict"
+	PRECONDITION_REQUIRED = "precondition_required"
+	TOO_MANY_REQUESTS     = "too_many_requests"
+
+	// idempotency
+	IDEMPOTENCY_KEY_IN_USE   = "idempotency_key_in_use"
+	IDEMPOTENCY_KEY_MISMATCH = "idempotency_key_mismatch"
 )
 
+// CodeError invalid field error carrying its own code, e.g. model.ErrorMessageCode
+type CodeError interface {
+	error
+	Code() string
+}
+
 // GetStatusCode for handle status error
 func GetStatusCode(err error) (int, string) {
 	if err == nil {
@@ -74,19 +107,52 @@ func GetStatusCode(err error) (int, string) {
 		return http.StatusBadRequest, BAD_REQUEST
 	case ErrorNotFound:
 		return http.StatusNotFound, DATA_NOT_EXIST
-	case ErrorDuplicateData:
+	case ErrorDuplicateData, ErrorDuplicateUsername, ErrorDuplicatePhoneNumber:
 		return http.StatusConflict, DUPLICATE_DATA
 	case ErrorUnauthorized, ErrorState, ErrorBearer, ErrorInvalidBearerToken:
 		return http.StatusUnauthorized, UNAUTHORIZE
+	case ErrorForbidden:
+		return http.StatusForbidden, FORBIDDEN
 	case ErrorRefreshTokenRevoked:
 		return http.StatusUnauthorized, REFRESH_TOKEN_REVOKED
 	case ErrorNoContent:
 		return http.StatusNoContent, NO_CONTENT
 	case ErrorAccessTokenExpired:
 		return http.StatusUnauthorized, ACCESS_TOKEN_EXPIRED
-	default:
-		return http.StatusInternalServerError, INTERNAL_SERVER_ERROR
+	case ErrorServiceUnavailable:
+		return http.StatusServiceUnavailable, SERVICE_UNAVAILABLE
+	case ErrorConflict:
+		return http.StatusConflict, CONFLICT
+	case ErrorPreconditionRequired:
+		return http.StatusPreconditionRequired, PRECONDITION_REQUIRED
+	case ErrorTooManyRequests:
+		return http.StatusTooManyRequests, TOO_MANY_REQUESTS
+	case ErrorIdempotencyKeyInUse:
+		return http.StatusConflict, IDEMPOTENCY_KEY_IN_USE
+	case ErrorIdempotencyKeyMismatch:
+		return http.StatusUnprocessableEntity, IDEMPOTENCY_KEY_MISMATCH
 	}
+
+	var codeErr CodeError
+	if errors.As(err, &codeErr) {
+		return http.StatusBadRequest, strings.ToLower(codeErr.Code())
+	}
+	return http.StatusInternalServerError, INTERNAL_SERVER_ERROR
+}
+
+// MatchETag whether the If-Match header value matches etag with the strong comparison of RFC 9110
+// section 8.8.3.2, "*" matches any version and a weak validator never matches
+func MatchETag(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if candidate != "" && !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 func HashToStr(authCode string) string {
@@ -136,6 +202,20 @@ func PtrToValue[T any](valuePtr *T) T {
 	return v
 }
 
+// ValueToPtr converts value to pointer, the zero value of the type would be nil.
+func ValueToPtr[T comparable](value T) *T {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return &value
+}
+
+// GenerateUniqueUsernameFromEmail the email's local part with a random number, the username is not
+// checked against the existing ones so it may be taken.
+//
+// Deprecated: use username service Generate, it sanitises the email, skips the reserved names and
+// retries the taken ones.
 func GenerateUniqueUsernameFromEmail(email string) string {
 	username := strings.Split(email, "@")[0]
 	randInt, _ := rand.Int(rand.Reader, big.NewInt(999))